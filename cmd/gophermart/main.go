@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/playmixer/gophermart/internal/adapters/api/grpcapi"
 	"github.com/playmixer/gophermart/internal/adapters/api/rest"
 	"github.com/playmixer/gophermart/internal/adapters/logger"
 	"github.com/playmixer/gophermart/internal/adapters/store"
@@ -49,33 +50,77 @@ func run() error {
 	}
 
 	mart := gophermart.New(ctx, cfg.Gophermart, storage, gophermart.SetSecretKey(cfg.Secret), gophermart.Logger(lgr))
+	if err := mart.BootstrapAdmins(ctx); err != nil {
+		return fmt.Errorf("failed bootstrap admins: %w", err)
+	}
+
+	keys, err := rest.NewKeySet(cfg.Rest)
+	if err != nil {
+		return fmt.Errorf("failed initialize jwt keys: %w", err)
+	}
+	go keys.Watch(ctx, cfg.Rest.JWTKeysReload, func(err error) {
+		lgr.Error("failed reload jwt keys", zap.Error(err))
+	})
 
 	server, err := rest.New(
 		mart,
 		rest.Logger(lgr),
 		rest.SetAddress(cfg.Rest.Address),
-		rest.SetSecretKey([]byte(cfg.Rest.Secret)),
+		rest.SetKeySet(keys),
+		rest.TrustedProxies(cfg.Rest.TrustedProxies),
+		rest.SetCookie(cfg.Rest.Cookie),
+		rest.EventsKeepAlive(cfg.Rest.EventsKeepAlive),
+		rest.SetWebSocket(cfg.Rest.WebSocket),
+		rest.SetRateLimits(cfg.Rest.RateLimit),
+		rest.SetHealth(cfg.Rest.Health),
+		rest.SetBodyLimits(cfg.Rest.Body),
 	)
 	if err != nil {
 		return fmt.Errorf("failed initialize rest server: %w", err)
 	}
 
+	var grpcServer *grpcapi.Server
+	if cfg.GRPC.Address != "" {
+		grpcServer = grpcapi.New(
+			mart,
+			grpcapi.Logger(lgr),
+			grpcapi.SetAddress(cfg.GRPC.Address),
+			grpcapi.SetTokenTTL(cfg.GRPC.TokenTTL),
+			grpcapi.SetKeySet(keys),
+		)
+	}
+
 	lgr.Info("Starting")
 	go func() {
 		if err := server.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			lgr.Error("failed run server", zap.Error(err))
 		}
 	}()
+	if grpcServer != nil {
+		go func() {
+			if err := grpcServer.Run(); err != nil {
+				lgr.Error("failed run grpc server", zap.Error(err))
+			}
+		}()
+	}
 
 	<-ctx.Done()
 	lgr.Info("Stopping...")
+	// /readyz отвечает not-ready, балансировщик успевает снять трафик до остановки сервера
+	server.Drain(context.Background())
+
 	ctxShutdown, stop := context.WithTimeout(context.Background(), shutdownDelay)
 	defer stop()
 
-	// выключаем http сервер
+	// выключаем http и grpc серверы, текущие запросы завершаются
 	if err := server.Shutdown(ctxShutdown); err != nil {
 		lgr.Error("Server Shutdown with error", zap.Error(err))
 	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctxShutdown); err != nil {
+			lgr.Error("GRPC Server Shutdown with error", zap.Error(err))
+		}
+	}
 
 	// ждем завершения горутин
 	mart.Wait()
@@ -15,35 +15,87 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/user/balance": {
+        "/.well-known/jwks.json": {
             "get": {
-                "description": "get user balance",
-                "consumes": [
-                    "text/plain"
-                ],
+                "description": "public keys for verifying user tokens signed with RS256 or EdDSA",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "balance"
+                    "auth"
                 ],
-                "summary": "User balance",
+                "summary": "JSON Web Key Set",
                 "responses": {
                     "200": {
-                        "description": "успешная обработка запроса"
+                        "description": "открытые ключи подписи токенов",
+                        "schema": {
+                            "$ref": "#/definitions/jwt.JWKSet"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/export/orders.csv": {
+            "get": {
+                "description": "заказы всех пользователей в CSV: user_id, number, status, accrual, uploaded_at, только для роли admin.\nСтроки передаются по мере чтения из базы, выгрузка пишется в журнал аудита.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Export orders of all users as CSV",
+                "responses": {
+                    "200": {
+                        "description": "CSV с заголовком"
+                    },
+                    "400": {
+                        "description": "неверные параметры списка"
                     },
                     "401": {
                         "description": "пользователь не авторизован"
                     },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
                     "500": {
                         "description": "внутренняя ошибка сервера"
                     }
                 }
             }
         },
-        "/api/user/balance/withdraw": {
+        "/api/admin/export/withdrawals.csv": {
+            "get": {
+                "description": "списания всех пользователей в CSV: user_id, order, sum, processed_at, только для роли admin.\nСтроки передаются по мере чтения из базы, выгрузка пишется в журнал аудита.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Export withdrawals of all users as CSV",
+                "responses": {
+                    "200": {
+                        "description": "CSV с заголовком"
+                    },
+                    "400": {
+                        "description": "неверные параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/admin/merchants": {
             "post": {
-                "description": "Withdraw from user balans",
+                "description": "create merchant account, available for admin role",
                 "consumes": [
                     "application/json"
                 ],
@@ -51,32 +103,38 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "balance"
+                    "admin"
                 ],
-                "summary": "Withdraw from user balans",
+                "summary": "Create merchant",
                 "parameters": [
                     {
-                        "description": "withdraw",
-                        "name": "withdraw",
+                        "description": "merchant",
+                        "name": "merchant",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/rest.tWithdraw"
+                            "$ref": "#/definitions/rest.tMerchantName"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "успешная обработка запроса"
+                    "201": {
+                        "description": "магазин создан",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tMerchant"
+                        }
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
                     },
                     "401": {
                         "description": "пользователь не авторизован"
                     },
-                    "402": {
-                        "description": "на счету недостаточно средств"
+                    "403": {
+                        "description": "недостаточно прав"
                     },
-                    "422": {
-                        "description": "неверный номер заказа"
+                    "409": {
+                        "description": "магазин с таким названием уже существует"
                     },
                     "500": {
                         "description": "внутренняя ошибка сервера"
@@ -84,39 +142,55 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/login": {
+        "/api/admin/merchants/{id}/keys": {
             "post": {
-                "description": "authorization",
+                "description": "issue API key with scopes ` + "`" + `orders:write` + "`" + `, ` + "`" + `balance:withdraw` + "`" + `; the key is shown only once, available for admin role",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Login user",
+                "summary": "Create merchant API key",
                 "parameters": [
                     {
-                        "description": "auth",
-                        "name": "auth",
+                        "type": "integer",
+                        "description": "merchant id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "scopes",
+                        "name": "scopes",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/rest.tAuthorization"
+                            "$ref": "#/definitions/rest.tMerchantKeyScopes"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "пользователь успешно аутентифицирован"
+                    "201": {
+                        "description": "ключ выпущен",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tMerchantKey"
+                        }
                     },
                     "400": {
-                        "description": "неверный формат запроса"
+                        "description": "неверный формат запроса или разрешения"
                     },
                     "401": {
-                        "description": "неверная пара логин/пароль"
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "магазин не найден"
                     },
                     "500": {
                         "description": "внутренняя ошибка сервера"
@@ -124,115 +198,141 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/orders": {
-            "get": {
-                "description": "get user orders",
-                "consumes": [
-                    "text/plain"
-                ],
+        "/api/admin/merchants/{id}/keys/{keyID}": {
+            "delete": {
+                "description": "revoke API key of merchant, available for admin role",
                 "produces": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "tags": [
-                    "order"
+                    "admin"
+                ],
+                "summary": "Revoke merchant API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "merchant id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "key id",
+                        "name": "keyID",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "List user orders",
                 "responses": {
                     "200": {
-                        "description": "успешная обработка запроса"
+                        "description": "ключ отозван"
                     },
-                    "204": {
-                        "description": "нет данных для ответа"
+                    "400": {
+                        "description": "неверный идентификатор"
                     },
                     "401": {
                         "description": "пользователь не авторизован"
                     },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "ключ не найден или уже отозван"
+                    },
                     "500": {
                         "description": "внутренняя ошибка сервера"
                     }
                 }
-            },
-            "post": {
-                "description": "upload order",
-                "consumes": [
-                    "text/plain"
-                ],
+            }
+        },
+        "/api/admin/merchants/{id}/users/{userID}": {
+            "put": {
+                "description": "allow merchant to act on behalf of user, available for admin role",
                 "produces": [
                     "text/plain"
                 ],
                 "tags": [
-                    "order"
+                    "admin"
                 ],
-                "summary": "upload user order",
+                "summary": "Link merchant to user",
                 "parameters": [
                     {
-                        "description": "order_id",
-                        "name": "order_id",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "integer"
-                        }
+                        "type": "integer",
+                        "description": "merchant id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "userID",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "номер заказа уже был загружен этим пользователем"
-                    },
-                    "202": {
-                        "description": "новый номер заказа принят в обработку"
+                        "description": "магазин связан с пользователем"
                     },
                     "400": {
-                        "description": "неверный формат запроса"
+                        "description": "неверный идентификатор"
                     },
                     "401": {
                         "description": "пользователь не авторизован"
                     },
-                    "409": {
-                        "description": "номер заказа уже был загружен другим пользователем"
+                    "403": {
+                        "description": "недостаточно прав"
                     },
-                    "422": {
-                        "description": "неверный формат номера заказа"
+                    "404": {
+                        "description": "магазин или пользователь не найден"
                     },
                     "500": {
                         "description": "внутренняя ошибка сервера"
                     }
                 }
-            }
-        },
-        "/api/user/register": {
-            "post": {
-                "description": "registration user",
-                "consumes": [
-                    "application/json"
-                ],
+            },
+            "delete": {
+                "description": "forbid merchant to act on behalf of user, available for admin role",
                 "produces": [
                     "text/plain"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Register user",
+                "summary": "Unlink merchant from user",
                 "parameters": [
                     {
-                        "description": "registration",
-                        "name": "registration",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/rest.tRegistration"
-                        }
+                        "type": "integer",
+                        "description": "merchant id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "userID",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "пользователь успешно зарегистрирован и аутентифицирован"
+                        "description": "связь удалена"
                     },
                     "400": {
-                        "description": "неверный формат запроса"
+                        "description": "неверный идентификатор"
                     },
-                    "409": {
-                        "description": "логин уже занят"
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "связь не найдена"
                     },
                     "500": {
                         "description": "внутренняя ошибка сервера"
@@ -240,73 +340,2452 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/withdrawals": {
+        "/api/admin/users": {
             "get": {
-                "description": "Withdraw from user balans",
-                "consumes": [
-                    "text/plain"
-                ],
+                "description": "search users by login substring, available for support and admin roles",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "balance"
+                    "admin"
+                ],
+                "summary": "Search users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "часть логина",
+                        "name": "login",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Withdraw from user balans",
                 "responses": {
                     "200": {
                         "description": "успешная обработка запроса",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/rest.tWithdrawBalance"
+                                "$ref": "#/definitions/rest.tAdminUser"
                             }
                         }
                     },
                     "204": {
-                        "description": "нет ни одного списания"
+                        "description": "пользователи не найдены"
                     },
                     "401": {
                         "description": "пользователь не авторизован"
                     },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
                     "500": {
                         "description": "внутренняя ошибка сервера"
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "rest.tAuthorization": {
-            "type": "object",
-            "properties": {
-                "login": {
-                    "type": "string"
-                },
-                "password": {
-                    "type": "string"
-                }
-            }
         },
-        "rest.tRegistration": {
-            "type": "object",
-            "properties": {
-                "login": {
-                    "type": "string"
-                },
-                "password": {
-                    "type": "string"
+        "/api/admin/users/{id}": {
+            "get": {
+                "description": "get user by id, available for support and admin roles",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tAdminUser"
+                        }
+                    },
+                    "400": {
+                        "description": "неверный идентификатор пользователя"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "пользователь не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
                 }
             }
         },
-        "rest.tWithdraw": {
-            "type": "object",
-            "properties": {
-                "order": {
-                    "type": "string"
-                },
-                "sum": {
-                    "type": "number"
+        "/api/admin/users/{id}/balance": {
+            "get": {
+                "description": "get balance of user, available for support and admin roles",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Balance of user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tBalanceByUser"
+                        }
+                    },
+                    "400": {
+                        "description": "неверный идентификатор пользователя"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "пользователь не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/admin/users/{id}/balance/adjustments": {
+            "post": {
+                "description": "manual balance adjustment written to audit, available for admin role",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Adjust balance of user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "adjustment",
+                        "name": "adjustment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tBalanceAdjustment"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "баланс после корректировки",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tBalanceByUser"
+                        }
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "402": {
+                        "description": "на счету недостаточно средств для списания"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "пользователь не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/admin/users/{id}/orders": {
+            "get": {
+                "description": "get orders of user, available for support and admin roles",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List orders of user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.tOrderByUser"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "нет данных для ответа"
+                    },
+                    "400": {
+                        "description": "неверный идентификатор пользователя или параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "пользователь не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/admin/users/{id}/orders/{number}": {
+            "get": {
+                "description": "get order of user with status history, available for support and admin roles",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Order of user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "номер заказа",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "заказ и история статусов",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tOrderDetails"
+                        }
+                    },
+                    "400": {
+                        "description": "неверный идентификатор пользователя"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "пользователь или заказ не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/admin/users/{id}/role": {
+            "put": {
+                "description": "change role of user, tokens of user are revoked, available for admin role",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Set role of user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tUserRole"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "роль изменена"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса или роль"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "пользователь не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/admin/users/{id}/withdrawals": {
+            "get": {
+                "description": "get withdrawals of user, available for support and admin roles",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List withdrawals of user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.tWithdrawBalance"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "нет ни одного списания"
+                    },
+                    "400": {
+                        "description": "неверный идентификатор пользователя или параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "недостаточно прав"
+                    },
+                    "404": {
+                        "description": "пользователь не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/merchant/users/{id}/balance/withdraw": {
+            "post": {
+                "description": "withdraw points on behalf of linked user, requires scope ` + "`" + `balance:withdraw` + "`" + `",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "merchant"
+                ],
+                "summary": "Withdraw from user balance by merchant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003cAPI key\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "withdraw",
+                        "name": "withdraw",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tWithdraw"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "неверный API ключ"
+                    },
+                    "402": {
+                        "description": "на счету недостаточно средств"
+                    },
+                    "403": {
+                        "description": "нет разрешения или магазин не связан с пользователем"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json"
+                    },
+                    "422": {
+                        "description": "неверный номер заказа или сумма не больше нуля"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/merchant/users/{id}/orders": {
+            "post": {
+                "description": "upload order on behalf of linked user, requires scope ` + "`" + `orders:write` + "`" + `",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "merchant"
+                ],
+                "summary": "Upload order of user by merchant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer \u003cAPI key\u003e",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "order_id",
+                        "name": "order_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "integer"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "номер заказа уже был загружен этим пользователем"
+                    },
+                    "202": {
+                        "description": "новый номер заказа принят в обработку"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "неверный API ключ"
+                    },
+                    "403": {
+                        "description": "нет разрешения или магазин не связан с пользователем"
+                    },
+                    "409": {
+                        "description": "номер заказа уже был загружен другим пользователем"
+                    },
+                    "415": {
+                        "description": "тело запроса не text/plain"
+                    },
+                    "422": {
+                        "description": "неверный формат номера заказа"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user": {
+            "delete": {
+                "description": "anonymize login and credentials, financial records are kept for accounting; all tokens are revoked",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "account"
+                ],
+                "summary": "Delete account",
+                "parameters": [
+                    {
+                        "description": "current password",
+                        "name": "password",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tDeleteUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "учетная запись удалена"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "неверный пароль"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/2fa": {
+            "delete": {
+                "description": "disable 2FA, requires authenticator code or recovery code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Disable TOTP",
+                "parameters": [
+                    {
+                        "description": "code",
+                        "name": "code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tTOTPCode"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "двухфакторная аутентификация выключена"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "неверный код"
+                    },
+                    "409": {
+                        "description": "двухфакторная аутентификация не включена"
+                    },
+                    "423": {
+                        "description": "ввод кода временно заблокирован после серии неудачных попыток"
+                    },
+                    "429": {
+                        "description": "слишком частые попытки, повторить через Retry-After"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/2fa/confirm": {
+            "post": {
+                "description": "confirm first authenticator code, enable 2FA and issue one-time recovery codes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Confirm TOTP",
+                "parameters": [
+                    {
+                        "description": "code",
+                        "name": "code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tTOTPCode"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "двухфакторная аутентификация включена",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tRecoveryCodes"
+                        }
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "409": {
+                        "description": "секрет не создан или двухфакторная аутентификация уже включена"
+                    },
+                    "422": {
+                        "description": "неверный код"
+                    },
+                    "423": {
+                        "description": "ввод кода временно заблокирован после серии неудачных попыток"
+                    },
+                    "429": {
+                        "description": "слишком частые попытки, повторить через Retry-After"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/2fa/enroll": {
+            "post": {
+                "description": "generate authenticator secret and provisioning URI, 2FA is enabled after confirmation",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Enroll TOTP",
+                "responses": {
+                    "200": {
+                        "description": "секрет создан, нужно подтвердить первый код",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tTOTPEnrollment"
+                        }
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "409": {
+                        "description": "двухфакторная аутентификация уже включена"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/balance": {
+            "get": {
+                "description": "get user balance",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "User balance",
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса"
+                    },
+                    "304": {
+                        "description": "данные не менялись, ETag совпал с If-None-Match"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/balance/withdraw": {
+            "post": {
+                "description": "Withdraw from user balans",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Withdraw from user balans",
+                "parameters": [
+                    {
+                        "description": "withdraw",
+                        "name": "withdraw",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tWithdraw"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "402": {
+                        "description": "на счету недостаточно средств"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json"
+                    },
+                    "422": {
+                        "description": "неверный номер заказа или сумма не больше нуля"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/events": {
+            "get": {
+                "description": "server-sent events: order (новый статус заказа) и balance (новый баланс). id события передается в Last-Event-ID при переподключении",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "events"
+                ],
+                "summary": "User events",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "идентификатор последнего полученного события",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "поток событий",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "неверный Last-Event-ID"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    }
+                }
+            }
+        },
+        "/api/user/export": {
+            "get": {
+                "description": "zip archive with profile.json, orders.json, withdrawals.json and balance.json (current balance and history)",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "account"
+                ],
+                "summary": "Export personal data",
+                "responses": {
+                    "200": {
+                        "description": "архив с данными пользователя"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/login": {
+            "post": {
+                "description": "authorization",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login user",
+                "parameters": [
+                    {
+                        "description": "auth",
+                        "name": "auth",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tAuthorization"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "пользователь успешно аутентифицирован"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "неверная пара логин/пароль"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json"
+                    },
+                    "423": {
+                        "description": "вход временно заблокирован после серии неудачных попыток"
+                    },
+                    "429": {
+                        "description": "слишком частые попытки входа, повторить через Retry-After"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/login/2fa": {
+            "post": {
+                "description": "finish login with TOTP code or recovery code after ` + "`" + `POST /api/user/login` + "`" + ` answered 202",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login second step",
+                "parameters": [
+                    {
+                        "description": "code",
+                        "name": "code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tTOTPCode"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "пользователь успешно аутентифицирован"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "неверный код или истек срок ввода кода"
+                    },
+                    "423": {
+                        "description": "ввод кода временно заблокирован после серии неудачных попыток"
+                    },
+                    "429": {
+                        "description": "слишком частые попытки, повторить через Retry-After"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/orders": {
+            "get": {
+                "description": "get user orders",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "List user orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "размер страницы, от 1 до 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "курсор следующей страницы из заголовка X-Next-Cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "порядок по времени загрузки: asc (по умолчанию) или desc",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "загруженные не раньше, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "загруженные раньше, RFC3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "статусы через запятую: NEW,PROCESSING,INVALID,PROCESSED",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса"
+                    },
+                    "204": {
+                        "description": "нет данных для ответа"
+                    },
+                    "304": {
+                        "description": "данные не менялись, ETag совпал с If-None-Match"
+                    },
+                    "400": {
+                        "description": "неверные параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            },
+            "post": {
+                "description": "upload order",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "upload user order",
+                "parameters": [
+                    {
+                        "description": "order_id",
+                        "name": "order_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "integer"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "номер заказа уже был загружен этим пользователем"
+                    },
+                    "202": {
+                        "description": "новый номер заказа принят в обработку"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "409": {
+                        "description": "номер заказа уже был загружен другим пользователем"
+                    },
+                    "415": {
+                        "description": "тело запроса не text/plain"
+                    },
+                    "422": {
+                        "description": "неверный формат номера заказа"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/orders.csv": {
+            "get": {
+                "description": "заказы пользователя в CSV: number, status, accrual, uploaded_at. Строки передаются по мере чтения из базы.\nПринимает from, to, sort и status как список заказов.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Export orders as CSV",
+                "responses": {
+                    "200": {
+                        "description": "CSV с заголовком, без заказов только заголовок"
+                    },
+                    "400": {
+                        "description": "неверные параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/orders/batch": {
+            "post": {
+                "description": "upload orders batch: json array of numbers or text/plain with a number per line",
+                "consumes": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "upload user orders batch",
+                "parameters": [
+                    {
+                        "description": "номера заказов, не больше 1000",
+                        "name": "orders",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "результат по каждому номеру: accepted, already_uploaded, owned_by_another_user, invalid",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.tOrderUpload"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "неверный формат запроса, пустой пакет или больше 1000 номеров"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json и не text/plain"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/orders/{number}": {
+            "get": {
+                "description": "get user order with status history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "User order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "номер заказа",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "заказ и история статусов",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tOrderDetails"
+                        }
+                    },
+                    "304": {
+                        "description": "данные не менялись, ETag совпал с If-None-Match"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "404": {
+                        "description": "заказ не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/password": {
+            "post": {
+                "description": "change password, all previously issued tokens are revoked",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Change user password",
+                "parameters": [
+                    {
+                        "description": "password",
+                        "name": "password",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tChangePassword"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "пароль изменен, выдан новый токен"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса или нового пароля"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "неверный текущий пароль"
+                    },
+                    "423": {
+                        "description": "смена пароля временно заблокирована после серии неудачных попыток"
+                    },
+                    "429": {
+                        "description": "слишком частые попытки, повторить через Retry-After"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/register": {
+            "post": {
+                "description": "registration user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Register user",
+                "parameters": [
+                    {
+                        "description": "registration",
+                        "name": "registration",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tRegistration"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "пользователь успешно зарегистрирован и аутентифицирован"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "409": {
+                        "description": "логин уже занят"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/withdrawals": {
+            "get": {
+                "description": "Withdraw from user balans",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Withdraw from user balans",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "размер страницы, от 1 до 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "курсор следующей страницы из заголовка X-Next-Cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "порядок по времени списания: asc (по умолчанию) или desc",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "списания не раньше, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "списания раньше, RFC3339",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.tWithdrawBalance"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "нет ни одного списания"
+                    },
+                    "304": {
+                        "description": "данные не менялись, ETag совпал с If-None-Match"
+                    },
+                    "400": {
+                        "description": "неверные параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/withdrawals.csv": {
+            "get": {
+                "description": "списания пользователя в CSV: order, sum, processed_at. Строки передаются по мере чтения из базы.\nПринимает from, to и sort как список списаний.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "balance"
+                ],
+                "summary": "Export withdrawals as CSV",
+                "responses": {
+                    "200": {
+                        "description": "CSV с заголовком, без списаний только заголовок"
+                    },
+                    "400": {
+                        "description": "неверные параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/user/ws": {
+            "get": {
+                "description": "те же события, что /api/user/events, в сообщениях {\"id\", \"type\", \"data\"}. Сервер шлет ping раз в WS_PING_INTERVAL, при отставании клиента закрывает соединение с кодом 1013",
+                "tags": [
+                    "events"
+                ],
+                "summary": "User events over WebSocket",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "идентификатор последнего полученного события",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "соединение установлено",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tWSMessage"
+                        }
+                    },
+                    "400": {
+                        "description": "неверный last_event_id или запрос не WebSocket"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/balance": {
+            "get": {
+                "description": "get user balance, v2: суммы строками",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v2"
+                ],
+                "summary": "User balance",
+                "responses": {
+                    "200": {
+                        "description": "баланс пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tBalanceV2"
+                        }
+                    },
+                    "304": {
+                        "description": "данные не менялись, ETag совпал с If-None-Match"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/balance/withdraw": {
+            "post": {
+                "description": "Withdraw from user balans, v2: сумма строкой",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v2"
+                ],
+                "summary": "Withdraw from user balans",
+                "parameters": [
+                    {
+                        "description": "withdraw",
+                        "name": "withdraw",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tWithdrawV2"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "успешная обработка запроса"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "402": {
+                        "description": "на счету недостаточно средств"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json"
+                    },
+                    "422": {
+                        "description": "неверный номер заказа или сумма"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/login": {
+            "post": {
+                "description": "authorization",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login user",
+                "parameters": [
+                    {
+                        "description": "auth",
+                        "name": "auth",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tAuthorization"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "пользователь успешно аутентифицирован"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "неверная пара логин/пароль"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json"
+                    },
+                    "423": {
+                        "description": "вход временно заблокирован после серии неудачных попыток"
+                    },
+                    "429": {
+                        "description": "слишком частые попытки входа, повторить через Retry-After"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/login/2fa": {
+            "post": {
+                "description": "finish login with TOTP code or recovery code after ` + "`" + `POST /api/user/login` + "`" + ` answered 202",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login second step",
+                "parameters": [
+                    {
+                        "description": "code",
+                        "name": "code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tTOTPCode"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "пользователь успешно аутентифицирован"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "неверный код или истек срок ввода кода"
+                    },
+                    "423": {
+                        "description": "ввод кода временно заблокирован после серии неудачных попыток"
+                    },
+                    "429": {
+                        "description": "слишком частые попытки, повторить через Retry-After"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/orders": {
+            "get": {
+                "description": "get user orders, v2: пустой список вместо 204, суммы строками, время загрузки и обработки, id заказа",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v2"
+                ],
+                "summary": "List user orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "размер страницы, от 1 до 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "курсор следующей страницы из заголовка X-Next-Cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "порядок по времени загрузки: desc (по умолчанию) или asc",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "загруженные не раньше, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "загруженные раньше, RFC3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "статусы через запятую: NEW,PROCESSING,INVALID,PROCESSED",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "заказы пользователя, возможно пустой список",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.tOrderV2"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "данные не менялись, ETag совпал с If-None-Match"
+                    },
+                    "400": {
+                        "description": "неверные параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            },
+            "post": {
+                "description": "upload order",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "upload user order",
+                "parameters": [
+                    {
+                        "description": "order_id",
+                        "name": "order_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "integer"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "номер заказа уже был загружен этим пользователем"
+                    },
+                    "202": {
+                        "description": "новый номер заказа принят в обработку"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "409": {
+                        "description": "номер заказа уже был загружен другим пользователем"
+                    },
+                    "415": {
+                        "description": "тело запроса не text/plain"
+                    },
+                    "422": {
+                        "description": "неверный формат номера заказа"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/orders/batch": {
+            "post": {
+                "description": "upload orders batch: json array of numbers or text/plain with a number per line",
+                "consumes": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "upload user orders batch",
+                "parameters": [
+                    {
+                        "description": "номера заказов, не больше 1000",
+                        "name": "orders",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "результат по каждому номеру: accepted, already_uploaded, owned_by_another_user, invalid",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.tOrderUpload"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "неверный формат запроса, пустой пакет или больше 1000 номеров"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json и не text/plain"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/orders/{number}": {
+            "get": {
+                "description": "get user order with status history, v2",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v2"
+                ],
+                "summary": "User order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "номер заказа",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "заказ и история статусов",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tOrderDetailsV2"
+                        }
+                    },
+                    "304": {
+                        "description": "данные не менялись, ETag совпал с If-None-Match"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "404": {
+                        "description": "заказ не найден"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/register": {
+            "post": {
+                "description": "registration user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Register user",
+                "parameters": [
+                    {
+                        "description": "registration",
+                        "name": "registration",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/rest.tRegistration"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "пользователь успешно зарегистрирован и аутентифицирован"
+                    },
+                    "400": {
+                        "description": "неверный формат запроса"
+                    },
+                    "409": {
+                        "description": "логин уже занят"
+                    },
+                    "415": {
+                        "description": "тело запроса не application/json"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/api/v2/user/withdrawals": {
+            "get": {
+                "description": "get user withdrawals, v2: пустой список вместо 204, суммы строками, id списания",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v2"
+                ],
+                "summary": "User withdrawals",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "размер страницы, от 1 до 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "курсор следующей страницы из заголовка X-Next-Cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "порядок по времени списания: desc (по умолчанию) или asc",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "списания не раньше, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "списания раньше, RFC3339",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "списания пользователя, возможно пустой список",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/rest.tWithdrawalV2"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "данные не менялись, ETag совпал с If-None-Match"
+                    },
+                    "400": {
+                        "description": "неверные параметры списка"
+                    },
+                    "401": {
+                        "description": "пользователь не авторизован"
+                    },
+                    "500": {
+                        "description": "внутренняя ошибка сервера"
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "процесс запущен и отвечает на запросы, зависимости не проверяются",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка жизнеспособности",
+                "responses": {
+                    "200": {
+                        "description": "сервис работает",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tHealth"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "проверяет соединение с базой данных и актуальность миграций, сообщает состояние circuit breaker\nсистемы расчета начислений. Во время остановки сервиса отвечает 503, чтобы балансировщик снял трафик.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка готовности",
+                "responses": {
+                    "200": {
+                        "description": "сервис готов принимать запросы",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tReadiness"
+                        }
+                    },
+                    "503": {
+                        "description": "сервис не готов, причина в проверках",
+                        "schema": {
+                            "$ref": "#/definitions/rest.tReadiness"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "jwt.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                }
+            }
+        },
+        "jwt.JWKSet": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jwt.JWK"
+                    }
+                }
+            }
+        },
+        "model.MerchantScope": {
+            "type": "string",
+            "enum": [
+                "orders:write",
+                "balance:withdraw"
+            ],
+            "x-enum-varnames": [
+                "ScopeOrdersWrite",
+                "ScopeBalanceWithdraw"
+            ]
+        },
+        "model.OrderStatus": {
+            "type": "string",
+            "enum": [
+                "NEW",
+                "PROCESSING",
+                "INVALID",
+                "PROCESSED"
+            ],
+            "x-enum-varnames": [
+                "OrderStateNew",
+                "OrderStateProcessing",
+                "OrderStateInvalid",
+                "OrderStateProcessed"
+            ]
+        },
+        "model.OrderUploadResult": {
+            "type": "string",
+            "enum": [
+                "accepted",
+                "already_uploaded",
+                "owned_by_another_user",
+                "invalid"
+            ],
+            "x-enum-varnames": [
+                "OrderUploadAccepted",
+                "OrderUploadAlreadyUploaded",
+                "OrderUploadOwnedByAnotherUser",
+                "OrderUploadInvalid"
+            ]
+        },
+        "model.UserRole": {
+            "type": "string",
+            "enum": [
+                "USER",
+                "SUPPORT",
+                "ADMIN"
+            ],
+            "x-enum-varnames": [
+                "RoleUser",
+                "RoleSupport",
+                "RoleAdmin"
+            ]
+        },
+        "rest.tAccrualHealth": {
+            "type": "object",
+            "properties": {
+                "circuit_breaker": {
+                    "type": "string",
+                    "example": "closed"
+                }
+            }
+        },
+        "rest.tAdminUser": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "login": {
+                    "type": "string"
+                },
+                "role": {
+                    "$ref": "#/definitions/model.UserRole"
+                }
+            }
+        },
+        "rest.tAuthorization": {
+            "type": "object",
+            "properties": {
+                "login": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tBalanceAdjustment": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tBalanceByUser": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "type": "number"
+                },
+                "withdrawn": {
+                    "type": "number"
+                }
+            }
+        },
+        "rest.tBalanceV2": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "type": "string"
+                },
+                "withdrawn": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tChangePassword": {
+            "type": "object",
+            "properties": {
+                "new_password": {
+                    "type": "string"
+                },
+                "old_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tDeleteUser": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tEvent": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "$ref": "#/definitions/rest.tBalanceByUser"
+                },
+                "order": {
+                    "$ref": "#/definitions/rest.tOrderByUser"
+                }
+            }
+        },
+        "rest.tHealth": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "rest.tHealthCheck": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "timeout"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "rest.tMerchant": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tMerchantKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "rest.tMerchantKeyScopes": {
+            "type": "object",
+            "properties": {
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.MerchantScope"
+                    }
+                }
+            }
+        },
+        "rest.tMerchantName": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tOrderByUser": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "number"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.OrderStatus"
+                },
+                "uploaded_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tOrderDetails": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "number"
+                },
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.tOrderStatusChange"
+                    }
+                },
+                "number": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.OrderStatus"
+                },
+                "uploaded_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tOrderDetailsV2": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "string"
+                },
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rest.tOrderStatusChangeV2"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.OrderStatus"
+                },
+                "uploaded_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tOrderStatusChange": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "number"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.OrderStatus"
+                }
+            }
+        },
+        "rest.tOrderStatusChangeV2": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "string"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.OrderStatus"
+                }
+            }
+        },
+        "rest.tOrderUpload": {
+            "type": "object",
+            "properties": {
+                "number": {
+                    "type": "string"
+                },
+                "result": {
+                    "$ref": "#/definitions/model.OrderUploadResult"
+                }
+            }
+        },
+        "rest.tOrderV2": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.OrderStatus"
+                },
+                "uploaded_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tReadiness": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "$ref": "#/definitions/rest.tAccrualHealth"
+                },
+                "database": {
+                    "$ref": "#/definitions/rest.tHealthCheck"
+                },
+                "migrations": {
+                    "$ref": "#/definitions/rest.tHealthCheck"
+                },
+                "shutting_down": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ready"
+                }
+            }
+        },
+        "rest.tRecoveryCodes": {
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "rest.tRegistration": {
+            "type": "object",
+            "properties": {
+                "login": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tTOTPCode": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tTOTPEnrollment": {
+            "type": "object",
+            "properties": {
+                "secret": {
+                    "type": "string"
+                },
+                "uri": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tUserRole": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "$ref": "#/definitions/model.UserRole"
+                }
+            }
+        },
+        "rest.tWSMessage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/rest.tEvent"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tWithdraw": {
+            "type": "object",
+            "properties": {
+                "order": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "number"
                 }
             }
         },
@@ -323,6 +2802,34 @@ const docTemplate = `{
                     "type": "number"
                 }
             }
+        },
+        "rest.tWithdrawV2": {
+            "type": "object",
+            "properties": {
+                "order": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "string"
+                }
+            }
+        },
+        "rest.tWithdrawalV2": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "sum": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
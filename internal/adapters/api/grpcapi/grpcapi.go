@@ -0,0 +1,143 @@
+// Package grpcapi gRPC сервер пользовательского API «Гофермарт» для внутренних сервисов.
+// Работает на том же ядре, что и rest.Server, и принимает те же токены пользователей.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/playmixer/gophermart/internal/adapters/api/grpcapi/pb"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"github.com/playmixer/gophermart/pkg/jwt"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// Config адрес gRPC сервера, пустой адрес отключает сервер. TokenTTL срок действия выпускаемых токенов.
+type Config struct {
+	Address  string        `env:"GRPC_ADDRESS" envDefault:"localhost:3200"`
+	TokenTTL time.Duration `env:"GRPC_TOKEN_TTL" envDefault:"24h"`
+}
+
+var defaultTokenTTL = time.Hour * 24
+
+type gophermartI interface {
+	Register(ctx context.Context, login, password string) error
+	Authorization(ctx context.Context, login, password, ip string) (model.User, error)
+	AuthorizationSecondFactor(ctx context.Context, userID uint, code, ip string) (model.User, error)
+	GetUser(ctx context.Context, userID uint) (model.User, error)
+	UploadOrder(ctx context.Context, userID uint, orderNumber string) error
+	GetUserOrders(ctx context.Context, userID uint, query model.ListQuery) ([]*model.Order, *model.ListCursor, error)
+	GetUserBalance(ctx context.Context, userID uint) (model.Balance, error)
+	WithdrawFromBalanceUser(ctx context.Context, userID uint, order string, sum float32) error
+	GetWithdrawalsByUser(
+		ctx context.Context, userID uint, query model.ListQuery,
+	) ([]*model.WithdrawBalance, *model.ListCursor, error)
+}
+
+type Server struct {
+	pb.UnimplementedGophermartServer
+	srv      *grpc.Server
+	log      *zap.Logger
+	service  gophermartI
+	jwt      *jwt.JWT
+	address  string
+	tokenTTL time.Duration
+}
+
+type Option func(*Server)
+
+func Logger(log *zap.Logger) Option {
+	return func(s *Server) {
+		s.log = log
+	}
+}
+
+func SetAddress(address string) Option {
+	return func(s *Server) {
+		s.address = address
+	}
+}
+
+func SetSecretKey(key []byte) Option {
+	return func(s *Server) {
+		s.jwt = jwt.New(key)
+	}
+}
+
+// SetKeySet задает набор ключей подписи токенов, общий с rest.Server.
+func SetKeySet(keys *jwt.KeySet) Option {
+	return func(s *Server) {
+		s.jwt = jwt.NewWithKeySet(keys)
+	}
+}
+
+// SetTokenTTL задает срок действия токенов, выпускаемых при регистрации и входе.
+func SetTokenTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		if ttl > 0 {
+			s.tokenTTL = ttl
+		}
+	}
+}
+
+func New(service gophermartI, options ...Option) *Server {
+	s := &Server{
+		log:      zap.NewNop(),
+		service:  service,
+		jwt:      jwt.New(nil),
+		tokenTTL: defaultTokenTTL,
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	s.srv = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		s.requestIDInterceptor,
+		s.loggerInterceptor,
+		s.authInterceptor,
+	))
+	pb.RegisterGophermartServer(s.srv, s)
+
+	return s
+}
+
+// Run слушает адрес из SetAddress и блокируется до остановки сервера.
+func (s *Server) Run() error {
+	lis, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed listen %s: %w", s.address, err)
+	}
+	return s.Serve(lis)
+}
+
+// Serve обслуживает соединения lis до остановки сервера.
+func (s *Server) Serve(lis net.Listener) error {
+	if err := s.srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("server stopped with error: %w", err)
+	}
+	return nil
+}
+
+// Shutdown перестает принимать соединения и ждет завершения текущих вызовов.
+// Если ctx завершится раньше, оставшиеся вызовы прерываются.
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.srv.Stop()
+		<-done
+		return fmt.Errorf("failed graceful stop: %w", ctx.Err())
+	}
+}
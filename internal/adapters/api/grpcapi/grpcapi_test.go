@@ -0,0 +1,196 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/playmixer/gophermart/internal/adapters/api/grpcapi"
+	"github.com/playmixer/gophermart/internal/adapters/api/grpcapi/pb"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"github.com/playmixer/gophermart/internal/core/config"
+	"github.com/playmixer/gophermart/internal/core/gophermart"
+	"github.com/playmixer/gophermart/internal/mocks/store"
+	"github.com/playmixer/gophermart/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient запускает сервер на соединении в памяти и возвращает клиента к нему.
+func newClient(t *testing.T, server *grpcapi.Server) pb.GophermartClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(func() { _ = server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewGophermartClient(conn)
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestServer_auth(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	hashPass, err := gophermart.NewPasswordHasher(cfg.Gophermart.Password).Hash("pass")
+	require.NoError(t, err)
+
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().RegisterUser(gomock.Any(), "user", gomock.Any()).Return(nil)
+	storeMock.EXPECT().RegisterUser(gomock.Any(), "taken", gomock.Any()).Return(errstore.ErrLoginNotUnique)
+	storeMock.EXPECT().
+		GetUserByLogin(gomock.Any(), "user").
+		Return(model.User{ID: 1, Login: "user", PasswordHash: hashPass, TokenVersion: 2}, nil).
+		Times(2)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		Return(model.User{ID: 1, Login: "user", TokenVersion: 2}, nil).
+		AnyTimes()
+	storeMock.EXPECT().GetUserBalance(gomock.Any(), uint(1)).Return(model.Balance{Current: 10.1, Withdrawn: 3}, nil)
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	client := newClient(t, grpcapi.New(mart, grpcapi.SetSecretKey([]byte(cfg.Rest.Secret))))
+
+	_, err = client.Register(ctx, &pb.RegisterRequest{Login: "taken", Password: "pass"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	registered, err := client.Register(ctx, &pb.RegisterRequest{Login: "user", Password: "pass"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, registered.GetToken())
+
+	_, err = client.Login(ctx, &pb.LoginRequest{Login: "user", Password: "wrong"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.GetBalance(ctx, &pb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// токен старой версии отозван
+	tokens := jwt.New([]byte(cfg.Rest.Secret))
+	staleToken, err := tokens.CreateExpiring(
+		map[string]string{"UserID": "1", "TokenVersion": "1"}, time.Now().Add(time.Hour),
+	)
+	require.NoError(t, err)
+	_, err = client.GetBalance(withToken(ctx, staleToken), &pb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// просроченный токен и токен без срока действия не принимаются
+	expiredToken, err := tokens.CreateExpiring(
+		map[string]string{"UserID": "1", "TokenVersion": "2"}, time.Now().Add(-time.Minute),
+	)
+	require.NoError(t, err)
+	_, err = client.GetBalance(withToken(ctx, expiredToken), &pb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	unlimitedToken, err := tokens.CreateClaims(map[string]string{"UserID": "1", "TokenVersion": "2"})
+	require.NoError(t, err)
+	_, err = client.GetBalance(withToken(ctx, unlimitedToken), &pb.GetBalanceRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	var header metadata.MD
+	reqCtx := metadata.AppendToOutgoingContext(withToken(ctx, registered.GetToken()), "x-request-id", "req-42")
+	balance, err := client.GetBalance(reqCtx, &pb.GetBalanceRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.InDelta(t, 10.1, balance.GetCurrent(), 0)
+	assert.InDelta(t, 3, balance.GetWithdrawn(), 0)
+	assert.Equal(t, []string{"req-42"}, header.Get("x-request-id"))
+}
+
+func TestServer_orders(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	uploadedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		Return(model.User{ID: 1}, nil).
+		AnyTimes()
+	storeMock.EXPECT().UploadOrder(gomock.Any(), uint(1), "2377225624", uint(0)).Return(nil)
+	storeMock.EXPECT().
+		UploadOrder(gomock.Any(), uint(1), "12345678903", uint(0)).
+		Return(errstore.ErrOrderWasCreatedByUser)
+	processed := []model.OrderStatus{model.OrderStateProcessed}
+	storeMock.EXPECT().
+		GetUserOrders(gomock.Any(), uint(1), model.ListQuery{Limit: 2, Statuses: processed}).
+		Return([]*model.Order{
+			{ID: 3, Number: "2377225624", Status: model.OrderStateProcessed, Accrual: 500.1, CreatedAt: uploadedAt},
+			{ID: 2, Number: "12345678903", Status: model.OrderStateProcessed, Accrual: 10, CreatedAt: uploadedAt},
+		}, nil)
+	storeMock.EXPECT().GetUserBalance(gomock.Any(), uint(1)).Return(model.Balance{ID: 5, Current: 1}, nil)
+	storeMock.EXPECT().
+		WithdrawFromUserBalance(gomock.Any(), uint(1), "2377225624", float32(100), uint(0)).
+		Return(errstore.ErrBalansNotEnough)
+	storeMock.EXPECT().
+		GetWithdrawalsFromBalance(gomock.Any(), uint(5), model.ListQuery{}).
+		Return([]*model.WithdrawBalance{{OderNumber: "2377225624", Sum: 0.1, CreatedAt: uploadedAt}}, nil)
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	client := newClient(t, grpcapi.New(mart, grpcapi.SetSecretKey([]byte(cfg.Rest.Secret))))
+	token, err := jwt.New([]byte(cfg.Rest.Secret)).
+		CreateExpiring(map[string]string{"UserID": "1"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	ctx = withToken(ctx, token)
+
+	uploaded, err := client.UploadOrder(ctx, &pb.UploadOrderRequest{Number: "2377225624"})
+	require.NoError(t, err)
+	assert.False(t, uploaded.GetAlreadyUploaded())
+	uploaded, err = client.UploadOrder(ctx, &pb.UploadOrderRequest{Number: "12345678903"})
+	require.NoError(t, err)
+	assert.True(t, uploaded.GetAlreadyUploaded())
+	_, err = client.UploadOrder(ctx, &pb.UploadOrderRequest{Number: "123"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	orders, err := client.ListOrders(ctx, &pb.ListOrdersRequest{
+		PageSize: 1,
+		Statuses: []pb.OrderStatus{pb.OrderStatus_ORDER_STATUS_PROCESSED},
+	})
+	require.NoError(t, err)
+	require.Len(t, orders.GetOrders(), 1)
+	order := orders.GetOrders()[0]
+	assert.Equal(t, "2377225624", order.GetNumber())
+	assert.Equal(t, pb.OrderStatus_ORDER_STATUS_PROCESSED, order.GetStatus())
+	assert.InDelta(t, 500.1, order.GetAccrual(), 0)
+	assert.Equal(t, uploadedAt, order.GetUploadedAt().AsTime())
+	assert.NotEmpty(t, orders.GetNextPageToken())
+
+	_, err = client.ListOrders(ctx, &pb.ListOrdersRequest{PageToken: "not a token"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Withdraw(ctx, &pb.WithdrawRequest{Order: "2377225624", Sum: 100})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	withdrawals, err := client.ListWithdrawals(ctx, &pb.ListWithdrawalsRequest{})
+	require.NoError(t, err)
+	require.Len(t, withdrawals.GetWithdrawals(), 1)
+	assert.InDelta(t, 0.1, withdrawals.GetWithdrawals()[0].GetSum(), 0)
+	assert.Empty(t, withdrawals.GetNextPageToken())
+}
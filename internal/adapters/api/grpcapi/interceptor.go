@@ -0,0 +1,179 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/playmixer/gophermart/internal/adapters/api/grpcapi/pb"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"github.com/playmixer/gophermart/pkg/requestid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errUnauthorize = errors.New("unauthorize")
+
+	// имена claims совпадают с rest, поэтому токены REST и gRPC взаимозаменяемы
+	claimUserID       = "UserID"
+	claimTokenVersion = "TokenVersion"
+	claimRole         = "Role"
+
+	authorizationKey = "authorization"
+	requestIDKey     = strings.ToLower(requestid.Header)
+
+	// publicMethods не требуют токена.
+	publicMethods = map[string]bool{
+		pb.Gophermart_Register_FullMethodName: true,
+		pb.Gophermart_Login_FullMethodName:    true,
+	}
+)
+
+type ctxUserKey struct{}
+
+// logger возвращает лог с идентификатором текущего вызова.
+func (s *Server) logger(ctx context.Context) *zap.Logger {
+	return requestid.Logger(ctx, s.log)
+}
+
+// requestIDInterceptor принимает идентификатор вызова из метаданных x-request-id или создает новый
+// и возвращает его в заголовке ответа, как RequestID в rest.
+func (s *Server) requestIDInterceptor(
+	ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	ctx = requestid.NewContext(ctx, id)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id)); err != nil {
+		s.logger(ctx).Debug("failed set request id header", zap.Error(err))
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) loggerInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.logger(ctx).Info(
+		"Call",
+		zap.String("method", info.FullMethod),
+		zap.Duration("duration", time.Since(start)),
+		zap.String("code", status.Code(err).String()),
+	)
+	return resp, err
+}
+
+// authInterceptor проверяет токен из метаданных `authorization: Bearer <token>` так же,
+// как rest проверяет cookie: подпись, пользователя и версию токена.
+func (s *Server) authInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	user, err := s.authenticate(ctx)
+	if err != nil {
+		if !errors.Is(err, errUnauthorize) {
+			s.logger(ctx).Error("failed authenticate user", zap.Error(err))
+			return nil, status.Error(codes.Internal, msgInternal)
+		}
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	return handler(context.WithValue(ctx, ctxUserKey{}, user), req)
+}
+
+func (s *Server) authenticate(ctx context.Context) (model.User, error) {
+	var user model.User
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return user, fmt.Errorf("metadata not found: %w", errUnauthorize)
+	}
+	values := md.Get(authorizationKey)
+	if len(values) == 0 {
+		return user, fmt.Errorf("token not found: %w", errUnauthorize)
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || token == "" {
+		return user, fmt.Errorf("token not bearer: %w", errUnauthorize)
+	}
+
+	claims, err := s.jwt.ExpiringClaims(token)
+	if err != nil {
+		return user, fmt.Errorf("failed verify token: %w %w", err, errUnauthorize)
+	}
+	userID, err := strconv.ParseUint(claims[claimUserID], 10, 32)
+	if err != nil {
+		return user, fmt.Errorf("token without user: %w", errUnauthorize)
+	}
+	var version uint64
+	if versionS, ok := claims[claimTokenVersion]; ok {
+		version, err = strconv.ParseUint(versionS, 10, 32)
+		if err != nil {
+			return user, fmt.Errorf("can't convert token version to uint: %w %w", err, errUnauthorize)
+		}
+	}
+
+	user, err = s.service.GetUser(ctx, uint(userID))
+	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			return user, fmt.Errorf("user of token not found: %w", errUnauthorize)
+		}
+		return user, fmt.Errorf("failed getting user: %w", err)
+	}
+	if uint(version) != user.TokenVersion {
+		return user, fmt.Errorf("token was revoked: %w", errUnauthorize)
+	}
+
+	return user, nil
+}
+
+// createToken выпускает токен пользователя с теми же claims, что и cookie rest, со сроком действия tokenTTL.
+func (s *Server) createToken(user model.User) (string, error) {
+	token, err := s.jwt.CreateExpiring(map[string]string{
+		claimUserID:       strconv.Itoa(int(user.ID)),
+		claimTokenVersion: strconv.Itoa(int(user.TokenVersion)),
+		claimRole:         string(user.Role),
+	}, time.Now().Add(s.tokenTTL))
+	if err != nil {
+		return "", fmt.Errorf("can't create token: %w", err)
+	}
+	return token, nil
+}
+
+func currentUser(ctx context.Context) model.User {
+	user, _ := ctx.Value(ctxUserKey{}).(model.User)
+	return user
+}
+
+// clientIP адрес клиента для защиты входа от перебора.
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
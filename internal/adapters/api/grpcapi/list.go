@@ -0,0 +1,71 @@
+package grpcapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/playmixer/gophermart/internal/adapters/api/grpcapi/pb"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+)
+
+var (
+	listPageSizeMax = int32(1000)
+
+	orderStatuses = map[pb.OrderStatus]model.OrderStatus{
+		pb.OrderStatus_ORDER_STATUS_NEW:        model.OrderStateNew,
+		pb.OrderStatus_ORDER_STATUS_PROCESSING: model.OrderStateProcessing,
+		pb.OrderStatus_ORDER_STATUS_INVALID:    model.OrderStateInvalid,
+		pb.OrderStatus_ORDER_STATUS_PROCESSED:  model.OrderStateProcessed,
+	}
+)
+
+// listQuery собирает параметры выборки из запроса списка. page_token совпадает по формату
+// с курсором rest, поэтому страницы можно продолжать между API.
+func listQuery(pageSize int32, pageToken string, ascending bool) (model.ListQuery, error) {
+	query := model.ListQuery{Asc: ascending}
+	if pageSize < 0 || pageSize > listPageSizeMax {
+		return query, fmt.Errorf("%w: page_size must be in [0, %d]", errListQueryNotValid, listPageSizeMax)
+	}
+	query.Limit = int(pageSize)
+	if pageToken != "" {
+		cursor, err := decodePageToken(pageToken)
+		if err != nil {
+			return query, err
+		}
+		query.After = cursor
+	}
+	return query, nil
+}
+
+func encodePageToken(cursor *model.ListCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	raw := strconv.FormatInt(cursor.CreatedAt.UnixNano(), 10) + "." + strconv.FormatUint(uint64(cursor.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePageToken(s string) (*model.ListCursor, error) {
+	errToken := fmt.Errorf("%w: page_token not valid", errListQueryNotValid)
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errToken
+	}
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, errToken
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errToken
+	}
+	i, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return nil, errToken
+	}
+
+	return &model.ListCursor{CreatedAt: time.Unix(0, n), ID: uint(i)}, nil
+}
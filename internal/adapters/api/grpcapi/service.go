@@ -0,0 +1,155 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/playmixer/gophermart/internal/adapters/api/grpcapi/pb"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"github.com/playmixer/gophermart/internal/core/gophermart"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.TokenResponse, error) {
+	if err := s.service.Register(ctx, req.GetLogin(), req.GetPassword()); err != nil {
+		return nil, s.toStatus(ctx, "failed register user", err)
+	}
+	return s.Login(ctx, &pb.LoginRequest{Login: req.GetLogin(), Password: req.GetPassword()})
+}
+
+func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.TokenResponse, error) {
+	ip := clientIP(ctx)
+	user, err := s.service.Authorization(ctx, req.GetLogin(), req.GetPassword(), ip)
+	if errors.Is(err, gophermart.ErrSecondFactorRequired) && req.GetTotpCode() != "" {
+		user, err = s.service.AuthorizationSecondFactor(ctx, user.ID, req.GetTotpCode(), ip)
+	}
+	if err != nil {
+		if errors.Is(err, gophermart.ErrPasswordNotEquale) || errors.Is(err, errstore.ErrNotFoundData) {
+			return nil, status.Error(codes.Unauthenticated, "credentials not valid")
+		}
+		return nil, s.toStatus(ctx, "authorization failed", err)
+	}
+
+	token, err := s.createToken(user)
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed create token", err)
+	}
+	return &pb.TokenResponse{Token: token}, nil
+}
+
+func (s *Server) UploadOrder(ctx context.Context, req *pb.UploadOrderRequest) (*pb.UploadOrderResponse, error) {
+	err := s.service.UploadOrder(ctx, currentUser(ctx).ID, req.GetNumber())
+	if errors.Is(err, errstore.ErrOrderWasCreatedByUser) {
+		return &pb.UploadOrderResponse{AlreadyUploaded: true}, nil
+	}
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed upload order number", err)
+	}
+	return &pb.UploadOrderResponse{}, nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	query, err := listQuery(req.GetPageSize(), req.GetPageToken(), req.GetAscending())
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed parse list query", err)
+	}
+	for _, item := range req.GetStatuses() {
+		orderStatus, ok := orderStatuses[item]
+		if !ok {
+			return nil, s.toStatus(ctx, "failed parse list query",
+				fmt.Errorf("%w: unknown status `%s`", errListQueryNotValid, item))
+		}
+		query.Statuses = append(query.Statuses, orderStatus)
+	}
+
+	orders, next, err := s.service.GetUserOrders(ctx, currentUser(ctx).ID, query)
+	if err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		return nil, s.toStatus(ctx, "failed get user orders", err)
+	}
+
+	resp := &pb.ListOrdersResponse{
+		Orders:        make([]*pb.Order, 0, len(orders)),
+		NextPageToken: encodePageToken(next),
+	}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, newOrder(order))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetBalance(ctx context.Context, _ *pb.GetBalanceRequest) (*pb.GetBalanceResponse, error) {
+	balance, err := s.service.GetUserBalance(ctx, currentUser(ctx).ID)
+	if err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		return nil, s.toStatus(ctx, "failed get user balance", err)
+	}
+	return &pb.GetBalanceResponse{
+		Current:   roundSum(balance.Current),
+		Withdrawn: roundSum(balance.Withdrawn),
+	}, nil
+}
+
+func (s *Server) Withdraw(ctx context.Context, req *pb.WithdrawRequest) (*pb.WithdrawResponse, error) {
+	if req.GetSum() > math.MaxFloat32 {
+		return nil, status.Error(codes.InvalidArgument, "sum is too large")
+	}
+	err := s.service.WithdrawFromBalanceUser(ctx, currentUser(ctx).ID, req.GetOrder(), float32(req.GetSum()))
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed withdraw from balance", err)
+	}
+	return &pb.WithdrawResponse{}, nil
+}
+
+func (s *Server) ListWithdrawals(
+	ctx context.Context, req *pb.ListWithdrawalsRequest,
+) (*pb.ListWithdrawalsResponse, error) {
+	query, err := listQuery(req.GetPageSize(), req.GetPageToken(), req.GetAscending())
+	if err != nil {
+		return nil, s.toStatus(ctx, "failed parse list query", err)
+	}
+
+	withdrawals, next, err := s.service.GetWithdrawalsByUser(ctx, currentUser(ctx).ID, query)
+	if err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		return nil, s.toStatus(ctx, "failed get user withdrawals", err)
+	}
+
+	resp := &pb.ListWithdrawalsResponse{
+		Withdrawals:   make([]*pb.Withdrawal, 0, len(withdrawals)),
+		NextPageToken: encodePageToken(next),
+	}
+	for _, w := range withdrawals {
+		resp.Withdrawals = append(resp.Withdrawals, &pb.Withdrawal{
+			Order:       w.OderNumber,
+			Sum:         roundSum(w.Sum),
+			ProcessedAt: timestamppb.New(w.CreatedAt),
+		})
+	}
+	return resp, nil
+}
+
+func newOrder(order *model.Order) *pb.Order {
+	o := &pb.Order{
+		Id:         uint64(order.ID),
+		Number:     order.Number,
+		UploadedAt: timestamppb.New(order.CreatedAt),
+	}
+	for pbStatus, orderStatus := range orderStatuses {
+		if orderStatus == order.Status {
+			o.Status = pbStatus
+		}
+	}
+	if order.Status == model.OrderStateProcessed {
+		accrual := roundSum(order.Accrual)
+		o.Accrual = &accrual
+	}
+	return o
+}
+
+// roundSum округляет сумму до копеек, чтобы float32 из хранилища не давал хвостов вроде 0.10000000149.
+func roundSum(sum float32) float64 {
+	return math.Round(float64(sum)*100) / 100
+}
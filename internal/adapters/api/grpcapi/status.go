@@ -0,0 +1,61 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/core/gophermart"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errListQueryNotValid = errors.New("list query not valid")
+
+	msgInternal = "internal error"
+)
+
+type statusCode struct {
+	err     error
+	message string
+	code    codes.Code
+	detail  bool
+}
+
+// statusCodes сопоставляет ошибки сервиса и хранилища с кодами gRPC, как problemTypes в rest.
+// Ошибки проверяются по порядку через errors.Is, для ошибок с detail клиенту передается текст ошибки.
+var statusCodes = []statusCode{
+	{err: errListQueryNotValid, code: codes.InvalidArgument, detail: true},
+	{err: errstore.ErrLoginNotUnique, code: codes.AlreadyExists, message: "login not unique"},
+	{err: errstore.ErrNotFoundData, code: codes.NotFound, message: "not found"},
+	{err: errstore.ErrOrderWasCreatedAnotherUser, code: codes.AlreadyExists,
+		message: "order was uploaded by another user"},
+	{err: errstore.ErrBalansNotEnough, code: codes.FailedPrecondition, message: "balance not enough"},
+	{err: gophermart.ErrLoginNotValid, code: codes.InvalidArgument, message: "login not valid"},
+	{err: gophermart.ErrPasswordNotValid, code: codes.InvalidArgument, message: "password not valid"},
+	{err: gophermart.ErrOrderNumberNotValid, code: codes.InvalidArgument, message: "order number not valid"},
+	{err: gophermart.ErrSumNotValid, code: codes.InvalidArgument, message: "sum must be greater than zero"},
+	{err: gophermart.ErrLoginThrottled, code: codes.ResourceExhausted, detail: true},
+	{err: gophermart.ErrLoginLocked, code: codes.ResourceExhausted, detail: true},
+	{err: gophermart.ErrSecondFactorRequired, code: codes.FailedPrecondition,
+		message: "second factor required, repeat login with totp_code"},
+	{err: gophermart.ErrTOTPCodeNotValid, code: codes.Unauthenticated, message: "totp code not valid"},
+}
+
+// toStatus переводит ошибку в статус gRPC. Неизвестные ошибки пишутся в лог
+// и отдаются клиенту как INTERNAL без подробностей.
+func (s *Server) toStatus(ctx context.Context, msg string, err error) error {
+	for _, item := range statusCodes {
+		if errors.Is(err, item.err) {
+			message := item.message
+			if item.detail {
+				message = err.Error()
+			}
+			return status.Error(item.code, message)
+		}
+	}
+	s.logger(ctx).Error(msg, zap.Error(err))
+	return status.Error(codes.Internal, msgInternal)
+}
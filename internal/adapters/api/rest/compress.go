@@ -1,7 +1,6 @@
 package rest
 
 import (
-	"bytes"
 	"compress/gzip"
 	"errors"
 	"fmt"
@@ -10,35 +9,69 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	errBodyTooLarge = errors.New("request body too large")
+)
+
+// limitReader отдает из r не больше limit байт. Тело длиннее limit дочитывается до первого лишнего байта,
+// после чего чтение возвращает errBodyTooLarge, так что большое тело не читается целиком.
+type limitReader struct {
+	r         io.Reader
+	name      string
+	limit     int64
+	remaining int64
+}
+
+func newLimitReader(r io.Reader, name string, limit int64) *limitReader {
+	return &limitReader{r: r, name: name, limit: limit, remaining: limit}
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > lr.remaining+1 {
+		p = p[:lr.remaining+1]
+	}
+	n, err := lr.r.Read(p)
+	if int64(n) > lr.remaining {
+		n = int(lr.remaining)
+		lr.remaining = 0
+		return n, fmt.Errorf("%w: %s body exceeds %d bytes", errBodyTooLarge, lr.name, lr.limit)
+	}
+	lr.remaining -= int64(n)
+	return n, err
+}
+
+// limitReadCloser ограниченное тело запроса, закрывается исходное тело.
+type limitReadCloser struct {
+	*limitReader
+	io.Closer
+}
+
+// GzipReader распаковывает тело запроса по мере чтения. Распакованные данные ограничены maxSize,
+// сжатое тело ограничивается отдельно до распаковки.
 type GzipReader struct {
 	r  io.ReadCloser
 	gz *gzip.Reader
+	lr *limitReader
 }
 
-func NewGzipReader(r io.ReadCloser) (*GzipReader, error) {
-	gzbody, err := io.ReadAll(r)
-	if err != nil {
-		return nil, fmt.Errorf("failed reading request body %w", err)
-	}
-	defer func() { _ = r.Close() }()
-
-	buf := bytes.NewReader(gzbody)
-	zr, err := gzip.NewReader(buf)
+func NewGzipReader(r io.ReadCloser, maxSize int64) (*GzipReader, error) {
+	zr, err := gzip.NewReader(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed reading gzip body %w", err)
 	}
 	return &GzipReader{
 		r:  r,
 		gz: zr,
+		lr: newLimitReader(zr, "decompressed", maxSize),
 	}, nil
 }
 
 func (gr GzipReader) Read(p []byte) (n int, err error) {
-	n, err = gr.gz.Read(p)
-	if err != nil && !errors.Is(err, io.EOF) {
-		return n, fmt.Errorf("failed reading []byte %w", err)
+	n, err = gr.lr.Read(p)
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, errBodyTooLarge) {
+		return
 	}
-	return
+	return n, fmt.Errorf("%w: body is not valid gzip: %w", errRequestNotValid, err)
 }
 
 func (gr *GzipReader) Close() (err error) {
@@ -79,3 +112,9 @@ func (gw *gzipWriter) WriteString(s string) (n int, err error) {
 	}
 	return
 }
+
+// Flush отправляет клиенту уже сжатые данные, нужен для потоковых ответов.
+func (gw *gzipWriter) Flush() {
+	_ = gw.writer.Flush()
+	gw.ResponseWriter.Flush()
+}
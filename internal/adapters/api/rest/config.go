@@ -1,6 +1,92 @@
 package rest
 
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
 type Config struct {
-	Address string `env:"RUN_ADDRESS" envDefault:"localhost:8080"`
-	Secret  string `env:"SECRET_KEY" envDefault:"secret_key"`
+	Address         string        `env:"RUN_ADDRESS" envDefault:"localhost:8080"`
+	Secret          string        `env:"SECRET_KEY" envDefault:"secret_key"`
+	JWTKeys         string        `env:"JWT_KEYS"`
+	JWTActiveKey    string        `env:"JWT_ACTIVE_KEY"`
+	JWTKeysDir      string        `env:"JWT_KEYS_DIR"`
+	JWTPEMKeys      string        `env:"JWT_PEM_KEYS"`
+	TrustedProxies  []string      `env:"TRUSTED_PROXIES"`
+	JWTKeysReload   time.Duration `env:"JWT_KEYS_RELOAD_INTERVAL" envDefault:"1m"`
+	EventsKeepAlive time.Duration `env:"EVENTS_KEEP_ALIVE" envDefault:"15s"`
+	Cookie          CookieConfig
+	WebSocket       WebSocketConfig
+	RateLimit       RateLimitConfig
+	Health          HealthConfig
+	Body            BodyConfig
+}
+
+// CookieConfig атрибуты cookie с токеном пользователя. SameSite принимает значения lax, strict или none.
+// SecondFactorMaxAge время, за которое нужно ввести код двухфакторной аутентификации после пароля.
+type CookieConfig struct {
+	Domain             string        `env:"COOKIE_DOMAIN"`
+	SameSite           string        `env:"COOKIE_SAME_SITE" envDefault:"strict"`
+	MaxAge             time.Duration `env:"COOKIE_MAX_AGE" envDefault:"24h"`
+	SecondFactorMaxAge time.Duration `env:"COOKIE_2FA_MAX_AGE" envDefault:"5m"`
+	HTTPOnly           bool          `env:"COOKIE_HTTP_ONLY" envDefault:"true"`
+	Secure             bool          `env:"COOKIE_SECURE" envDefault:"true"`
+}
+
+// WebSocketConfig настройки /api/user/ws. Клиент, не ответивший на ping за два интервала, отключается,
+// WriteTimeout ограничивает запись одного сообщения.
+type WebSocketConfig struct {
+	PingInterval time.Duration `env:"WS_PING_INTERVAL" envDefault:"30s"`
+	WriteTimeout time.Duration `env:"WS_WRITE_TIMEOUT" envDefault:"10s"`
+}
+
+// HealthConfig настройки /readyz. ReadyTimeout ограничивает проверку базы данных, DrainDelay время
+// между переходом в not-ready и остановкой сервера, за которое балансировщик перестает направлять запросы.
+type HealthConfig struct {
+	ReadyTimeout time.Duration `env:"READY_TIMEOUT" envDefault:"2s"`
+	DrainDelay   time.Duration `env:"SHUTDOWN_DRAIN_DELAY" envDefault:"5s"`
+}
+
+// BodyConfig ограничения тела запроса. MaxSize ограничивает тело в том виде, как оно передано, в том числе сжатое,
+// MaxDecompressedSize тело после распаковки gzip. При превышении запрос отклоняется с 413.
+type BodyConfig struct {
+	MaxSize             int64 `env:"MAX_BODY_SIZE" envDefault:"1048576"`
+	MaxDecompressedSize int64 `env:"MAX_DECOMPRESSED_BODY_SIZE" envDefault:"10485760"`
+}
+
+var defaultBodyConfig = BodyConfig{
+	MaxSize:             1 << 20,
+	MaxDecompressedSize: 10 << 20,
+}
+
+var defaultHealthConfig = HealthConfig{
+	ReadyTimeout: time.Second * 2,
+}
+
+var defaultWebSocketConfig = WebSocketConfig{
+	PingInterval: time.Second * 30,
+	WriteTimeout: time.Second * 10,
+}
+
+var defaultCookieConfig = CookieConfig{
+	SameSite:           "strict",
+	MaxAge:             time.Hour * 24,
+	SecondFactorMaxAge: time.Minute * 5,
+	HTTPOnly:           true,
+	Secure:             true,
+}
+
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "", "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return http.SameSiteDefaultMode, fmt.Errorf("unknown SameSite value `%s`", value)
+	}
 }
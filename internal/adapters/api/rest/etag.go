@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	etagHeader        = "ETag"
+	ifNoneMatchHeader = "If-None-Match"
+	etagLength        = 16
+	etagVary          = "Accept-Encoding, Authorization, Cookie"
+)
+
+// ETag отвечает 304 Not Modified, если данные пользователя не менялись с ответа, ETag которого
+// клиент прислал в If-None-Match. ETag строится по версии данных пользователя, которая растет
+// в той же транзакции, что меняет заказы или баланс, поэтому ответ не нужно собирать заранее.
+// В ETag также входят адрес запроса с параметрами и сжатие ответа, разные представления
+// одних данных получают разные ETag, а Vary не дает общим кешам отдать ответ другому
+// пользователю или в другом сжатии. Используется после Authentication.
+func (s *Server) ETag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%s:%s",
+			user.ID, user.DataVersion, c.Request.URL.RequestURI(), c.GetHeader("Accept-Encoding"))))
+		etag := `"` + hex.EncodeToString(hash[:])[:etagLength] + `"`
+
+		c.Header(etagHeader, etag)
+		c.Header("Cache-Control", "private, no-cache")
+		c.Writer.Header().Add("Vary", etagVary)
+		if matchETag(c.GetHeader(ifNoneMatchHeader), etag) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+		c.Next()
+	}
+}
+
+// matchETag сравнивает ETag со списком из If-None-Match слабым сравнением, как требует RFC 9110.
+func matchETag(ifNoneMatch, etag string) bool {
+	for _, item := range strings.Split(ifNoneMatch, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" || strings.TrimPrefix(item, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
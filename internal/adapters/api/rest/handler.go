@@ -1,15 +1,11 @@
 package rest
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
-	"sort"
 
 	"github.com/gin-gonic/gin"
 	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
-	"github.com/playmixer/gophermart/internal/adapters/store/model"
-	"github.com/playmixer/gophermart/internal/core/gophermart"
 	"go.uber.org/zap"
 )
 
@@ -27,60 +23,29 @@ var (
 //	@Success		200				"пользователь успешно зарегистрирован и аутентифицирован"
 //	@failure		400				"неверный формат запроса"
 //	@failure		409				"логин уже занят"
+//	@failure		415				"тело запроса не application/json"
 //	@failure		500				"внутренняя ошибка сервера"
 //	@Router			/api/user/register [post]
+//	@Router			/api/v2/user/register [post]
 func (s *Server) handlerRegister(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	unauthorize(c)
-
-	bBody, statusCode := s.readBody(c)
-	if statusCode > 0 {
-		c.Writer.WriteHeader(statusCode)
-		return
-	}
+	s.unauthorize(c)
 
 	jBody := tRegistration{}
-
-	err := json.Unmarshal(bBody, &jBody)
-	if err != nil {
-		s.log.Error("failed parse body", zap.Error(err))
-		c.Writer.WriteHeader(http.StatusInternalServerError)
+	if !s.bindJSON(c, &jBody) {
 		return
 	}
 
-	if err = s.service.Register(ctx, jBody.Login, jBody.Password); err != nil {
-		if errors.Is(err, errstore.ErrLoginNotUnique) {
-			c.Writer.WriteHeader(http.StatusConflict)
-			return
-		}
-		if errors.Is(err, gophermart.ErrLoginNotValid) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": "Не верный формат логина",
-			})
-			return
-		}
-		if errors.Is(err, gophermart.ErrPasswordNotValid) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": "Не верный формат пароля",
-			})
-			return
+	if err := s.service.Register(ctx, jBody.Login, jBody.Password); err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed register user", zap.Error(err))
 		}
-
-		s.log.Error("failed register user", zap.Error(err))
-		c.Writer.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	statusCode, message := s.login(c, jBody.Login, jBody.Password)
-	if message != "" {
-		c.JSON(statusCode, gin.H{
-			"message": message,
-		})
+		writeProblem(c, 0, err)
 		return
 	}
 
-	c.Writer.WriteHeader(statusCode)
+	s.login(c, jBody.Login, jBody.Password)
 }
 
 //	@Summary	Login user
@@ -93,35 +58,21 @@ func (s *Server) handlerRegister(c *gin.Context) {
 //	@Success		200		"пользователь успешно аутентифицирован"
 //	@failure		400		"неверный формат запроса"
 //	@failure		401		"неверная пара логин/пароль"
+//	@failure		415		"тело запроса не application/json"
+//	@failure		423		"вход временно заблокирован после серии неудачных попыток"
+//	@failure		429		"слишком частые попытки входа, повторить через Retry-After"
 //	@failure		500		"внутренняя ошибка сервера"
 //	@Router			/api/user/login [post]
+//	@Router			/api/v2/user/login [post]
 func (s *Server) handlerLogin(c *gin.Context) {
-	unauthorize(c)
-
-	bBody, statusCode := s.readBody(c)
-	if statusCode > 0 {
-		c.Writer.WriteHeader(statusCode)
-		return
-	}
+	s.unauthorize(c)
 
 	jBody := tAuthorization{}
-
-	err := json.Unmarshal(bBody, &jBody)
-	if err != nil {
-		s.log.Error("failed parse body", zap.Error(err))
-		c.Writer.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	statusCode, message := s.login(c, jBody.Login, jBody.Password)
-	if message != "" {
-		c.JSON(statusCode, gin.H{
-			"message": message,
-		})
+	if !s.bindJSON(c, &jBody) {
 		return
 	}
 
-	c.Writer.WriteHeader(statusCode)
+	s.login(c, jBody.Login, jBody.Password)
 }
 
 //	@Summary	upload user order
@@ -136,101 +87,160 @@ func (s *Server) handlerLogin(c *gin.Context) {
 //	@failure		400			"неверный формат запроса"
 //	@failure		401			"пользователь не авторизован"
 //	@failure		409			"номер заказа уже был загружен другим пользователем"
+//	@failure		415			"тело запроса не text/plain"
 //	@failure		422			"неверный формат номера заказа"
 //	@failure		500			"внутренняя ошибка сервера"
 //	@Router			/api/user/orders [post]
+//	@Router			/api/v2/user/orders [post]
 func (s *Server) handlerLoadUserOrders(c *gin.Context) {
 	ctx := c.Request.Context()
 	userID, err := s.checkAuth(c)
 	if err != nil {
-		c.Writer.WriteHeader(http.StatusUnauthorized)
+		writeProblem(c, 0, errUnauthorize)
 		return
 	}
 
-	bBody, statusCode := s.readBody(c)
-	if statusCode > 0 {
-		c.Writer.WriteHeader(statusCode)
-		return
-	}
-
-	orderNumber := string(bBody)
-	if orderNumber == "" {
-		c.Writer.WriteHeader(http.StatusBadRequest)
+	orderNumber, ok := s.readOrderNumber(c)
+	if !ok {
 		return
 	}
 
 	err = s.service.UploadOrder(ctx, userID, orderNumber)
+	s.uploadOrderResponse(c, err, orderNumber)
+}
+
+// uploadOrderResponse отвечает на загрузку заказа.
+func (s *Server) uploadOrderResponse(c *gin.Context, err error, orderNumber string) {
 	if err != nil {
-		if errors.Is(err, gophermart.ErrOrderNumberNotValid) {
-			c.Writer.WriteHeader(http.StatusUnprocessableEntity)
-			return
-		}
-		if errors.Is(err, errstore.ErrOrderWasCreatedAnotherUser) {
-			c.Writer.WriteHeader(http.StatusConflict)
-			return
-		}
 		if errors.Is(err, errstore.ErrOrderWasCreatedByUser) {
 			c.Writer.WriteHeader(http.StatusOK)
 			return
 		}
-
-		s.log.Error("failed upload order number", zap.String("orderNumber", orderNumber), zap.Error(err))
-		c.Writer.WriteHeader(http.StatusInternalServerError)
+		if !isProblem(err) {
+			s.logger(c).Error("failed upload order number", zap.String("orderNumber", orderNumber), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
 		return
 	}
 
 	c.Writer.WriteHeader(http.StatusAccepted)
 }
 
+//	@Summary	upload user orders batch
+//	@Schemes
+//	@Description	upload orders batch: json array of numbers or text/plain with a number per line
+//	@Tags			order
+//	@Accept			json,plain
+//	@Produce		json
+//	@Param			orders	body		[]string	true	"номера заказов, не больше 1000"
+//	@Success		200		{array}		tOrderUpload	"результат по каждому номеру: accepted, already_uploaded, owned_by_another_user, invalid"
+//	@failure		400		"неверный формат запроса, пустой пакет или больше 1000 номеров"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		415		"тело запроса не application/json и не text/plain"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/user/orders/batch [post]
+//	@Router			/api/v2/user/orders/batch [post]
+func (s *Server) handlerLoadUserOrdersBatch(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, err := s.checkAuth(c)
+	if err != nil {
+		writeProblem(c, 0, errUnauthorize)
+		return
+	}
+
+	orderNumbers, ok := s.readOrderNumbers(c)
+	if !ok {
+		return
+	}
+
+	uploads, err := s.service.UploadOrders(ctx, userID, orderNumbers)
+	if err != nil {
+		s.logger(c).Error("failed upload orders batch", zap.Int("count", len(orderNumbers)), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newOrderUploads(uploads))
+}
+
 //	@Summary	List user orders
 //	@Schemes
 //	@Description	get user orders
 //	@Tags			order
 //	@Accept			plain
 //	@Produce		json
-//	@Success		200	"успешная обработка запроса"
-//	@Success		204	"нет данных для ответа"
-//	@failure		401	"пользователь не авторизован"
-//	@failure		500	"внутренняя ошибка сервера"
+//	@Param			limit	query	integer	false	"размер страницы, от 1 до 1000"
+//	@Param			cursor	query	string	false	"курсор следующей страницы из заголовка X-Next-Cursor"
+//	@Param			sort	query	string	false	"порядок по времени загрузки: asc (по умолчанию) или desc"
+//	@Param			from	query	string	false	"загруженные не раньше, RFC3339"
+//	@Param			to		query	string	false	"загруженные раньше, RFC3339"
+//	@Param			status	query	string	false	"статусы через запятую: NEW,PROCESSING,INVALID,PROCESSED"
+//	@Success		200		"успешная обработка запроса"
+//	@Success		204		"нет данных для ответа"
+//	@Success		304		"данные не менялись, ETag совпал с If-None-Match"
+//	@failure		400		"неверные параметры списка"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		500		"внутренняя ошибка сервера"
 //	@Router			/api/user/orders [get]
 func (s *Server) handlerGetUserOrders(c *gin.Context) {
 	ctx := c.Request.Context()
 	userID, err := s.checkAuth(c)
 	if err != nil {
-		c.Writer.WriteHeader(http.StatusUnauthorized)
+		writeProblem(c, 0, errUnauthorize)
+		return
+	}
+
+	query, ok := s.listQueryV1(c, true)
+	if !ok {
 		return
 	}
 
-	orders, err := s.service.GetUserOrders(ctx, userID)
+	orders, next, err := s.service.GetUserOrders(ctx, userID, query)
 	if err != nil {
 		if errors.Is(err, errstore.ErrNotFoundData) {
 			c.Writer.WriteHeader(http.StatusNoContent)
 			return
 		}
 
-		s.log.Error("failed get orders by user", zap.Error(err))
-		c.Writer.WriteHeader(http.StatusInternalServerError)
+		s.logger(c).Error("failed get orders by user", zap.Error(err))
+		writeProblem(c, 0, err)
 		return
 	}
 
-	response := []tOrderByUser{}
-	for _, order := range orders {
-		resOrder := tOrderByUser{
-			Number:     order.Number,
-			Status:     order.Status,
-			uploadedAt: order.UpdatedAt,
-		}
-		if order.Status == model.OrderStateProcessed {
-			_accrual := &order.Accrual
-			resOrder.Accrual = _accrual
+	setNextCursor(c, next)
+	c.JSON(http.StatusOK, newOrdersByUser(orders))
+}
+
+//	@Summary	User order
+//	@Schemes
+//	@Description	get user order with status history
+//	@Tags			order
+//	@Produce		json
+//	@Param			number	path		string	true	"номер заказа"
+//	@Success		200		{object}	tOrderDetails	"заказ и история статусов"
+//	@Success		304		"данные не менялись, ETag совпал с If-None-Match"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		404		"заказ не найден"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/user/orders/{number} [get]
+func (s *Server) handlerGetUserOrder(c *gin.Context) {
+	user, _ := currentUser(c)
+	s.writeOrderDetails(c, user.ID)
+}
+
+// writeOrderDetails отвечает заказом пользователя с номером из пути и историей его статусов.
+func (s *Server) writeOrderDetails(c *gin.Context, userID uint) {
+	number := c.Param("number")
+	order, history, err := s.service.GetUserOrder(c.Request.Context(), userID, number)
+	if err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed get order", zap.Uint("userID", userID), zap.String("number", number), zap.Error(err))
 		}
-		resOrder.Prepare()
-		response = append(response, resOrder)
+		writeProblem(c, 0, err)
+		return
 	}
-	sort.Slice(response, func(i, j int) bool {
-		return response[i].uploadedAt.Sub(response[j].uploadedAt) < 0
-	})
-	c.JSON(http.StatusOK, response)
+
+	c.JSON(http.StatusOK, newOrderDetails(&order, history))
 }
 
 //	@Summary	User balance
@@ -240,6 +250,7 @@ func (s *Server) handlerGetUserOrders(c *gin.Context) {
 //	@Accept			plain
 //	@Produce		json
 //	@Success		200	"успешная обработка запроса"
+//	@Success		304	"данные не менялись, ETag совпал с If-None-Match"
 //	@failure		401	"пользователь не авторизован"
 //	@failure		500	"внутренняя ошибка сервера"
 //	@Router			/api/user/balance [get]
@@ -247,7 +258,7 @@ func (s *Server) handlerGetUserBalance(c *gin.Context) {
 	ctx := c.Request.Context()
 	userID, err := s.checkAuth(c)
 	if err != nil {
-		c.Writer.WriteHeader(http.StatusUnauthorized)
+		writeProblem(c, 0, errUnauthorize)
 		return
 	}
 
@@ -260,7 +271,8 @@ func (s *Server) handlerGetUserBalance(c *gin.Context) {
 			})
 			return
 		}
-		c.Writer.WriteHeader(http.StatusInternalServerError)
+		s.logger(c).Error("failed getting balance by user", zap.Error(err))
+		writeProblem(c, 0, err)
 		return
 	}
 	c.JSON(http.StatusOK, tBalanceByUser{
@@ -277,46 +289,37 @@ func (s *Server) handlerGetUserBalance(c *gin.Context) {
 //	@Param			withdraw	body	tWithdraw	true	"withdraw"
 //	@Produce		json
 //	@Success		200	"успешная обработка запроса"
+//	@failure		400	"неверный формат запроса"
 //	@failure		401	"пользователь не авторизован"
 //	@failure		402	"на счету недостаточно средств"
-//	@failure		422	"неверный номер заказа"
+//	@failure		415	"тело запроса не application/json"
+//	@failure		422	"неверный номер заказа или сумма не больше нуля"
 //	@failure		500	"внутренняя ошибка сервера"
 //	@Router			/api/user/balance/withdraw [post]
 func (s *Server) handlerUserBalanceWithdraw(c *gin.Context) {
 	ctx := c.Request.Context()
 	userID, err := s.checkAuth(c)
 	if err != nil {
-		c.Writer.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	bBody, statusCode := s.readBody(c)
-	if statusCode > 0 {
-		c.Writer.WriteHeader(statusCode)
+		writeProblem(c, 0, errUnauthorize)
 		return
 	}
 
 	withdraw := tWithdraw{}
-	err = json.Unmarshal(bBody, &withdraw)
-	if err != nil {
-		s.log.Error("failed marshal body", zap.String("body", string(bBody)), zap.Error(err))
-		c.Writer.WriteHeader(http.StatusInternalServerError)
+	if !s.bindJSON(c, &withdraw) {
 		return
 	}
 
 	err = s.service.WithdrawFromBalanceUser(ctx, userID, withdraw.Order, withdraw.Sum)
+	s.withdrawResponse(c, err)
+}
+
+// withdrawResponse отвечает на списание баллов.
+func (s *Server) withdrawResponse(c *gin.Context, err error) {
 	if err != nil {
-		if errors.Is(err, gophermart.ErrOrderNumberNotValid) {
-			c.Writer.WriteHeader(http.StatusUnprocessableEntity)
-			return
-		}
-		if errors.Is(err, errstore.ErrBalansNotEnough) {
-			c.Writer.WriteHeader(http.StatusPaymentRequired)
-			return
+		if !isProblem(err) {
+			s.logger(c).Error("failed withdraw balance", zap.Error(err))
 		}
-
-		s.log.Error("failed withdraw balance", zap.Error(err))
-		c.Writer.WriteHeader(http.StatusInternalServerError)
+		writeProblem(c, 0, err)
 		return
 	}
 
@@ -329,42 +332,104 @@ func (s *Server) handlerUserBalanceWithdraw(c *gin.Context) {
 //	@Tags			balance
 //	@Accept			plain
 //	@Produce		json
-//	@Success		200	{array}	tWithdrawBalance	"успешная обработка запроса"
-//	@failure		204	"нет ни одного списания"
-//	@failure		401	"пользователь не авторизован"
-//	@failure		500	"внутренняя ошибка сервера"
+//	@Param			limit	query	integer	false	"размер страницы, от 1 до 1000"
+//	@Param			cursor	query	string	false	"курсор следующей страницы из заголовка X-Next-Cursor"
+//	@Param			sort	query	string	false	"порядок по времени списания: asc (по умолчанию) или desc"
+//	@Param			from	query	string	false	"списания не раньше, RFC3339"
+//	@Param			to		query	string	false	"списания раньше, RFC3339"
+//	@Success		200		{array}	tWithdrawBalance	"успешная обработка запроса"
+//	@Success		304		"данные не менялись, ETag совпал с If-None-Match"
+//	@failure		204		"нет ни одного списания"
+//	@failure		400		"неверные параметры списка"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		500		"внутренняя ошибка сервера"
 //	@Router			/api/user/withdrawals [get]
 func (s *Server) handlerUserWithdrawals(c *gin.Context) {
 	ctx := c.Request.Context()
 	userID, err := s.checkAuth(c)
 	if err != nil {
-		c.Writer.WriteHeader(http.StatusUnauthorized)
+		writeProblem(c, 0, errUnauthorize)
 		return
 	}
 
-	withdrawals, err := s.service.GetWithdrawalsByUser(ctx, userID)
+	query, ok := s.listQueryV1(c, false)
+	if !ok {
+		return
+	}
+
+	withdrawals, next, err := s.service.GetWithdrawalsByUser(ctx, userID, query)
 	if err != nil {
 		if errors.Is(err, errstore.ErrNotFoundData) {
 			c.Writer.WriteHeader(http.StatusNoContent)
 			return
 		}
 
-		s.log.Error("failed getting withdrawals by user", zap.Error(err))
-		c.Writer.WriteHeader(http.StatusInternalServerError)
+		s.logger(c).Error("failed getting withdrawals by user", zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+	setNextCursor(c, next)
+	c.JSON(http.StatusOK, newWithdrawalsByUser(withdrawals))
+}
+
+//	@Summary	Change user password
+//	@Schemes
+//	@Description	change password, all previously issued tokens are revoked
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		plain
+//	@Param			password	body	tChangePassword	true	"password"
+//	@Success		200			"пароль изменен, выдан новый токен"
+//	@failure		400			"неверный формат запроса или нового пароля"
+//	@failure		401			"пользователь не авторизован"
+//	@failure		403			"неверный текущий пароль"
+//	@failure		423			"смена пароля временно заблокирована после серии неудачных попыток"
+//	@failure		429			"слишком частые попытки, повторить через Retry-After"
+//	@failure		500			"внутренняя ошибка сервера"
+//	@Router			/api/user/password [post]
+func (s *Server) handlerChangePassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, err := s.checkAuth(c)
+	if err != nil {
+		writeProblem(c, 0, errUnauthorize)
+		return
+	}
+
+	jBody := tChangePassword{}
+	if !s.bindJSON(c, &jBody) {
 		return
 	}
-	result := []tWithdrawBalance{}
-	for _, withdrawal := range withdrawals {
-		wd := tWithdrawBalance{
-			Order:       withdrawal.OderNumber,
-			Sum:         withdrawal.Sum,
-			processedAt: withdrawal.UpdatedAt,
+
+	user, err := s.service.ChangePassword(ctx, userID, jBody.OldPassword, jBody.NewPassword)
+	if err != nil {
+		if status, ok := loginAttemptsStatus(c, err); ok {
+			writeProblem(c, status, err)
+			return
+		}
+		if !isProblem(err) {
+			s.logger(c).Error("failed change password", zap.Uint("userID", userID), zap.Error(err))
 		}
-		result = append(result, *wd.Prepare())
+		writeProblem(c, 0, err)
+		return
 	}
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].processedAt.Sub(result[j].processedAt) < 0
-	})
 
-	c.JSON(http.StatusOK, result)
+	if err := s.setToken(c, user); err != nil {
+		s.logger(c).Error("failed set token after password change", zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusOK)
+}
+
+//	@Summary	JSON Web Key Set
+//	@Schemes
+//	@Description	public keys for verifying user tokens signed with RS256 or EdDSA
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	jwt.JWKSet	"открытые ключи подписи токенов"
+//	@Router			/.well-known/jwks.json [get]
+func (s *Server) handlerJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, s.jwt.JWKS())
 }
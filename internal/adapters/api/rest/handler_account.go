@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+//	@Summary	Export personal data
+//	@Schemes
+//	@Description	zip archive with profile.json, orders.json, withdrawals.json and balance.json (current balance and history)
+//	@Tags			account
+//	@Produce		application/zip
+//	@Success		200	"архив с данными пользователя"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/user/export [get]
+func (s *Server) handlerExportUserData(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	export, err := s.service.ExportUserData(c.Request.Context(), user.ID)
+	if err != nil {
+		s.logger(c).Error("failed export user data", zap.Uint("userID", user.ID), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	files := []struct {
+		data any
+		name string
+	}{
+		{name: "profile.json", data: tExportProfile{
+			ID:               export.User.ID,
+			Login:            export.User.Login,
+			Role:             export.User.Role,
+			CreatedAt:        export.User.CreatedAt.Format(time.RFC3339),
+			TwoFactorEnabled: export.User.TOTPEnabled,
+		}},
+		{name: "orders.json", data: newOrdersByUser(export.Orders)},
+		{name: "withdrawals.json", data: newWithdrawalsByUser(export.Withdrawals)},
+		{name: "balance.json", data: newExportBalance(&export)},
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="gophermart-user-%d.zip"`, user.ID))
+	c.Writer.WriteHeader(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for _, file := range files {
+		w, err := archive.Create(file.name)
+		if err != nil {
+			s.logger(c).Error("failed create file in export archive", zap.String("file", file.name), zap.Error(err))
+			return
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			s.logger(c).Error("failed write export file", zap.String("file", file.name), zap.Error(err))
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		s.logger(c).Error("failed close export archive", zap.Error(err))
+	}
+}
+
+//	@Summary	Delete account
+//	@Schemes
+//	@Description	anonymize login and credentials, financial records are kept for accounting; all tokens are revoked
+//	@Tags			account
+//	@Accept			json
+//	@Produce		plain
+//	@Param			password	body	tDeleteUser	true	"current password"
+//	@Success		200			"учетная запись удалена"
+//	@failure		400			"неверный формат запроса"
+//	@failure		401			"пользователь не авторизован"
+//	@failure		403			"неверный пароль"
+//	@failure		500			"внутренняя ошибка сервера"
+//	@Router			/api/user [delete]
+func (s *Server) handlerDeleteUser(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	jBody := tDeleteUser{}
+	if !s.bindJSON(c, &jBody) {
+		return
+	}
+
+	if err := s.service.DeleteUser(c.Request.Context(), user.ID, jBody.Password); err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed delete user", zap.Uint("userID", user.ID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	s.unauthorize(c)
+	c.Writer.WriteHeader(http.StatusOK)
+}
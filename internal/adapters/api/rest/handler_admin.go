@@ -0,0 +1,493 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"go.uber.org/zap"
+)
+
+// pathID разбирает идентификатор из параметра пути name. При ошибке отвечает 400 и возвращает false.
+func pathID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		writeProblem(c, 0, fmt.Errorf("%w: %s must be positive integer", errRequestNotValid, name))
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+// adminTargetUser загружает пользователя из параметра пути `id`.
+// При ошибке ответ уже записан и возвращается false.
+func (s *Server) adminTargetUser(c *gin.Context) (model.User, bool) {
+	userID, ok := pathID(c, "id")
+	if !ok {
+		return model.User{}, false
+	}
+
+	user, err := s.service.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed get user", zap.Uint("userID", userID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return user, false
+	}
+
+	return user, true
+}
+
+//	@Summary	Search users
+//	@Schemes
+//	@Description	search users by login substring, available for support and admin roles
+//	@Tags			admin
+//	@Produce		json
+//	@Param			login	query	string	false	"часть логина"
+//	@Success		200		{array}	tAdminUser	"успешная обработка запроса"
+//	@Success		204		"пользователи не найдены"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		403		"недостаточно прав"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/admin/users [get]
+func (s *Server) handlerAdminSearchUsers(c *gin.Context) {
+	users, err := s.service.SearchUsers(c.Request.Context(), c.Query("login"))
+	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			c.Writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.logger(c).Error("failed search users", zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	result := []tAdminUser{}
+	for _, user := range users {
+		result = append(result, newAdminUser(user))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+//	@Summary	Get user
+//	@Schemes
+//	@Description	get user by id, available for support and admin roles
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path	integer	true	"user id"
+//	@Success		200	{object}	tAdminUser	"успешная обработка запроса"
+//	@failure		400	"неверный идентификатор пользователя"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		403	"недостаточно прав"
+//	@failure		404	"пользователь не найден"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{id} [get]
+func (s *Server) handlerAdminGetUser(c *gin.Context) {
+	user, ok := s.adminTargetUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newAdminUser(&user))
+}
+
+//	@Summary	List orders of user
+//	@Schemes
+//	@Description	get orders of user, available for support and admin roles
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path	integer	true	"user id"
+//	@Success		200	{array}	tOrderByUser	"успешная обработка запроса"
+//	@Success		204	"нет данных для ответа"
+//	@failure		400	"неверный идентификатор пользователя или параметры списка"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		403	"недостаточно прав"
+//	@failure		404	"пользователь не найден"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{id}/orders [get]
+func (s *Server) handlerAdminGetUserOrders(c *gin.Context) {
+	user, ok := s.adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	query, ok := s.listQuery(c, true)
+	if !ok {
+		return
+	}
+
+	orders, next, err := s.service.GetUserOrders(c.Request.Context(), user.ID, query)
+	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			c.Writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.logger(c).Error("failed get orders by user", zap.Uint("userID", user.ID), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	setNextCursor(c, next)
+	c.JSON(http.StatusOK, newOrdersByUser(orders))
+}
+
+//	@Summary	Order of user
+//	@Schemes
+//	@Description	get order of user with status history, available for support and admin roles
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id		path		integer	true	"user id"
+//	@Param			number	path		string	true	"номер заказа"
+//	@Success		200		{object}	tOrderDetails	"заказ и история статусов"
+//	@failure		400		"неверный идентификатор пользователя"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		403		"недостаточно прав"
+//	@failure		404		"пользователь или заказ не найден"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{id}/orders/{number} [get]
+func (s *Server) handlerAdminGetUserOrder(c *gin.Context) {
+	user, ok := s.adminTargetUser(c)
+	if !ok {
+		return
+	}
+	s.writeOrderDetails(c, user.ID)
+}
+
+//	@Summary	List withdrawals of user
+//	@Schemes
+//	@Description	get withdrawals of user, available for support and admin roles
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path	integer	true	"user id"
+//	@Success		200	{array}	tWithdrawBalance	"успешная обработка запроса"
+//	@Success		204	"нет ни одного списания"
+//	@failure		400	"неверный идентификатор пользователя или параметры списка"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		403	"недостаточно прав"
+//	@failure		404	"пользователь не найден"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{id}/withdrawals [get]
+func (s *Server) handlerAdminGetUserWithdrawals(c *gin.Context) {
+	user, ok := s.adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	query, ok := s.listQuery(c, false)
+	if !ok {
+		return
+	}
+
+	withdrawals, next, err := s.service.GetWithdrawalsByUser(c.Request.Context(), user.ID, query)
+	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			c.Writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.logger(c).Error("failed getting withdrawals by user", zap.Uint("userID", user.ID), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	setNextCursor(c, next)
+	c.JSON(http.StatusOK, newWithdrawalsByUser(withdrawals))
+}
+
+//	@Summary	Balance of user
+//	@Schemes
+//	@Description	get balance of user, available for support and admin roles
+//	@Tags			admin
+//	@Produce		json
+//	@Param			id	path	integer	true	"user id"
+//	@Success		200	{object}	tBalanceByUser	"успешная обработка запроса"
+//	@failure		400	"неверный идентификатор пользователя"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		403	"недостаточно прав"
+//	@failure		404	"пользователь не найден"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{id}/balance [get]
+func (s *Server) handlerAdminGetUserBalance(c *gin.Context) {
+	user, ok := s.adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	balance, err := s.service.GetUserBalance(c.Request.Context(), user.ID)
+	if err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		s.logger(c).Error("failed getting balance by user", zap.Uint("userID", user.ID), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tBalanceByUser{
+		Current:   balance.Current,
+		Withdrawn: balance.Withdrawn,
+	})
+}
+
+//	@Summary	Adjust balance of user
+//	@Schemes
+//	@Description	manual balance adjustment written to audit, available for admin role
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	integer				true	"user id"
+//	@Param			adjustment	body	tBalanceAdjustment	true	"adjustment"
+//	@Success		200			{object}	tBalanceByUser	"баланс после корректировки"
+//	@failure		400			"неверный формат запроса"
+//	@failure		401			"пользователь не авторизован"
+//	@failure		402			"на счету недостаточно средств для списания"
+//	@failure		403			"недостаточно прав"
+//	@failure		404			"пользователь не найден"
+//	@failure		500			"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{id}/balance/adjustments [post]
+func (s *Server) handlerAdminAdjustBalance(c *gin.Context) {
+	actor, _ := currentUser(c)
+	user, ok := s.adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	adjustment := tBalanceAdjustment{}
+	if !s.bindJSON(c, &adjustment) {
+		return
+	}
+
+	balance, err := s.service.AdjustBalance(c.Request.Context(), actor.ID, user.ID, adjustment.Amount, adjustment.Reason)
+	if err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed adjust balance", zap.Uint("userID", user.ID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tBalanceByUser{
+		Current:   balance.Current,
+		Withdrawn: balance.Withdrawn,
+	})
+}
+
+//	@Summary	Set role of user
+//	@Schemes
+//	@Description	change role of user, tokens of user are revoked, available for admin role
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		plain
+//	@Param			id		path	integer		true	"user id"
+//	@Param			role	body	tUserRole	true	"role"
+//	@Success		200		"роль изменена"
+//	@failure		400		"неверный формат запроса или роль"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		403		"недостаточно прав"
+//	@failure		404		"пользователь не найден"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/admin/users/{id}/role [put]
+func (s *Server) handlerAdminSetUserRole(c *gin.Context) {
+	actor, _ := currentUser(c)
+	user, ok := s.adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	role := tUserRole{}
+	if !s.bindJSON(c, &role) {
+		return
+	}
+
+	if err := s.service.SetUserRole(c.Request.Context(), actor.ID, user.ID, role.Role); err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed set user role", zap.Uint("userID", user.ID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusOK)
+}
+
+//	@Summary	Create merchant
+//	@Schemes
+//	@Description	create merchant account, available for admin role
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			merchant	body	tMerchantName	true	"merchant"
+//	@Success		201			{object}	tMerchant	"магазин создан"
+//	@failure		400			"неверный формат запроса"
+//	@failure		401			"пользователь не авторизован"
+//	@failure		403			"недостаточно прав"
+//	@failure		409			"магазин с таким названием уже существует"
+//	@failure		500			"внутренняя ошибка сервера"
+//	@Router			/api/admin/merchants [post]
+func (s *Server) handlerAdminCreateMerchant(c *gin.Context) {
+	actor, _ := currentUser(c)
+
+	jBody := tMerchantName{}
+	if !s.bindJSON(c, &jBody) {
+		return
+	}
+
+	merchant, err := s.service.CreateMerchant(c.Request.Context(), actor.ID, jBody.Name)
+	if err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed create merchant", zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newMerchant(&merchant))
+}
+
+//	@Summary	Create merchant API key
+//	@Schemes
+//	@Description	issue API key with scopes `orders:write`, `balance:withdraw`; the key is shown only once, available for admin role
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	integer				true	"merchant id"
+//	@Param			scopes	body	tMerchantKeyScopes	true	"scopes"
+//	@Success		201		{object}	tMerchantKey	"ключ выпущен"
+//	@failure		400		"неверный формат запроса или разрешения"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		403		"недостаточно прав"
+//	@failure		404		"магазин не найден"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/admin/merchants/{id}/keys [post]
+func (s *Server) handlerAdminCreateMerchantKey(c *gin.Context) {
+	actor, _ := currentUser(c)
+	merchantID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+
+	jBody := tMerchantKeyScopes{}
+	if !s.bindJSON(c, &jBody) {
+		return
+	}
+
+	apiKey, key, err := s.service.CreateMerchantKey(c.Request.Context(), actor.ID, merchantID, jBody.Scopes)
+	if err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed create merchant key", zap.Uint("merchantID", merchantID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tMerchantKey{
+		ID:        key.ID,
+		Key:       apiKey,
+		Scopes:    strings.Split(key.Scopes, ","),
+		CreatedAt: key.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+//	@Summary	Revoke merchant API key
+//	@Schemes
+//	@Description	revoke API key of merchant, available for admin role
+//	@Tags			admin
+//	@Produce		plain
+//	@Param			id		path	integer	true	"merchant id"
+//	@Param			keyID	path	integer	true	"key id"
+//	@Success		200		"ключ отозван"
+//	@failure		400		"неверный идентификатор"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		403		"недостаточно прав"
+//	@failure		404		"ключ не найден или уже отозван"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/admin/merchants/{id}/keys/{keyID} [delete]
+func (s *Server) handlerAdminRevokeMerchantKey(c *gin.Context) {
+	actor, _ := currentUser(c)
+	merchantID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	keyID, ok := pathID(c, "keyID")
+	if !ok {
+		return
+	}
+
+	if err := s.service.RevokeMerchantKey(c.Request.Context(), actor.ID, merchantID, keyID); err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed revoke merchant key", zap.Uint("merchantID", merchantID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusOK)
+}
+
+//	@Summary	Link merchant to user
+//	@Schemes
+//	@Description	allow merchant to act on behalf of user, available for admin role
+//	@Tags			admin
+//	@Produce		plain
+//	@Param			id		path	integer	true	"merchant id"
+//	@Param			userID	path	integer	true	"user id"
+//	@Success		200		"магазин связан с пользователем"
+//	@failure		400		"неверный идентификатор"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		403		"недостаточно прав"
+//	@failure		404		"магазин или пользователь не найден"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/admin/merchants/{id}/users/{userID} [put]
+func (s *Server) handlerAdminLinkMerchantUser(c *gin.Context) {
+	s.adminMerchantUser(c, s.service.LinkMerchantUser)
+}
+
+//	@Summary	Unlink merchant from user
+//	@Schemes
+//	@Description	forbid merchant to act on behalf of user, available for admin role
+//	@Tags			admin
+//	@Produce		plain
+//	@Param			id		path	integer	true	"merchant id"
+//	@Param			userID	path	integer	true	"user id"
+//	@Success		200		"связь удалена"
+//	@failure		400		"неверный идентификатор"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		403		"недостаточно прав"
+//	@failure		404		"связь не найдена"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/admin/merchants/{id}/users/{userID} [delete]
+func (s *Server) handlerAdminUnlinkMerchantUser(c *gin.Context) {
+	s.adminMerchantUser(c, s.service.UnlinkMerchantUser)
+}
+
+func (s *Server) adminMerchantUser(
+	c *gin.Context,
+	action func(ctx context.Context, actorID, merchantID, userID uint) error,
+) {
+	actor, _ := currentUser(c)
+	merchantID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	userID, ok := pathID(c, "userID")
+	if !ok {
+		return
+	}
+
+	if err := action(c.Request.Context(), actor.ID, merchantID, userID); err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed change merchant user link",
+				zap.Uint("merchantID", merchantID),
+				zap.Uint("userID", userID),
+				zap.Error(err),
+			)
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,196 @@
+package rest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"go.uber.org/zap"
+)
+
+var (
+	contentTypeCSV = "text/csv; charset=utf-8"
+
+	ordersCSVHeader      = []string{"number", "status", "accrual", "uploaded_at"}
+	withdrawalsCSVHeader = []string{"order", "sum", "processed_at"}
+)
+
+//	@Summary	Export orders as CSV
+//	@Schemes
+//	@Description	заказы пользователя в CSV: number, status, accrual, uploaded_at. Строки передаются по мере чтения из базы.
+//	@Description	Принимает from, to, sort и status как список заказов.
+//	@Tags			orders
+//	@Produce		text/csv
+//	@Success		200	"CSV с заголовком, без заказов только заголовок"
+//	@failure		400	"неверные параметры списка"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/user/orders.csv [get]
+func (s *Server) handlerUserOrdersCSV(c *gin.Context) {
+	user, _ := currentUser(c)
+	query, ok := s.listQuery(c, true)
+	if !ok {
+		return
+	}
+
+	s.streamCSV(c, "orders.csv", ordersCSVHeader, func(write func([]string) error) error {
+		return s.service.StreamUserOrders(c.Request.Context(), user.ID, query, func(order *model.Order) error {
+			return write(orderCSVRecord(order))
+		})
+	})
+}
+
+//	@Summary	Export withdrawals as CSV
+//	@Schemes
+//	@Description	списания пользователя в CSV: order, sum, processed_at. Строки передаются по мере чтения из базы.
+//	@Description	Принимает from, to и sort как список списаний.
+//	@Tags			balance
+//	@Produce		text/csv
+//	@Success		200	"CSV с заголовком, без списаний только заголовок"
+//	@failure		400	"неверные параметры списка"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/user/withdrawals.csv [get]
+func (s *Server) handlerUserWithdrawalsCSV(c *gin.Context) {
+	user, _ := currentUser(c)
+	query, ok := s.listQuery(c, false)
+	if !ok {
+		return
+	}
+
+	s.streamCSV(c, "withdrawals.csv", withdrawalsCSVHeader, func(write func([]string) error) error {
+		return s.service.StreamUserWithdrawals(c.Request.Context(), user.ID, query,
+			func(withdrawal *model.WithdrawBalance) error {
+				return write(withdrawalCSVRecord(withdrawal))
+			})
+	})
+}
+
+//	@Summary	Export orders of all users as CSV
+//	@Schemes
+//	@Description	заказы всех пользователей в CSV: user_id, number, status, accrual, uploaded_at, только для роли admin.
+//	@Description	Строки передаются по мере чтения из базы, выгрузка пишется в журнал аудита.
+//	@Tags			admin
+//	@Produce		text/csv
+//	@Success		200	"CSV с заголовком"
+//	@failure		400	"неверные параметры списка"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		403	"недостаточно прав"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/admin/export/orders.csv [get]
+func (s *Server) handlerAdminOrdersCSV(c *gin.Context) {
+	actor, _ := currentUser(c)
+	query, ok := s.listQuery(c, true)
+	if !ok {
+		return
+	}
+
+	header := append([]string{"user_id"}, ordersCSVHeader...)
+	s.streamCSV(c, "orders-all.csv", header, func(write func([]string) error) error {
+		return s.service.StreamAllOrders(c.Request.Context(), actor.ID, query, func(order *model.Order) error {
+			return write(append([]string{formatID(order.UserID)}, orderCSVRecord(order)...))
+		})
+	})
+}
+
+//	@Summary	Export withdrawals of all users as CSV
+//	@Schemes
+//	@Description	списания всех пользователей в CSV: user_id, order, sum, processed_at, только для роли admin.
+//	@Description	Строки передаются по мере чтения из базы, выгрузка пишется в журнал аудита.
+//	@Tags			admin
+//	@Produce		text/csv
+//	@Success		200	"CSV с заголовком"
+//	@failure		400	"неверные параметры списка"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		403	"недостаточно прав"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/admin/export/withdrawals.csv [get]
+func (s *Server) handlerAdminWithdrawalsCSV(c *gin.Context) {
+	actor, _ := currentUser(c)
+	query, ok := s.listQuery(c, false)
+	if !ok {
+		return
+	}
+
+	header := append([]string{"user_id"}, withdrawalsCSVHeader...)
+	s.streamCSV(c, "withdrawals-all.csv", header, func(write func([]string) error) error {
+		return s.service.StreamAllWithdrawals(c.Request.Context(), actor.ID, query,
+			func(withdrawal *model.WithdrawBalance) error {
+				return write(append([]string{formatID(withdrawal.Balance.UserID)}, withdrawalCSVRecord(withdrawal)...))
+			})
+	})
+}
+
+// streamCSV отвечает CSV, строки которого stream передает в write по одной. Статус и заголовок CSV
+// отправляются с первой строкой, поэтому ошибка до нее отдается как обычная ошибка API.
+// Ошибка после начала ответа обрывает соединение, чтобы клиент не принял неполный файл за целый.
+func (s *Server) streamCSV(
+	c *gin.Context, filename string, header []string, stream func(write func([]string) error) error,
+) {
+	w := csv.NewWriter(c.Writer)
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", contentTypeCSV)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Writer.WriteHeader(http.StatusOK)
+		return w.Write(header)
+	}
+	write := func(record []string) error {
+		if !started {
+			if err := start(); err != nil {
+				return fmt.Errorf("failed write csv header: %w", err)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed write csv record: %w", err)
+		}
+		return nil
+	}
+
+	err := stream(write)
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		w.Flush()
+		err = w.Error()
+	}
+	if err == nil {
+		return
+	}
+
+	if c.Request.Context().Err() != nil {
+		s.logger(c).Debug("csv export interrupted by client", zap.String("file", filename), zap.Error(err))
+		return
+	}
+	if !started {
+		if !isProblem(err) {
+			s.logger(c).Error("failed export csv", zap.String("file", filename), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+	s.logger(c).Error("failed export csv, response truncated", zap.String("file", filename), zap.Error(err))
+	panic(http.ErrAbortHandler)
+}
+
+func orderCSVRecord(order *model.Order) []string {
+	accrual := ""
+	if order.Status == model.OrderStateProcessed {
+		accrual = formatDecimal(order.Accrual)
+	}
+	return []string{order.Number, string(order.Status), accrual, order.CreatedAt.Format(time.RFC3339)}
+}
+
+func withdrawalCSVRecord(withdrawal *model.WithdrawBalance) []string {
+	return []string{withdrawal.OderNumber, formatDecimal(withdrawal.Sum), withdrawal.CreatedAt.Format(time.RFC3339)}
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
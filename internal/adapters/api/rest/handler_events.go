@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	lastEventIDHeader      = "Last-Event-ID"
+	lastEventIDQuery       = "last_event_id"
+	defaultEventsKeepAlive = time.Second * 15
+)
+
+//	@Summary	User events
+//	@Schemes
+//	@Description	server-sent events: order (новый статус заказа) и balance (новый баланс). id события передается в Last-Event-ID при переподключении
+//	@Tags			events
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header	integer	false	"идентификатор последнего полученного события"
+//	@Success		200				{object}	tEvent	"поток событий"
+//	@failure		400				"неверный Last-Event-ID"
+//	@failure		401				"пользователь не авторизован"
+//	@Router			/api/user/events [get]
+func (s *Server) handlerUserEvents(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	lastEventID, ok := readLastEventID(c)
+	if !ok {
+		return
+	}
+
+	events, cancel := s.service.SubscribeEvents(user.ID, lastEventID)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(s.eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			err := sse.Encode(c.Writer, sse.Event{
+				Id:    strconv.FormatUint(event.ID, 10),
+				Event: string(event.Type),
+				Data:  newEvent(&event),
+			})
+			if err != nil {
+				s.logger(c).Debug("failed write event", zap.Uint("userID", user.ID), zap.Error(err))
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// readLastEventID читает идентификатор последнего события, полученного клиентом до переподключения,
+// из заголовка Last-Event-ID или параметра last_event_id: браузер не дает задать заголовки WebSocket.
+func readLastEventID(c *gin.Context) (uint64, bool) {
+	value := c.GetHeader(lastEventIDHeader)
+	if value == "" {
+		value = c.Query(lastEventIDQuery)
+	}
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		writeProblem(c, 0, fmt.Errorf("%w: %s must be a number", errRequestNotValid, lastEventIDHeader))
+		return 0, false
+	}
+
+	return id, true
+}
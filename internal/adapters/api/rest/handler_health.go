@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"go.uber.org/zap"
+)
+
+const (
+	healthOK      = "ok"
+	healthFail    = "fail"
+	healthSkipped = "skipped"
+
+	readinessReady    = "ready"
+	readinessNotReady = "not_ready"
+)
+
+//	@Summary	Проверка жизнеспособности
+//	@Schemes
+//	@Description	процесс запущен и отвечает на запросы, зависимости не проверяются
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	tHealth	"сервис работает"
+//	@Router			/healthz [get]
+func (s *Server) handlerHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, tHealth{Status: healthOK})
+}
+
+//	@Summary	Проверка готовности
+//	@Schemes
+//	@Description	проверяет соединение с базой данных и актуальность миграций, сообщает состояние circuit breaker
+//	@Description	системы расчета начислений. Во время остановки сервиса отвечает 503, чтобы балансировщик снял трафик.
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	tReadiness	"сервис готов принимать запросы"
+//	@Failure		503	{object}	tReadiness	"сервис не готов, причина в проверках"
+//	@Router			/readyz [get]
+func (s *Server) handlerReadyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), s.health.ReadyTimeout)
+	defer cancel()
+
+	result := tReadiness{
+		Status:       readinessReady,
+		Database:     tHealthCheck{Status: healthOK},
+		Migrations:   tHealthCheck{Status: healthOK},
+		Accrual:      tAccrualHealth{CircuitBreaker: s.service.AccrualState()},
+		ShuttingDown: s.draining.Load(),
+	}
+
+	if err := s.service.PingStore(ctx); err != nil {
+		s.logger(c).Warn("readiness: database unavailable", zap.Error(err))
+		result.Database = tHealthCheck{Status: healthFail, Error: healthError(err)}
+		result.Migrations = tHealthCheck{Status: healthSkipped}
+	} else if err := s.service.CheckMigrations(ctx); err != nil {
+		s.logger(c).Warn("readiness: migrations check failed", zap.Error(err))
+		message := healthError(err)
+		if errors.Is(err, errstore.ErrMigrationsNotApplied) {
+			message = err.Error()
+		}
+		result.Migrations = tHealthCheck{Status: healthFail, Error: message}
+	}
+
+	status := http.StatusOK
+	if result.ShuttingDown || result.Database.Status != healthOK || result.Migrations.Status != healthOK {
+		result.Status = readinessNotReady
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, result)
+}
+
+// healthError скрывает подробности ошибки зависимости, они пишутся в лог.
+func healthError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "unavailable"
+}
+
+// Drain переводит /readyz в not-ready и ждет HealthConfig.DrainDelay, чтобы балансировщик
+// перестал направлять запросы до остановки сервера. Ожидание прерывается по ctx.
+func (s *Server) Drain(ctx context.Context) {
+	s.draining.Store(true)
+	if s.health.DrainDelay <= 0 {
+		return
+	}
+	s.log.Info("draining before shutdown", zap.Duration("delay", s.health.DrainDelay))
+	timer := time.NewTimer(s.health.DrainDelay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+//	@Summary	Upload order of user by merchant
+//	@Schemes
+//	@Description	upload order on behalf of linked user, requires scope `orders:write`
+//	@Tags			merchant
+//	@Accept			plain
+//	@Produce		plain
+//	@Param			Authorization	header	string	true	"Bearer <API key>"
+//	@Param			id				path	integer	true	"user id"
+//	@Param			order_id		body	integer	true	"order_id"
+//	@Success		200				"номер заказа уже был загружен этим пользователем"
+//	@Success		202				"новый номер заказа принят в обработку"
+//	@failure		400				"неверный формат запроса"
+//	@failure		401				"неверный API ключ"
+//	@failure		403				"нет разрешения или магазин не связан с пользователем"
+//	@failure		409				"номер заказа уже был загружен другим пользователем"
+//	@failure		415				"тело запроса не text/plain"
+//	@failure		422				"неверный формат номера заказа"
+//	@failure		500				"внутренняя ошибка сервера"
+//	@Router			/api/merchant/users/{id}/orders [post]
+func (s *Server) handlerMerchantUploadOrder(c *gin.Context) {
+	key, _ := currentMerchantKey(c)
+	userID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+
+	orderNumber, ok := s.readOrderNumber(c)
+	if !ok {
+		return
+	}
+
+	err := s.service.MerchantUploadOrder(c.Request.Context(), key.MerchantID, userID, orderNumber)
+	s.uploadOrderResponse(c, err, orderNumber)
+}
+
+//	@Summary	Withdraw from user balance by merchant
+//	@Schemes
+//	@Description	withdraw points on behalf of linked user, requires scope `balance:withdraw`
+//	@Tags			merchant
+//	@Accept			json
+//	@Produce		plain
+//	@Param			Authorization	header	string		true	"Bearer <API key>"
+//	@Param			id				path	integer		true	"user id"
+//	@Param			withdraw		body	tWithdraw	true	"withdraw"
+//	@Success		200				"успешная обработка запроса"
+//	@failure		400				"неверный формат запроса"
+//	@failure		401				"неверный API ключ"
+//	@failure		402				"на счету недостаточно средств"
+//	@failure		403				"нет разрешения или магазин не связан с пользователем"
+//	@failure		415				"тело запроса не application/json"
+//	@failure		422				"неверный номер заказа или сумма не больше нуля"
+//	@failure		500				"внутренняя ошибка сервера"
+//	@Router			/api/merchant/users/{id}/balance/withdraw [post]
+func (s *Server) handlerMerchantWithdraw(c *gin.Context) {
+	key, _ := currentMerchantKey(c)
+	userID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+
+	withdraw := tWithdraw{}
+	if !s.bindJSON(c, &withdraw) {
+		return
+	}
+
+	err := s.service.MerchantWithdraw(c.Request.Context(), key.MerchantID, userID, withdraw.Order, withdraw.Sum)
+	s.withdrawResponse(c, err)
+}
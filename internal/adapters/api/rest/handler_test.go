@@ -1,14 +1,29 @@
 package rest_test
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
 	"testing"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/playmixer/gophermart/internal/adapters/api/rest"
 	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
 	"github.com/playmixer/gophermart/internal/adapters/store/model"
@@ -16,14 +31,26 @@ import (
 	"github.com/playmixer/gophermart/internal/core/gophermart"
 	"github.com/playmixer/gophermart/internal/mocks/store"
 	"github.com/playmixer/gophermart/pkg/jwt"
+	"github.com/playmixer/gophermart/pkg/requestid"
+	"github.com/playmixer/gophermart/pkg/totp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	cookieKey = "UserID"
 )
 
+// userToken подписывает токен пользователя со сроком действия, как его выпускает сервер при входе.
+func userToken(j *jwt.JWT, userID string) (string, error) {
+	return j.CreateExpiring(map[string]string{cookieKey: userID}, time.Now().Add(time.Hour))
+}
+
 func TestServer_handlerRegister(t *testing.T) {
 	ctx := context.Background()
 	tests := []struct {
@@ -66,18 +93,18 @@ func TestServer_handlerRegister(t *testing.T) {
 			if tt.status != http.StatusBadRequest {
 				if tt.status == http.StatusConflict {
 					storeMock.EXPECT().
-						RegisterUser(ctx, gomock.Any(), gomock.Any()).
+						RegisterUser(gomock.Any(), gomock.Any(), gomock.Any()).
 						Return(errstore.ErrLoginNotUnique).
 						Times(1)
 				} else {
 					storeMock.EXPECT().
-						RegisterUser(ctx, gomock.Any(), gomock.Any()).
+						RegisterUser(gomock.Any(), gomock.Any(), gomock.Any()).
 						Return(nil).
 						Times(1)
-					hashPass, err := gophermart.HashPassword(tt.password)
+					hashPass, err := gophermart.NewPasswordHasher(cfg.Gophermart.Password).Hash(tt.password)
 					assert.NoError(t, err)
 					storeMock.EXPECT().
-						GetUserByLogin(ctx, tt.login).
+						GetUserByLogin(gomock.Any(), tt.login).
 						Return(model.User{
 							PasswordHash: hashPass,
 						}, nil).
@@ -143,19 +170,19 @@ func TestServer_handlerLogin(t *testing.T) {
 			cfg.Gophermart.GorutineEnabled = false
 
 			storeMock := store.NewMockStore(ctrl)
-			hashPass, err := gophermart.HashPassword(tt.password)
+			hashPass, err := gophermart.NewPasswordHasher(cfg.Gophermart.Password).Hash(tt.password)
 			assert.NoError(t, err)
 			if tt.status != http.StatusBadRequest {
 				if tt.status == http.StatusUnauthorized {
 					storeMock.EXPECT().
-						GetUserByLogin(ctx, tt.login).
+						GetUserByLogin(gomock.Any(), tt.login).
 						Return(model.User{
 							PasswordHash: "wrong pass",
 						}, nil).
 						Times(1)
 				} else {
 					storeMock.EXPECT().
-						GetUserByLogin(ctx, tt.login).
+						GetUserByLogin(gomock.Any(), tt.login).
 						Return(model.User{
 							PasswordHash: hashPass,
 						}, nil).
@@ -179,12 +206,266 @@ func TestServer_handlerLogin(t *testing.T) {
 
 			assert.Equal(t, tt.status, result.StatusCode)
 
+			cookies := result.Cookies()
+			if assert.NotEmpty(t, cookies) {
+				token := cookies[len(cookies)-1]
+				assert.Equal(t, "token", token.Name)
+				assert.True(t, token.HttpOnly)
+				assert.True(t, token.Secure)
+				assert.Equal(t, http.SameSiteStrictMode, token.SameSite)
+				if tt.status == http.StatusOK {
+					assert.Positive(t, token.MaxAge)
+				} else {
+					assert.Negative(t, token.MaxAge)
+				}
+			}
+
+			err = result.Body.Close()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServer_handlerLogin_lockout(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	assert.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+	cfg.Gophermart.LoginGuard.DelayAfter = 0
+	cfg.Gophermart.LoginGuard.LockoutAfter = 3
+
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByLogin(gomock.Any(), "user").
+		Return(model.User{ID: 1, PasswordHash: "wrong pass"}, nil).
+		Times(3)
+	storeMock.EXPECT().
+		AddAuditEvent(gomock.Any(), gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart)
+	assert.NoError(t, err)
+	engin := server.Engine()
+
+	statuses := []int{
+		http.StatusUnauthorized,
+		http.StatusUnauthorized,
+		http.StatusUnauthorized,
+		http.StatusLocked,
+	}
+	for _, status := range statuses {
+		w := httptest.NewRecorder()
+		body := strings.NewReader(`{"login":"user", "password":"pass"}`)
+		r := httptest.NewRequest(http.MethodPost, "/api/user/login", body)
+
+		engin.ServeHTTP(w, r)
+
+		result := w.Result()
+		assert.Equal(t, status, result.StatusCode)
+		if status == http.StatusLocked {
+			assert.NotEmpty(t, result.Header.Get("Retry-After"))
+		}
+		err = result.Body.Close()
+		assert.NoError(t, err)
+	}
+}
+
+func TestServer_handlerLogin_lockoutParallel(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	assert.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+	cfg.Gophermart.LoginGuard.DelayAfter = 0
+	cfg.Gophermart.LoginGuard.LockoutAfter = 5
+
+	var checked atomic.Int32
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByLogin(gomock.Any(), "user").
+		DoAndReturn(func(context.Context, string) (model.User, error) {
+			checked.Add(1)
+			// медленная проверка пароля, пока она идет, остальные запросы уже приходят
+			time.Sleep(50 * time.Millisecond)
+			return model.User{ID: 1, PasswordHash: "wrong pass"}, nil
+		}).
+		AnyTimes()
+	storeMock.EXPECT().
+		AddAuditEvent(gomock.Any(), gomock.Any()).
+		Return(nil).
+		AnyTimes()
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart)
+	assert.NoError(t, err)
+	engin := server.Engine()
+
+	attempts := 30
+	statuses := make(chan int, attempts)
+	wg := sync.WaitGroup{}
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login":"user", "password":"pass"}`))
+			engin.ServeHTTP(w, r)
+			statuses <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	locked := 0
+	for status := range statuses {
+		if status == http.StatusLocked {
+			locked++
+		}
+	}
+	assert.LessOrEqual(t, int(checked.Load()), cfg.Gophermart.LoginGuard.LockoutAfter)
+	assert.Equal(t, attempts-int(checked.Load()), locked)
+}
+
+func TestServer_handlerLogin_rehash(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		stored    gophermart.PasswordConfig
+		current   gophermart.PasswordConfig
+		rehash    bool
+		newPrefix string
+	}{
+		{
+			name:    "actual bcrypt",
+			stored:  gophermart.PasswordConfig{Algorithm: gophermart.HashBcrypt, BcryptCost: bcrypt.MinCost},
+			current: gophermart.PasswordConfig{Algorithm: gophermart.HashBcrypt, BcryptCost: bcrypt.MinCost},
+			rehash:  false,
+		},
+		{
+			name:      "outdated bcrypt cost",
+			stored:    gophermart.PasswordConfig{Algorithm: gophermart.HashBcrypt, BcryptCost: bcrypt.MinCost},
+			current:   gophermart.PasswordConfig{Algorithm: gophermart.HashBcrypt, BcryptCost: bcrypt.MinCost + 1},
+			rehash:    true,
+			newPrefix: "$2a$05$",
+		},
+		{
+			name:      "bcrypt to argon2id",
+			stored:    gophermart.PasswordConfig{Algorithm: gophermart.HashBcrypt, BcryptCost: bcrypt.MinCost},
+			current:   gophermart.PasswordConfig{Algorithm: gophermart.HashArgon2id, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1},
+			rehash:    true,
+			newPrefix: "$argon2id$v=19$m=64,t=1,p=1$",
+		},
+		{
+			name:    "actual argon2id",
+			stored:  gophermart.PasswordConfig{Algorithm: gophermart.HashArgon2id, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1},
+			current: gophermart.PasswordConfig{Algorithm: gophermart.HashArgon2id, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1},
+			rehash:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+			cfg.Gophermart.Password = tt.current
+
+			hashPass, err := gophermart.NewPasswordHasher(tt.stored).Hash("pass")
+			assert.NoError(t, err)
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByLogin(gomock.Any(), "user").
+				Return(model.User{ID: 1, Login: "user", PasswordHash: hashPass}, nil).
+				Times(1)
+			if tt.rehash {
+				storeMock.EXPECT().
+					UpdateUserPasswordHash(gomock.Any(), uint(1), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ uint, hash string) error {
+						assert.True(t, strings.HasPrefix(hash, tt.newPrefix), hash)
+						ok, needsRehash := gophermart.NewPasswordHasher(tt.current).Check("pass", hash)
+						assert.True(t, ok)
+						assert.False(t, needsRehash)
+						return nil
+					}).
+					Times(1)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart)
+			assert.NoError(t, err)
+			engin := server.Engine()
+
+			w := httptest.NewRecorder()
+			body := strings.NewReader(`{"login":"user", "password":"pass"}`)
+			r := httptest.NewRequest(http.MethodPost, "/api/user/login", body)
+
+			engin.ServeHTTP(w, r)
+
+			result := w.Result()
+			assert.Equal(t, http.StatusOK, result.StatusCode)
 			err = result.Body.Close()
 			assert.NoError(t, err)
 		})
 	}
 }
 
+func TestServer_handlerLogin_argon2Bounds(t *testing.T) {
+	ctx := context.Background()
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, 16))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{name: "memory", params: "m=4294967295,t=1,p=1"},
+		{name: "time", params: "m=64,t=4294967295,p=1"},
+		{name: "threads", params: "m=64,t=1,p=255"},
+		{name: "zero threads", params: "m=64,t=1,p=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByLogin(gomock.Any(), "user").
+				Return(model.User{ID: 1, Login: "user", PasswordHash: "$argon2id$v=19$" + tt.params + "$" + salt + "$" + key}, nil).
+				Times(1)
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart)
+			assert.NoError(t, err)
+			engin := server.Engine()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login":"user", "password":"pass"}`))
+
+			engin.ServeHTTP(w, r)
+
+			result := w.Result()
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.NoError(t, result.Body.Close())
+		})
+	}
+}
+
 func TestServer_handlerLoadUserOrders(t *testing.T) {
 	ctx := context.Background()
 	tests := []struct {
@@ -246,9 +527,13 @@ func TestServer_handlerLoadUserOrders(t *testing.T) {
 			cfg.Gophermart.GorutineEnabled = false
 
 			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), tt.userID).
+				Return(model.User{ID: tt.userID}, nil).
+				AnyTimes()
 			if !(tt.errstore == nil) || tt.name == "apply" {
 				storeMock.EXPECT().
-					UploadOrder(ctx, tt.userID, tt.order).
+					UploadOrder(gomock.Any(), tt.userID, tt.order, uint(0)).
 					Return(tt.errstore).
 					Times(1)
 			}
@@ -263,7 +548,7 @@ func TestServer_handlerLoadUserOrders(t *testing.T) {
 			r := httptest.NewRequest(http.MethodPost, "/api/user/orders", body)
 			jwtRest := jwt.New([]byte(cfg.Rest.Secret))
 			if tt.status != http.StatusUnauthorized {
-				signedCookie, err := jwtRest.Create(cookieKey, strconv.Itoa(int(tt.userID)))
+				signedCookie, err := userToken(jwtRest, strconv.Itoa(int(tt.userID)))
 				assert.NoError(t, err)
 				userCookie := &http.Cookie{
 					Name:  "token",
@@ -288,26 +573,87 @@ func TestServer_handlerLoadUserOrders(t *testing.T) {
 
 func TestServer_handlerGetUserOrders(t *testing.T) {
 	ctx := context.Background()
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
 	tests := []struct {
-		name     string
-		userID   uint
-		orders   []*model.Order
-		status   int
-		errstore error
+		errstore   error
+		name       string
+		query      string
+		orders     []*model.Order
+		listQuery  model.ListQuery
+		userID     uint
+		status     int
+		nextCursor bool
 	}{
 		{
+			// без параметров v1 отдает заказы от старых к новым, как до постраничной выдачи
 			name:   "ok",
 			userID: 1,
 			orders: []*model.Order{
 				{ID: 1, Number: "9278923470", UserID: 1},
 			},
+			listQuery: model.ListQuery{Asc: true},
+			status:    http.StatusOK,
+		},
+		{
+			name:   "sort desc",
+			userID: 1,
+			query:  "?sort=desc",
+			orders: []*model.Order{
+				{ID: 1, Number: "9278923470", UserID: 1},
+			},
 			status: http.StatusOK,
 		},
 		{
-			name:     "no content",
-			userID:   1,
-			status:   http.StatusNoContent,
-			errstore: errstore.ErrNotFoundData,
+			name:   "page",
+			userID: 1,
+			query:  "?limit=1&sort=asc&status=new,processed&from=2024-05-01T00:00:00Z",
+			orders: []*model.Order{
+				{ID: 1, Number: "9278923470", UserID: 1, CreatedAt: created},
+				{ID: 2, Number: "12345678903", UserID: 1, CreatedAt: created},
+			},
+			listQuery: model.ListQuery{
+				From:     time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+				Statuses: []model.OrderStatus{model.OrderStateNew, model.OrderStateProcessed},
+				Limit:    2,
+				Asc:      true,
+			},
+			status:     http.StatusOK,
+			nextCursor: true,
+		},
+		{
+			name:   "last page",
+			userID: 1,
+			query:  "?limit=2",
+			orders: []*model.Order{
+				{ID: 1, Number: "9278923470", UserID: 1, CreatedAt: created},
+			},
+			listQuery: model.ListQuery{Limit: 3, Asc: true},
+			status:    http.StatusOK,
+		},
+		{
+			name:   "bad limit",
+			userID: 1,
+			query:  "?limit=0",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "bad status",
+			userID: 1,
+			query:  "?status=DONE",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "bad cursor",
+			userID: 1,
+			query:  "?cursor=!!!",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:      "no content",
+			userID:    1,
+			status:    http.StatusNoContent,
+			errstore:  errstore.ErrNotFoundData,
+			listQuery: model.ListQuery{Asc: true},
 		},
 		{
 			name:   "unauthorize",
@@ -326,9 +672,13 @@ func TestServer_handlerGetUserOrders(t *testing.T) {
 			cfg.Gophermart.GorutineEnabled = false
 
 			storeMock := store.NewMockStore(ctrl)
-			if tt.errstore != nil || tt.name == "ok" {
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), tt.userID).
+				Return(model.User{ID: tt.userID}, nil).
+				AnyTimes()
+			if tt.errstore != nil || tt.orders != nil {
 				storeMock.EXPECT().
-					GetUserOrders(ctx, tt.userID).
+					GetUserOrders(gomock.Any(), tt.userID, tt.listQuery).
 					Return(tt.orders, tt.errstore).
 					Times(1)
 			}
@@ -339,11 +689,11 @@ func TestServer_handlerGetUserOrders(t *testing.T) {
 			engin := server.Engine()
 
 			w := httptest.NewRecorder()
-			r := httptest.NewRequest(http.MethodGet, "/api/user/orders", http.NoBody)
+			r := httptest.NewRequest(http.MethodGet, "/api/user/orders"+tt.query, http.NoBody)
 
 			jwtRest := jwt.New([]byte(cfg.Rest.Secret))
 			if tt.status != http.StatusUnauthorized {
-				signedCookie, err := jwtRest.Create(cookieKey, strconv.Itoa(int(tt.userID)))
+				signedCookie, err := userToken(jwtRest, strconv.Itoa(int(tt.userID)))
 				assert.NoError(t, err)
 				userCookie := &http.Cookie{
 					Name:  "token",
@@ -359,6 +709,10 @@ func TestServer_handlerGetUserOrders(t *testing.T) {
 			result := w.Result()
 
 			assert.Equal(t, tt.status, result.StatusCode)
+			assert.Equal(t, tt.nextCursor, result.Header.Get("X-Next-Cursor") != "")
+			if tt.nextCursor {
+				assert.Contains(t, result.Header.Get("Link"), "cursor="+result.Header.Get("X-Next-Cursor"))
+			}
 
 			err = result.Body.Close()
 			assert.NoError(t, err)
@@ -398,9 +752,13 @@ func TestServer_handlerGetUserBalance(t *testing.T) {
 			cfg.Gophermart.GorutineEnabled = false
 
 			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), tt.userID).
+				Return(model.User{ID: tt.userID}, nil).
+				AnyTimes()
 			if tt.name == "ok" {
 				storeMock.EXPECT().
-					GetUserBalance(ctx, tt.userID).
+					GetUserBalance(gomock.Any(), tt.userID).
 					Return(tt.balance, tt.errstore).
 					Times(1)
 			}
@@ -415,7 +773,7 @@ func TestServer_handlerGetUserBalance(t *testing.T) {
 
 			jwtRest := jwt.New([]byte(cfg.Rest.Secret))
 			if tt.status != http.StatusUnauthorized {
-				signedCookie, err := jwtRest.Create(cookieKey, strconv.Itoa(int(tt.userID)))
+				signedCookie, err := userToken(jwtRest, strconv.Itoa(int(tt.userID)))
 				assert.NoError(t, err)
 				userCookie := &http.Cookie{
 					Name:  "token",
@@ -485,12 +843,22 @@ func TestServer_handlerUserBalanceWithdraw(t *testing.T) {
 			cfg.Gophermart.GorutineEnabled = false
 
 			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), tt.userID).
+				Return(model.User{ID: tt.userID}, nil).
+				AnyTimes()
 			if tt.name == "ok" || tt.name == "no money" {
 				storeMock.EXPECT().
-					WithdrawFromUserBalance(ctx, tt.userID, tt.order, float32(1)).
+					WithdrawFromUserBalance(gomock.Any(), tt.userID, tt.order, float32(1), uint(0)).
 					Return(tt.errstore).
 					Times(1)
 			}
+			if tt.name == "ok" {
+				storeMock.EXPECT().
+					GetUserBalance(gomock.Any(), tt.userID).
+					Return(model.Balance{UserID: tt.userID}, nil).
+					Times(1)
+			}
 
 			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
 			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
@@ -503,7 +871,7 @@ func TestServer_handlerUserBalanceWithdraw(t *testing.T) {
 
 			jwtRest := jwt.New([]byte(cfg.Rest.Secret))
 			if tt.status != http.StatusUnauthorized {
-				signedCookie, err := jwtRest.Create(cookieKey, strconv.Itoa(int(tt.userID)))
+				signedCookie, err := userToken(jwtRest, strconv.Itoa(int(tt.userID)))
 				assert.NoError(t, err)
 				userCookie := &http.Cookie{
 					Name:  "token",
@@ -562,14 +930,18 @@ func TestServer_handlerUserWithdrawals(t *testing.T) {
 			cfg.Gophermart.GorutineEnabled = false
 
 			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), tt.userID).
+				Return(model.User{ID: tt.userID}, nil).
+				AnyTimes()
 			if tt.name != "unauthorize" {
 				storeMock.EXPECT().
-					GetUserBalance(ctx, tt.userID).
+					GetUserBalance(gomock.Any(), tt.userID).
 					Return(model.Balance{ID: 1, UserID: tt.userID}, tt.errstore).
 					Times(1)
 				if tt.name != "no content" {
 					storeMock.EXPECT().
-						GetWithdrawalsFromBalance(ctx, uint(1)).
+						GetWithdrawalsFromBalance(gomock.Any(), uint(1), model.ListQuery{Asc: true}).
 						Return([]*model.WithdrawBalance{{ID: 1, OderNumber: "123", Sum: float32(123)}}, tt.errstore).
 						Times(1)
 				}
@@ -585,7 +957,7 @@ func TestServer_handlerUserWithdrawals(t *testing.T) {
 
 			jwtRest := jwt.New([]byte(cfg.Rest.Secret))
 			if tt.status != http.StatusUnauthorized {
-				signedCookie, err := jwtRest.Create(cookieKey, strconv.Itoa(int(tt.userID)))
+				signedCookie, err := userToken(jwtRest, strconv.Itoa(int(tt.userID)))
 				assert.NoError(t, err)
 				userCookie := &http.Cookie{
 					Name:  "token",
@@ -607,3 +979,2380 @@ func TestServer_handlerUserWithdrawals(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_handlerChangePassword(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name         string
+		oldPassword  string
+		newPassword  string
+		tokenVersion uint
+		userID       uint
+		attempts     int
+		status       int
+	}{
+		{
+			name:        "ok",
+			userID:      1,
+			oldPassword: "pass",
+			newPassword: "new pass",
+			status:      http.StatusOK,
+		},
+		{
+			name:        "wrong old password",
+			userID:      1,
+			oldPassword: "wrong",
+			newPassword: "new pass",
+			status:      http.StatusForbidden,
+		},
+		{
+			name:        "repeated wrong old password",
+			userID:      1,
+			oldPassword: "wrong",
+			newPassword: "new pass",
+			attempts:    4,
+			status:      http.StatusTooManyRequests,
+		},
+		{
+			name:        "same password",
+			userID:      1,
+			oldPassword: "pass",
+			newPassword: "pass",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:         "revoked token",
+			userID:       1,
+			oldPassword:  "pass",
+			newPassword:  "new pass",
+			tokenVersion: 1,
+			status:       http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+			hashPass, err := gophermart.NewPasswordHasher(cfg.Gophermart.Password).Hash("pass")
+			assert.NoError(t, err)
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), tt.userID).
+				Return(model.User{ID: tt.userID, Login: "user", PasswordHash: hashPass}, nil).
+				AnyTimes()
+			if tt.status == http.StatusOK {
+				storeMock.EXPECT().
+					ChangeUserPassword(gomock.Any(), tt.userID, gomock.Any()).
+					Return(model.User{ID: tt.userID, Login: "user", TokenVersion: 1}, nil).
+					Times(1)
+				storeMock.EXPECT().
+					AddAuditEvent(gomock.Any(), gomock.Any()).
+					Return(nil).
+					Times(1)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			assert.NoError(t, err)
+			engin := server.Engine()
+
+			jwtRest := jwt.New([]byte(cfg.Rest.Secret))
+			signedCookie, err := jwtRest.CreateExpiring(map[string]string{
+				cookieKey:      strconv.Itoa(int(tt.userID)),
+				"TokenVersion": strconv.Itoa(int(tt.tokenVersion)),
+			}, time.Now().Add(time.Hour))
+			assert.NoError(t, err)
+
+			var result *http.Response
+			for range max(tt.attempts, 1) {
+				w := httptest.NewRecorder()
+				body := strings.NewReader(fmt.Sprintf(`{"old_password":%q, "new_password":%q}`, tt.oldPassword, tt.newPassword))
+				r := httptest.NewRequest(http.MethodPost, "/api/user/password", body)
+				r.AddCookie(&http.Cookie{
+					Name:  "token",
+					Value: signedCookie,
+					Path:  "/",
+				})
+
+				engin.ServeHTTP(w, r)
+
+				result = w.Result()
+				assert.NoError(t, result.Body.Close())
+			}
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.status == http.StatusOK {
+				assert.NotEmpty(t, result.Cookies())
+			}
+			if tt.status == http.StatusTooManyRequests {
+				assert.NotEmpty(t, result.Header.Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestServer_handlerAdmin(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		role   model.UserRole
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{
+			name:   "user has no access",
+			role:   model.RoleUser,
+			method: http.MethodGet,
+			path:   "/api/admin/users/2/balance",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "support views balance",
+			role:   model.RoleSupport,
+			method: http.MethodGet,
+			path:   "/api/admin/users/2/balance",
+			status: http.StatusOK,
+		},
+		{
+			name:   "support can not adjust balance",
+			role:   model.RoleSupport,
+			method: http.MethodPost,
+			path:   "/api/admin/users/2/balance/adjustments",
+			body:   `{"amount": 10, "reason": "compensation"}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "admin adjusts balance",
+			role:   model.RoleAdmin,
+			method: http.MethodPost,
+			path:   "/api/admin/users/2/balance/adjustments",
+			body:   `{"amount": 10, "reason": "compensation"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "adjustment without reason",
+			role:   model.RoleAdmin,
+			method: http.MethodPost,
+			path:   "/api/admin/users/2/balance/adjustments",
+			body:   `{"amount": 10}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "user not found",
+			role:   model.RoleAdmin,
+			method: http.MethodGet,
+			path:   "/api/admin/users/3/balance",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(1)).
+				Return(model.User{ID: 1, Role: tt.role}, nil).
+				AnyTimes()
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(2)).
+				Return(model.User{ID: 2, Role: model.RoleUser}, nil).
+				AnyTimes()
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(3)).
+				Return(model.User{}, errstore.ErrNotFoundData).
+				AnyTimes()
+			storeMock.EXPECT().
+				GetUserBalance(gomock.Any(), uint(2)).
+				Return(model.Balance{ID: 1, UserID: 2, Current: 100}, nil).
+				AnyTimes()
+			if tt.status == http.StatusOK && tt.method == http.MethodPost {
+				storeMock.EXPECT().
+					AdjustUserBalance(gomock.Any(), uint(2), gomock.Any(), gomock.Any()).
+					Return(model.Balance{ID: 1, UserID: 2, Current: 110}, nil).
+					Times(1)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			assert.NoError(t, err)
+			engin := server.Engine()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+
+			jwtRest := jwt.New([]byte(cfg.Rest.Secret))
+			signedCookie, err := userToken(jwtRest, "1")
+			assert.NoError(t, err)
+			r.AddCookie(&http.Cookie{
+				Name:  "token",
+				Value: signedCookie,
+				Path:  "/",
+			})
+
+			engin.ServeHTTP(w, r)
+
+			result := w.Result()
+
+			assert.Equal(t, tt.status, result.StatusCode)
+
+			err = result.Body.Close()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServer_handlerMerchant(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		key     func(apiKey string) string
+		revoked bool
+		status  int
+	}{
+		{
+			name:   "upload order",
+			path:   "/api/merchant/users/2/orders",
+			body:   "12345678903",
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "without key",
+			path:   "/api/merchant/users/2/orders",
+			body:   "12345678903",
+			key:    func(string) string { return "" },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong key",
+			path:   "/api/merchant/users/2/orders",
+			body:   "12345678903",
+			key:    func(apiKey string) string { return apiKey + "x" },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "revoked key",
+			path:    "/api/merchant/users/2/orders",
+			body:    "12345678903",
+			revoked: true,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:   "user not linked",
+			path:   "/api/merchant/users/3/orders",
+			body:   "12345678903",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "withdraw without scope",
+			path:   "/api/merchant/users/2/balance/withdraw",
+			body:   `{"order": "2377225624", "sum": 1}`,
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetMerchant(gomock.Any(), uint(1)).
+				Return(model.Merchant{ID: 1, Name: "shop"}, nil).
+				Times(1)
+			storeMock.EXPECT().
+				AddAuditEvent(gomock.Any(), gomock.Any()).
+				Return(nil).
+				Times(1)
+			var stored model.MerchantKey
+			storeMock.EXPECT().
+				CreateMerchantKey(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, key *model.MerchantKey) error {
+					key.ID = 1
+					if tt.revoked {
+						revokedAt := time.Now()
+						key.RevokedAt = &revokedAt
+					}
+					stored = *key
+					return nil
+				}).
+				Times(1)
+			storeMock.EXPECT().
+				GetMerchantKey(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, prefix string) (model.MerchantKey, error) {
+					assert.Equal(t, stored.Prefix, prefix)
+					return stored, nil
+				}).
+				AnyTimes()
+			storeMock.EXPECT().
+				IsMerchantUser(gomock.Any(), uint(1), uint(2)).
+				Return(true, nil).
+				AnyTimes()
+			storeMock.EXPECT().
+				IsMerchantUser(gomock.Any(), uint(1), uint(3)).
+				Return(false, nil).
+				AnyTimes()
+			if tt.status == http.StatusAccepted {
+				storeMock.EXPECT().
+					UploadOrder(gomock.Any(), uint(2), tt.body, uint(1)).
+					Return(nil).
+					Times(1)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			apiKey, _, err := mart.CreateMerchantKey(ctx, 1, 1, []model.MerchantScope{model.ScopeOrdersWrite})
+			assert.NoError(t, err)
+			if tt.key != nil {
+				apiKey = tt.key(apiKey)
+			}
+
+			server, err := rest.New(mart)
+			assert.NoError(t, err)
+			engin := server.Engine()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if apiKey != "" {
+				r.Header.Set("Authorization", "Bearer "+apiKey)
+			}
+
+			engin.ServeHTTP(w, r)
+
+			result := w.Result()
+
+			assert.Equal(t, tt.status, result.StatusCode)
+
+			err = result.Body.Close()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServer_handlerLogin_twoFactor(t *testing.T) {
+	ctx := context.Background()
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	validCode, err := totp.Code(secret, totp.Step(time.Now()))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		code     string
+		stepUsed bool
+		recovery bool
+		revoked  bool
+		status   int
+	}{
+		{
+			name:     "totp code",
+			code:     validCode,
+			stepUsed: true,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "replayed totp code",
+			code:     validCode,
+			stepUsed: false,
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:     "recovery code",
+			code:     "ABCDE-FGHIJ",
+			recovery: true,
+			status:   http.StatusOK,
+		},
+		{
+			name:   "used recovery code",
+			code:   "ABCDE-FGHIJ",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "password changed after first step",
+			code:    validCode,
+			revoked: true,
+			status:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+			cfg.Gophermart.Password.BcryptCost = bcrypt.MinCost
+			hashPass, err := gophermart.NewPasswordHasher(cfg.Gophermart.Password).Hash("pass")
+			assert.NoError(t, err)
+
+			user := model.User{ID: 1, Login: "user", PasswordHash: hashPass, TOTPSecret: secret, TOTPEnabled: true}
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByLogin(gomock.Any(), "user").
+				Return(user, nil).
+				Times(1)
+			if tt.revoked {
+				changed := user
+				changed.TokenVersion++
+				storeMock.EXPECT().
+					GetUserByID(gomock.Any(), uint(1)).
+					Return(changed, nil).
+					Times(1)
+			} else {
+				storeMock.EXPECT().
+					GetUserByID(gomock.Any(), uint(1)).
+					Return(user, nil).
+					Times(2)
+			}
+			if !tt.revoked && (tt.recovery || tt.code == validCode) {
+				storeMock.EXPECT().
+					UseTOTPStep(gomock.Any(), uint(1), gomock.Any()).
+					Return(tt.stepUsed, nil).
+					MaxTimes(1)
+			}
+			if !tt.revoked && tt.code != validCode {
+				storeMock.EXPECT().
+					UseRecoveryCode(gomock.Any(), uint(1), gomock.Any()).
+					Return(tt.recovery, nil).
+					Times(1)
+			}
+			if tt.recovery {
+				storeMock.EXPECT().
+					AddAuditEvent(gomock.Any(), gomock.Any()).
+					Return(nil).
+					Times(1)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			assert.NoError(t, err)
+			engin := server.Engine()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(`{"login":"user", "password":"pass"}`))
+			engin.ServeHTTP(w, r)
+			result := w.Result()
+			assert.Equal(t, http.StatusAccepted, result.StatusCode)
+			var challenge *http.Cookie
+			for _, cookie := range result.Cookies() {
+				if cookie.Name == "token" {
+					assert.Negative(t, cookie.MaxAge)
+				}
+				if cookie.Name == "mfa_token" {
+					challenge = cookie
+				}
+			}
+			assert.NoError(t, result.Body.Close())
+			if !assert.NotNil(t, challenge) {
+				return
+			}
+
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", strings.NewReader(fmt.Sprintf(`{"code":%q}`, tt.code)))
+			r.AddCookie(challenge)
+			engin.ServeHTTP(w, r)
+			result = w.Result()
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.status == http.StatusOK {
+				cookies := result.Cookies()
+				if assert.NotEmpty(t, cookies) {
+					assert.Equal(t, "token", cookies[0].Name)
+					assert.Positive(t, cookies[0].MaxAge)
+				}
+			}
+			assert.NoError(t, result.Body.Close())
+		})
+	}
+}
+
+func TestServer_handlerLogin_twoFactorExpiry(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	assert.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	mart := gophermart.New(ctx, cfg.Gophermart, store.NewMockStore(ctrl))
+	server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+	assert.NoError(t, err)
+	engin := server.Engine()
+
+	j := jwt.New([]byte(cfg.Rest.Secret))
+	claims := map[string]string{"MFAUserID": "1", "TokenVersion": "0"}
+	expired, err := j.CreateExpiring(claims, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	unlimited, err := j.CreateClaims(claims)
+	assert.NoError(t, err)
+
+	for name, token := range map[string]string{"expired": expired, "without exp": unlimited} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", strings.NewReader(`{"code":"123456"}`))
+			r.AddCookie(&http.Cookie{Name: "mfa_token", Value: token, Path: "/"})
+			engin.ServeHTTP(w, r)
+			result := w.Result()
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.NoError(t, result.Body.Close())
+		})
+	}
+}
+
+func TestServer_handlerConfirmTOTP_attempts(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	assert.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		Return(model.User{ID: 1, Login: "user", Role: model.RoleUser, TOTPSecret: secret}, nil).
+		AnyTimes()
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+	assert.NoError(t, err)
+	engin := server.Engine()
+	signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+	assert.NoError(t, err)
+
+	confirm := func() *http.Response {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/user/2fa/confirm", strings.NewReader(`{"code":"abcdef"}`))
+		r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+		engin.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	for range cfg.Gophermart.LoginGuard.DelayAfter {
+		result := confirm()
+		assert.Equal(t, http.StatusUnprocessableEntity, result.StatusCode)
+		assert.NoError(t, result.Body.Close())
+	}
+
+	result := confirm()
+	assert.Equal(t, http.StatusTooManyRequests, result.StatusCode)
+	assert.NotEmpty(t, result.Header.Get("Retry-After"))
+	assert.NoError(t, result.Body.Close())
+}
+
+func TestServer_handlerExportUserData(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	assert.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	now := time.Now()
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		Return(model.User{ID: 1, Login: "user", Role: model.RoleUser}, nil).
+		AnyTimes()
+	storeMock.EXPECT().
+		GetUserOrders(gomock.Any(), uint(1), model.ListQuery{}).
+		Return([]*model.Order{
+			{Number: "12345678903", Status: model.OrderStateProcessed, Accrual: 500, UpdatedAt: now.Add(-time.Hour)},
+		}, nil).
+		Times(1)
+	storeMock.EXPECT().
+		GetUserBalance(gomock.Any(), uint(1)).
+		Return(model.Balance{ID: 3, UserID: 1, Current: 390, Withdrawn: 100}, nil).
+		Times(1)
+	storeMock.EXPECT().
+		GetWithdrawalsFromBalance(gomock.Any(), uint(3), model.ListQuery{}).
+		Return([]*model.WithdrawBalance{{OderNumber: "2377225624", Sum: 100, UpdatedAt: now}}, nil).
+		Times(1)
+	storeMock.EXPECT().
+		GetBalanceAdjustments(gomock.Any(), uint(3)).
+		Return([]*model.BalanceAdjustment{{Reason: "compensation", Amount: -10, CreatedAt: now.Add(-time.Minute)}}, nil).
+		Times(1)
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+	assert.NoError(t, err)
+	engin := server.Engine()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/user/export", http.NoBody)
+	signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+	assert.NoError(t, err)
+	r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+
+	engin.ServeHTTP(w, r)
+
+	result := w.Result()
+	defer func() { assert.NoError(t, result.Body.Close()) }()
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "application/zip", result.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(result.Body)
+	assert.NoError(t, err)
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		f, err := file.Open()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		files[file.Name] = string(data)
+	}
+	assert.Len(t, files, 4)
+	assert.Contains(t, files["profile.json"], `"login": "user"`)
+	assert.Contains(t, files["orders.json"], `"number": "12345678903"`)
+	assert.Contains(t, files["withdrawals.json"], `"order": "2377225624"`)
+
+	balance := struct {
+		History []struct {
+			Type   string  `json:"type"`
+			Amount float32 `json:"amount"`
+		} `json:"history"`
+		Current float32 `json:"current"`
+	}{}
+	assert.NoError(t, json.Unmarshal([]byte(files["balance.json"]), &balance))
+	assert.Equal(t, float32(390), balance.Current)
+	if assert.Len(t, balance.History, 3) {
+		assert.Equal(t, "accrual", balance.History[0].Type)
+		assert.Equal(t, "adjustment", balance.History[1].Type)
+		assert.Equal(t, "withdrawal", balance.History[2].Type)
+		assert.Equal(t, float32(-100), balance.History[2].Amount)
+	}
+}
+
+func TestServer_handlerDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		password string
+		status   int
+	}{
+		{
+			name:     "deleted",
+			password: "pass",
+			status:   http.StatusOK,
+		},
+		{
+			name:     "wrong password",
+			password: "wrong",
+			status:   http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+			cfg.Gophermart.Password.BcryptCost = bcrypt.MinCost
+			hashPass, err := gophermart.NewPasswordHasher(cfg.Gophermart.Password).Hash("pass")
+			assert.NoError(t, err)
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(1)).
+				Return(model.User{ID: 1, Login: "user", PasswordHash: hashPass}, nil).
+				AnyTimes()
+			if tt.status == http.StatusOK {
+				storeMock.EXPECT().
+					AnonymizeUser(gomock.Any(), uint(1), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ uint, login string) error {
+						assert.NotContains(t, login, "user")
+						return nil
+					}).
+					Times(1)
+				storeMock.EXPECT().
+					AddAuditEvent(gomock.Any(), gomock.Any()).
+					Return(nil).
+					Times(1)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			assert.NoError(t, err)
+			engin := server.Engine()
+
+			w := httptest.NewRecorder()
+			body := strings.NewReader(fmt.Sprintf(`{"password":%q}`, tt.password))
+			r := httptest.NewRequest(http.MethodDelete, "/api/user", body)
+			signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+			assert.NoError(t, err)
+			r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+
+			engin.ServeHTTP(w, r)
+
+			result := w.Result()
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.status == http.StatusOK {
+				cookies := result.Cookies()
+				if assert.NotEmpty(t, cookies) {
+					assert.Negative(t, cookies[len(cookies)-1].MaxAge)
+				}
+			}
+			assert.NoError(t, result.Body.Close())
+		})
+	}
+}
+
+func TestServer_problemResponse(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		prepare func(storeMock *store.MockStore)
+		name    string
+		method  string
+		target  string
+		body    string
+		code    string
+		detail  string
+		status  int
+		auth    bool
+	}{
+		{
+			name:   "login not unique",
+			method: http.MethodPost,
+			target: "/api/user/register",
+			body:   `{"login":"user","password":"pass"}`,
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().
+					RegisterUser(gomock.Any(), "user", gomock.Any()).
+					Return(errstore.ErrLoginNotUnique).
+					Times(1)
+			},
+			status: http.StatusConflict,
+			code:   "login_not_unique",
+		},
+		{
+			name:   "bad credentials",
+			method: http.MethodPost,
+			target: "/api/user/login",
+			body:   `{"login":"user","password":"pass"}`,
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().
+					GetUserByLogin(gomock.Any(), "user").
+					Return(model.User{}, errstore.ErrNotFoundData).
+					Times(1)
+			},
+			status: http.StatusUnauthorized,
+			code:   "credentials_not_valid",
+		},
+		{
+			name:   "unauthorized",
+			method: http.MethodGet,
+			target: "/api/user/balance",
+			status: http.StatusUnauthorized,
+			code:   "unauthorized",
+		},
+		{
+			name:   "list query",
+			method: http.MethodGet,
+			target: "/api/user/orders?sort=up",
+			auth:   true,
+			status: http.StatusBadRequest,
+			code:   "list_query_not_valid",
+			detail: "list query not valid: sort must be asc or desc",
+		},
+		{
+			name:   "not enough balance",
+			method: http.MethodPost,
+			target: "/api/user/balance/withdraw",
+			body:   `{"order":"2377225624","sum":1}`,
+			auth:   true,
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().
+					WithdrawFromUserBalance(gomock.Any(), uint(1), "2377225624", float32(1), uint(0)).
+					Return(errstore.ErrBalansNotEnough).
+					Times(1)
+			},
+			status: http.StatusPaymentRequired,
+			code:   "balance_not_enough",
+		},
+		{
+			name:   "internal",
+			method: http.MethodGet,
+			target: "/api/user/balance",
+			auth:   true,
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().
+					GetUserBalance(gomock.Any(), uint(1)).
+					Return(model.Balance{}, errors.New("connection refused")).
+					Times(1)
+			},
+			status: http.StatusInternalServerError,
+			code:   "internal_error",
+		},
+		{
+			name:   "route not found",
+			method: http.MethodGet,
+			target: "/api/unknown",
+			status: http.StatusNotFound,
+			code:   "not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(1)).
+				Return(model.User{ID: 1}, nil).
+				AnyTimes()
+			if tt.prepare != nil {
+				tt.prepare(storeMock)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			assert.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.auth {
+				signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+				assert.NoError(t, err)
+				r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+			}
+
+			server.Engine().ServeHTTP(w, r)
+
+			result := w.Result()
+			defer func() { assert.NoError(t, result.Body.Close()) }()
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			assert.Equal(t, "application/problem+json", result.Header.Get("Content-Type"))
+
+			problem := struct {
+				Type   string `json:"type"`
+				Title  string `json:"title"`
+				Detail string `json:"detail"`
+				Code   string `json:"code"`
+				Status int    `json:"status"`
+			}{}
+			assert.NoError(t, json.NewDecoder(result.Body).Decode(&problem))
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, "urn:gophermart:problem:"+tt.code, problem.Type)
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.detail, problem.Detail)
+			assert.NotEmpty(t, problem.Title)
+		})
+	}
+}
+
+func TestServer_requestValidation(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		prepare     func(storeMock *store.MockStore)
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		code        string
+		fields      []string
+		status      int
+	}{
+		{
+			name:   "malformed json",
+			method: http.MethodPost,
+			target: "/api/user/register",
+			body:   `{"login":"user",`,
+			status: http.StatusBadRequest,
+			code:   "request_not_valid",
+		},
+		{
+			name:   "unknown field",
+			method: http.MethodPost,
+			target: "/api/user/login",
+			body:   `{"login":"user","password":"pass","remember":true}`,
+			status: http.StatusBadRequest,
+			code:   "request_not_valid",
+			fields: []string{"remember"},
+		},
+		{
+			name:   "wrong field type",
+			method: http.MethodPost,
+			target: "/api/user/balance/withdraw",
+			body:   `{"order":"2377225624","sum":"1"}`,
+			status: http.StatusBadRequest,
+			code:   "request_not_valid",
+			fields: []string{"sum"},
+		},
+		{
+			name:   "zero sum",
+			method: http.MethodPost,
+			target: "/api/user/balance/withdraw",
+			body:   `{"order":"","sum":0}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			fields: []string{"order", "sum"},
+		},
+		{
+			name:   "negative sum",
+			method: http.MethodPost,
+			target: "/api/user/balance/withdraw",
+			body:   `{"order":"2377225624","sum":-10}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			fields: []string{"sum"},
+		},
+		{
+			name:        "withdraw content type",
+			method:      http.MethodPost,
+			target:      "/api/user/balance/withdraw",
+			contentType: "text/plain",
+			body:        `{"order":"2377225624","sum":1}`,
+			status:      http.StatusUnsupportedMediaType,
+			code:        "content_type_not_supported",
+		},
+		{
+			name:        "order content type",
+			method:      http.MethodPost,
+			target:      "/api/user/orders",
+			contentType: "application/json",
+			body:        `12345678903`,
+			status:      http.StatusUnsupportedMediaType,
+			code:        "content_type_not_supported",
+		},
+		{
+			name:        "order not digits",
+			method:      http.MethodPost,
+			target:      "/api/user/orders",
+			contentType: "text/plain; charset=utf-8",
+			body:        `abc`,
+			status:      http.StatusUnprocessableEntity,
+			code:        "order_number_not_valid",
+		},
+		{
+			name:        "order with spaces",
+			method:      http.MethodPost,
+			target:      "/api/user/orders",
+			contentType: "text/plain",
+			body:        " 12345678903\n",
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().
+					UploadOrder(gomock.Any(), uint(1), "12345678903", uint(0)).
+					Return(nil).
+					Times(1)
+			},
+			status: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(1)).
+				Return(model.User{ID: 1}, nil).
+				AnyTimes()
+			if tt.prepare != nil {
+				tt.prepare(storeMock)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			assert.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+			assert.NoError(t, err)
+			r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+
+			server.Engine().ServeHTTP(w, r)
+
+			result := w.Result()
+			defer func() { assert.NoError(t, result.Body.Close()) }()
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.code == "" {
+				return
+			}
+
+			problem := struct {
+				Code   string `json:"code"`
+				Errors []struct {
+					Field   string `json:"field"`
+					Message string `json:"message"`
+				} `json:"errors"`
+			}{}
+			assert.NoError(t, json.NewDecoder(result.Body).Decode(&problem))
+			assert.Equal(t, tt.code, problem.Code)
+			fields := []string{}
+			for _, e := range problem.Errors {
+				fields = append(fields, e.Field)
+				assert.NotEmpty(t, e.Message)
+			}
+			if tt.fields == nil {
+				tt.fields = []string{}
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestServer_handlerGetUserOrder(t *testing.T) {
+	ctx := context.Background()
+	uploaded := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	order := model.Order{
+		ID:        7,
+		UserID:    1,
+		Number:    "12345678903",
+		Status:    model.OrderStateProcessed,
+		Accrual:   500,
+		CreatedAt: uploaded,
+	}
+	tests := []struct {
+		errstore error
+		name     string
+		history  []*model.OrderStatusChange
+		want     []string
+		status   int
+		auth     bool
+	}{
+		{
+			name: "ok",
+			auth: true,
+			history: []*model.OrderStatusChange{
+				{OrderID: 7, Status: model.OrderStateNew, CreatedAt: uploaded},
+				{OrderID: 7, Status: model.OrderStateProcessing, CreatedAt: uploaded.Add(time.Minute)},
+				{OrderID: 7, Status: model.OrderStateProcessed, Accrual: 500, CreatedAt: uploaded.Add(2 * time.Minute)},
+			},
+			want:   []string{"NEW", "PROCESSING", "PROCESSED"},
+			status: http.StatusOK,
+		},
+		{
+			name: "without upload record",
+			auth: true,
+			history: []*model.OrderStatusChange{
+				{OrderID: 7, Status: model.OrderStateProcessed, Accrual: 500, CreatedAt: uploaded.Add(time.Hour)},
+			},
+			want:   []string{"NEW", "PROCESSED"},
+			status: http.StatusOK,
+		},
+		{
+			name:     "not found",
+			auth:     true,
+			errstore: errstore.ErrNotFoundData,
+			status:   http.StatusNotFound,
+		},
+		{
+			name:   "unauthorize",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(1)).
+				Return(model.User{ID: 1}, nil).
+				AnyTimes()
+			if tt.auth {
+				storeMock.EXPECT().
+					GetUserOrder(gomock.Any(), uint(1), order.Number).
+					Return(order, tt.errstore).
+					Times(1)
+			}
+			if tt.history != nil {
+				storeMock.EXPECT().
+					GetOrderStatusHistory(gomock.Any(), order.ID).
+					Return(tt.history, nil).
+					Times(1)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			assert.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/user/orders/"+order.Number, http.NoBody)
+			if tt.auth {
+				signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+				assert.NoError(t, err)
+				r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+			}
+
+			server.Engine().ServeHTTP(w, r)
+
+			result := w.Result()
+			defer func() { assert.NoError(t, result.Body.Close()) }()
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			details := struct {
+				Accrual    *float32 `json:"accrual"`
+				Number     string   `json:"number"`
+				UploadedAt string   `json:"uploaded_at"`
+				History    []struct {
+					Accrual   *float32 `json:"accrual"`
+					Status    string   `json:"status"`
+					ChangedAt string   `json:"changed_at"`
+				} `json:"history"`
+			}{}
+			assert.NoError(t, json.NewDecoder(result.Body).Decode(&details))
+			assert.Equal(t, order.Number, details.Number)
+			assert.Equal(t, uploaded.Format(time.RFC3339), details.UploadedAt)
+			require.NotNil(t, details.Accrual)
+			assert.Equal(t, float32(500), *details.Accrual)
+			statuses := []string{}
+			for _, change := range details.History {
+				statuses = append(statuses, change.Status)
+			}
+			assert.Equal(t, tt.want, statuses)
+			assert.Equal(t, uploaded.Format(time.RFC3339), details.History[0].ChangedAt)
+			last := details.History[len(details.History)-1]
+			require.NotNil(t, last.Accrual)
+			assert.Equal(t, float32(500), *last.Accrual)
+		})
+	}
+}
+
+func TestServer_handlerLoadUserOrdersBatch(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		stored      map[string]model.OrderUploadResult
+		errstore    error
+		name        string
+		contentType string
+		body        string
+		toStore     []string
+		want        []string
+		status      int
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `["12345678903", "79927398713", "4561261212345467", "123", "12345678903"]`,
+			toStore:     []string{"12345678903", "79927398713", "4561261212345467"},
+			stored: map[string]model.OrderUploadResult{
+				"12345678903":      model.OrderUploadAccepted,
+				"79927398713":      model.OrderUploadAlreadyUploaded,
+				"4561261212345467": model.OrderUploadOwnedByAnotherUser,
+			},
+			want:   []string{"accepted", "already_uploaded", "owned_by_another_user", "invalid", "already_uploaded"},
+			status: http.StatusOK,
+		},
+		{
+			name:        "plain",
+			contentType: "text/plain",
+			body:        "12345678903\n\n 79927398713 \n",
+			toStore:     []string{"12345678903", "79927398713"},
+			stored: map[string]model.OrderUploadResult{
+				"12345678903": model.OrderUploadAccepted,
+				"79927398713": model.OrderUploadAccepted,
+			},
+			want:   []string{"accepted", "accepted"},
+			status: http.StatusOK,
+		},
+		{
+			name:        "all invalid",
+			contentType: "application/json",
+			body:        `["1", "abc"]`,
+			want:        []string{"invalid", "invalid"},
+			status:      http.StatusOK,
+		},
+		{
+			name:        "empty",
+			contentType: "application/json",
+			body:        `[]`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "numbers not strings",
+			contentType: "application/json",
+			body:        `[12345678903]`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "too many",
+			contentType: "text/plain",
+			body:        strings.Repeat("12345678903\n", 1001),
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "content type",
+			contentType: "application/xml",
+			body:        `<orders/>`,
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "store error",
+			contentType: "text/plain",
+			body:        "12345678903",
+			toStore:     []string{"12345678903"},
+			errstore:    errors.New("connection refused"),
+			status:      http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			assert.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(1)).
+				Return(model.User{ID: 1}, nil).
+				AnyTimes()
+			if tt.toStore != nil {
+				storeMock.EXPECT().
+					UploadOrders(gomock.Any(), uint(1), tt.toStore).
+					Return(tt.stored, tt.errstore).
+					Times(1)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			assert.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+			assert.NoError(t, err)
+			r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+
+			server.Engine().ServeHTTP(w, r)
+
+			result := w.Result()
+			defer func() { assert.NoError(t, result.Body.Close()) }()
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			uploads := []struct {
+				Number string `json:"number"`
+				Result string `json:"result"`
+			}{}
+			assert.NoError(t, json.NewDecoder(result.Body).Decode(&uploads))
+			got := []string{}
+			for _, upload := range uploads {
+				got = append(got, upload.Result)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServer_handlerUserEvents(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		Return(model.User{ID: 1}, nil).
+		AnyTimes()
+	storeMock.EXPECT().
+		AdjustUserBalance(gomock.Any(), uint(1), gomock.Any(), gomock.Any()).
+		Return(model.Balance{UserID: 1, Current: 100}, nil)
+	storeMock.EXPECT().
+		AdjustUserBalance(gomock.Any(), uint(1), gomock.Any(), gomock.Any()).
+		Return(model.Balance{UserID: 1, Current: 150}, nil)
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.Engine())
+	t.Cleanup(ts.Close)
+
+	signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+	require.NoError(t, err)
+	subscribe := func(lastEventID string) *http.Response {
+		reqCtx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		r, err := http.NewRequestWithContext(reqCtx, http.MethodGet, ts.URL+"/api/user/events", http.NoBody)
+		require.NoError(t, err)
+		r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+		if lastEventID != "" {
+			r.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := ts.Client().Do(r)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	readEvent := func(r *bufio.Reader) map[string]string {
+		event := map[string]string{}
+		for {
+			line, err := r.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimRight(line, "\n")
+			if line == "" {
+				return event
+			}
+			if key, value, ok := strings.Cut(line, ":"); ok && key != "" {
+				event[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	resp := subscribe("")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	_, err = mart.AdjustBalance(ctx, 2, 1, 100, "bonus")
+	require.NoError(t, err)
+	_, err = mart.AdjustBalance(ctx, 2, 1, 50, "bonus")
+	require.NoError(t, err)
+
+	stream := bufio.NewReader(resp.Body)
+	first := readEvent(stream)
+	assert.Equal(t, "balance", first["event"])
+	assert.JSONEq(t, `{"balance":{"current":100,"withdrawn":0}}`, first["data"])
+	second := readEvent(stream)
+	assert.JSONEq(t, `{"balance":{"current":150,"withdrawn":0}}`, second["data"])
+
+	t.Run("reconnect", func(t *testing.T) {
+		resp := subscribe(first["id"])
+		event := readEvent(bufio.NewReader(resp.Body))
+		assert.Equal(t, second["id"], event["id"])
+		assert.JSONEq(t, second["data"], event["data"])
+	})
+
+	t.Run("bad last event id", func(t *testing.T) {
+		resp := subscribe("abc")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("withdraw", func(t *testing.T) {
+		storeMock.EXPECT().
+			WithdrawFromUserBalance(gomock.Any(), uint(1), "2377225624", float32(50), uint(0)).
+			Return(nil)
+		storeMock.EXPECT().
+			GetUserBalance(gomock.Any(), uint(1)).
+			Return(model.Balance{UserID: 1, Current: 100, Withdrawn: 50}, nil)
+
+		resp := subscribe(second["id"])
+		require.NoError(t, mart.WithdrawFromBalanceUser(ctx, 1, "2377225624", 50))
+		event := readEvent(bufio.NewReader(resp.Body))
+		assert.Equal(t, "balance", event["event"])
+		assert.JSONEq(t, `{"balance":{"current":100,"withdrawn":50}}`, event["data"])
+	})
+}
+
+func TestServer_handlerUserWS(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		Return(model.User{ID: 1}, nil).
+		AnyTimes()
+	storeMock.EXPECT().
+		AdjustUserBalance(gomock.Any(), uint(1), gomock.Any(), gomock.Any()).
+		Return(model.Balance{UserID: 1, Current: 100}, nil)
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart,
+		rest.SetSecretKey([]byte(cfg.Rest.Secret)),
+		rest.SetWebSocket(rest.WebSocketConfig{PingInterval: 200 * time.Millisecond}),
+	)
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.Engine())
+	t.Cleanup(ts.Close)
+
+	signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+	require.NoError(t, err)
+	dial := func(t *testing.T, cookie string) (*websocket.Conn, *http.Response, error) {
+		header := http.Header{}
+		if cookie != "" {
+			header.Set("Cookie", "token="+cookie)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/user/ws", header)
+		if resp != nil {
+			t.Cleanup(func() { _ = resp.Body.Close() })
+		}
+		if conn != nil {
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+		return conn, resp, err
+	}
+
+	t.Run("unauthorize", func(t *testing.T) {
+		_, resp, err := dial(t, "")
+		require.ErrorIs(t, err, websocket.ErrBadHandshake)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("all connections", func(t *testing.T) {
+		pings := make(chan struct{}, 1)
+		first, _, err := dial(t, signedCookie)
+		require.NoError(t, err)
+		first.SetPingHandler(func(string) error {
+			select {
+			case pings <- struct{}{}:
+			default:
+			}
+			return nil
+		})
+		second, _, err := dial(t, signedCookie)
+		require.NoError(t, err)
+
+		// подписка создается после upgrade, ждем ее перед публикацией
+		time.Sleep(50 * time.Millisecond)
+		_, err = mart.AdjustBalance(ctx, 2, 1, 100, "bonus")
+		require.NoError(t, err)
+
+		for _, conn := range []*websocket.Conn{first, second} {
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+			message := struct {
+				Data json.RawMessage `json:"data"`
+				ID   string          `json:"id"`
+				Type string          `json:"type"`
+			}{}
+			require.NoError(t, conn.ReadJSON(&message))
+			assert.Equal(t, "balance", message.Type)
+			assert.NotEmpty(t, message.ID)
+			assert.JSONEq(t, `{"balance":{"current":100,"withdrawn":0}}`, string(message.Data))
+		}
+
+		go func() { _, _, _ = first.ReadMessage() }()
+		select {
+		case <-pings:
+		case <-time.After(time.Second):
+			t.Fatal("ping not received")
+		}
+	})
+}
+
+func TestServer_v2(t *testing.T) {
+	ctx := context.Background()
+	uploaded := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	processed := uploaded.Add(time.Minute)
+	tests := []struct {
+		setup  func(m *store.MockStore)
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+		status int
+	}{
+		{
+			name:   "empty orders",
+			method: http.MethodGet,
+			path:   "/api/v2/user/orders",
+			setup: func(m *store.MockStore) {
+				m.EXPECT().GetUserOrders(gomock.Any(), uint(1), model.ListQuery{}).Return(nil, errstore.ErrNotFoundData)
+			},
+			want:   `[]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "orders",
+			method: http.MethodGet,
+			path:   "/api/v2/user/orders",
+			setup: func(m *store.MockStore) {
+				m.EXPECT().GetUserOrders(gomock.Any(), uint(1), model.ListQuery{}).Return([]*model.Order{
+					{ID: 2, Number: "79927398713", Status: model.OrderStateProcessing,
+						CreatedAt: uploaded, UpdatedAt: processed},
+					// время обработки берется из истории статусов, а не из UpdatedAt
+					{ID: 1, Number: "12345678903", Status: model.OrderStateProcessed, Accrual: 729.98,
+						CreatedAt: uploaded, UpdatedAt: processed.Add(time.Hour), ProcessedAt: &processed},
+					// заказ обработан до появления истории статусов
+					{ID: 3, Number: "4561261212345467", Status: model.OrderStateInvalid,
+						CreatedAt: uploaded, UpdatedAt: processed},
+				}, nil)
+			},
+			want: `[
+				{"id": 2, "number": "79927398713", "status": "PROCESSING", "accrual": null,
+					"uploaded_at": "2024-05-01T10:00:00Z", "processed_at": null},
+				{"id": 1, "number": "12345678903", "status": "PROCESSED", "accrual": "729.98",
+					"uploaded_at": "2024-05-01T10:00:00Z", "processed_at": "2024-05-01T10:01:00Z"},
+				{"id": 3, "number": "4561261212345467", "status": "INVALID", "accrual": null,
+					"uploaded_at": "2024-05-01T10:00:00Z", "processed_at": null}
+			]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "balance",
+			method: http.MethodGet,
+			path:   "/api/v2/user/balance",
+			setup: func(m *store.MockStore) {
+				m.EXPECT().GetUserBalance(gomock.Any(), uint(1)).Return(model.Balance{Current: 500.5, Withdrawn: 42}, nil)
+			},
+			want:   `{"current": "500.50", "withdrawn": "42.00"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "empty withdrawals",
+			method: http.MethodGet,
+			path:   "/api/v2/user/withdrawals",
+			setup: func(m *store.MockStore) {
+				m.EXPECT().GetUserBalance(gomock.Any(), uint(1)).Return(model.Balance{}, errstore.ErrNotFoundData)
+			},
+			want:   `[]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "withdrawals",
+			method: http.MethodGet,
+			path:   "/api/v2/user/withdrawals",
+			setup: func(m *store.MockStore) {
+				m.EXPECT().GetUserBalance(gomock.Any(), uint(1)).Return(model.Balance{ID: 3}, nil)
+				m.EXPECT().GetWithdrawalsFromBalance(gomock.Any(), uint(3), model.ListQuery{}).Return([]*model.WithdrawBalance{
+					{ID: 4, OderNumber: "2377225624", Sum: 751, CreatedAt: processed},
+				}, nil)
+			},
+			want:   `[{"id": 4, "order": "2377225624", "sum": "751.00", "processed_at": "2024-05-01T10:01:00Z"}]`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "withdraw",
+			method: http.MethodPost,
+			path:   "/api/v2/user/balance/withdraw",
+			body:   `{"order": "2377225624", "sum": "42.50"}`,
+			setup: func(m *store.MockStore) {
+				m.EXPECT().WithdrawFromUserBalance(gomock.Any(), uint(1), "2377225624", float32(42.5), uint(0)).Return(nil)
+				m.EXPECT().GetUserBalance(gomock.Any(), uint(1)).Return(model.Balance{ID: 3}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "withdraw sum as number",
+			method: http.MethodPost,
+			path:   "/api/v2/user/balance/withdraw",
+			body:   `{"order": "2377225624", "sum": 42.5}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "withdraw sum not decimal",
+			method: http.MethodPost,
+			path:   "/api/v2/user/balance/withdraw",
+			body:   `{"order": "2377225624", "sum": "NaN"}`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "withdraw zero",
+			method: http.MethodPost,
+			path:   "/api/v2/user/balance/withdraw",
+			body:   `{"order": "2377225624", "sum": "0.00"}`,
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			require.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(1)).
+				Return(model.User{ID: 1}, nil).
+				AnyTimes()
+			if tt.setup != nil {
+				tt.setup(storeMock)
+			}
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+			require.NoError(t, err)
+			r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+
+			server.Engine().ServeHTTP(w, r)
+
+			result := w.Result()
+			defer func() { assert.NoError(t, result.Body.Close()) }()
+
+			assert.Equal(t, tt.status, result.StatusCode)
+			if tt.want != "" {
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.want, string(body))
+			}
+		})
+	}
+}
+
+func TestServer_ETag(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	version := uint64(1)
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		DoAndReturn(func(context.Context, uint) (model.User, error) {
+			return model.User{ID: 1, DataVersion: version}, nil
+		}).
+		AnyTimes()
+	storeMock.EXPECT().
+		GetUserBalance(gomock.Any(), uint(1)).
+		Return(model.Balance{Current: 500}, nil).
+		Times(3)
+	storeMock.EXPECT().
+		GetUserOrders(gomock.Any(), uint(1), model.ListQuery{Asc: true}).
+		Return(nil, errors.New("connection refused")).
+		Times(1)
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+	require.NoError(t, err)
+	signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+	require.NoError(t, err)
+
+	get := func(path, etag string) *http.Response {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		server.Engine().ServeHTTP(w, r)
+		result := w.Result()
+		t.Cleanup(func() { _ = result.Body.Close() })
+		return result
+	}
+
+	first := get("/api/user/balance", "")
+	assert.Equal(t, http.StatusOK, first.StatusCode)
+	etag := first.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "Accept-Encoding, Authorization, Cookie", first.Header.Get("Vary"))
+
+	cached := get("/api/user/balance", etag)
+	assert.Equal(t, http.StatusNotModified, cached.StatusCode)
+	assert.Equal(t, etag, cached.Header.Get("ETag"))
+	assert.Equal(t, "Accept-Encoding, Authorization, Cookie", cached.Header.Get("Vary"))
+
+	assert.NotEqual(t, etag, get("/api/v2/user/balance", etag).Header.Get("ETag"),
+		"другое представление тех же данных")
+
+	version++
+	changed := get("/api/user/balance", etag)
+	assert.Equal(t, http.StatusOK, changed.StatusCode)
+	assert.NotEqual(t, etag, changed.Header.Get("ETag"))
+
+	failed := get("/api/user/orders", "")
+	assert.Equal(t, http.StatusInternalServerError, failed.StatusCode)
+	assert.Empty(t, failed.Header.Get("ETag"))
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+	assert.Equal(t, rest.RateLimit{Limit: 120, Window: time.Minute}, cfg.Rest.RateLimit.User)
+
+	storeMock := store.NewMockStore(ctrl)
+	for _, id := range []uint{1, 2} {
+		storeMock.EXPECT().
+			GetUserByID(gomock.Any(), id).
+			Return(model.User{ID: id}, nil).
+			AnyTimes()
+	}
+	storeMock.EXPECT().
+		GetUserBalance(gomock.Any(), gomock.Any()).
+		Return(model.Balance{}, nil).
+		AnyTimes()
+
+	limit := rest.RateLimit{}
+	require.NoError(t, limit.UnmarshalText([]byte("2/1m")))
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart,
+		rest.SetSecretKey([]byte(cfg.Rest.Secret)),
+		rest.SetRateLimits(rest.RateLimitConfig{User: limit}),
+	)
+	require.NoError(t, err)
+
+	request := func(path, userID, ip string) *http.Response {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		r.RemoteAddr = ip + ":1234"
+		if userID != "" {
+			signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), userID)
+			require.NoError(t, err)
+			r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+		}
+		server.Engine().ServeHTTP(w, r)
+		result := w.Result()
+		t.Cleanup(func() { _ = result.Body.Close() })
+		return result
+	}
+
+	first := request("/api/user/balance", "1", "192.0.2.1")
+	assert.Equal(t, http.StatusOK, first.StatusCode)
+	assert.Equal(t, "2", first.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "1", first.Header.Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", first.Header.Get("RateLimit-Reset"))
+
+	// v1 и v2 считаются вместе, ключ по пользователю, а не по адресу
+	assert.Equal(t, http.StatusOK, request("/api/v2/user/balance", "1", "192.0.2.2").StatusCode)
+	limited := request("/api/user/balance", "1", "192.0.2.3")
+	assert.Equal(t, http.StatusTooManyRequests, limited.StatusCode)
+	assert.Equal(t, "0", limited.Header.Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, limited.Header.Get("Retry-After"))
+	problem := map[string]any{}
+	require.NoError(t, json.NewDecoder(limited.Body).Decode(&problem))
+	assert.Equal(t, "rate_limited", problem["code"])
+
+	assert.Equal(t, http.StatusOK, request("/api/user/balance", "2", "192.0.2.1").StatusCode,
+		"у другого пользователя свой лимит")
+
+	// без токена считается по адресу
+	assert.Equal(t, http.StatusUnauthorized, request("/api/user/balance", "", "192.0.2.9").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, request("/api/user/balance", "", "192.0.2.9").StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, request("/api/user/balance", "", "192.0.2.9").StatusCode)
+
+	// запросы магазина считаются по магазину: ключи одного магазина делят лимит с любых адресов
+	merchantKeys := map[string]uint{"key1aaaa": 10, "key2bbbb": 10, "key3cccc": 20}
+	for prefix, merchantID := range merchantKeys {
+		apiKey := "gm_" + prefix + "_secret"
+		sum := sha256.Sum256([]byte(apiKey))
+		storeMock.EXPECT().
+			GetMerchantKey(gomock.Any(), prefix).
+			Return(model.MerchantKey{MerchantID: merchantID, KeyHash: hex.EncodeToString(sum[:]),
+				Scopes: string(model.ScopeOrdersWrite)}, nil).
+			AnyTimes()
+	}
+	storeMock.EXPECT().IsMerchantUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	server, err = rest.New(mart,
+		rest.SetSecretKey([]byte(cfg.Rest.Secret)),
+		rest.SetRateLimits(rest.RateLimitConfig{Merchant: limit}),
+	)
+	require.NoError(t, err)
+	merchantRequest := func(prefix, ip string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/merchant/users/1/orders", strings.NewReader("12345678903"))
+		r.RemoteAddr = ip + ":1234"
+		r.Header.Set("Authorization", "Bearer gm_"+prefix+"_secret")
+		server.Engine().ServeHTTP(w, r)
+		result := w.Result()
+		_ = result.Body.Close()
+		return result.StatusCode
+	}
+	assert.Equal(t, http.StatusForbidden, merchantRequest("key1aaaa", "192.0.2.1"))
+	assert.Equal(t, http.StatusForbidden, merchantRequest("key2bbbb", "192.0.2.2"))
+	assert.Equal(t, http.StatusTooManyRequests, merchantRequest("key1aaaa", "192.0.2.3"))
+	assert.Equal(t, http.StatusForbidden, merchantRequest("key3cccc", "192.0.2.1"),
+		"у другого магазина с того же адреса свой лимит")
+
+	// подбор ключей считается по адресу до проверки ключа в базе
+	storeMock.EXPECT().
+		GetMerchantKey(gomock.Any(), "badkeyxx").
+		Return(model.MerchantKey{}, errstore.ErrNotFoundData).
+		Times(2)
+	assert.Equal(t, http.StatusUnauthorized, merchantRequest("badkeyxx", "192.0.2.8"))
+	assert.Equal(t, http.StatusUnauthorized, merchantRequest("badkeyxx", "192.0.2.8"))
+	assert.Equal(t, http.StatusTooManyRequests, merchantRequest("badkeyxx", "192.0.2.8"))
+}
+
+func TestServer_RequestID(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		Return(model.User{ID: 1}, nil).
+		AnyTimes()
+	storeMock.EXPECT().
+		GetUserBalance(gomock.Any(), uint(1)).
+		DoAndReturn(func(ctx context.Context, _ uint) (model.Balance, error) {
+			// идентификатор запроса доходит до хранилища через context
+			assert.Equal(t, "req-42", requestid.FromContext(ctx))
+			return model.Balance{}, errors.New("connection reset")
+		})
+
+	observed, logs := observer.New(zapcore.InfoLevel)
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart,
+		rest.SetSecretKey([]byte(cfg.Rest.Secret)),
+		rest.Logger(zap.New(observed)),
+	)
+	require.NoError(t, err)
+
+	request := func(requestID string, withToken bool) *http.Response {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/user/balance", http.NoBody)
+		if requestID != "" {
+			r.Header.Set(requestid.Header, requestID)
+		}
+		if withToken {
+			signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+			require.NoError(t, err)
+			r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+		}
+		server.Engine().ServeHTTP(w, r)
+		result := w.Result()
+		t.Cleanup(func() { _ = result.Body.Close() })
+		return result
+	}
+
+	generated := request("", false)
+	assert.Len(t, generated.Header.Get(requestid.Header), 32)
+
+	// неверный идентификатор заменяется новым
+	replaced := request("bad id\twith spaces", false)
+	assert.Len(t, replaced.Header.Get(requestid.Header), 32)
+	assert.NotEqual(t, generated.Header.Get(requestid.Header), replaced.Header.Get(requestid.Header))
+
+	logs.TakeAll()
+	failed := request("req-42", true)
+	assert.Equal(t, http.StatusInternalServerError, failed.StatusCode)
+	assert.Equal(t, "req-42", failed.Header.Get(requestid.Header))
+	problem := map[string]any{}
+	require.NoError(t, json.NewDecoder(failed.Body).Decode(&problem))
+	assert.Equal(t, "req-42", problem["request_id"])
+
+	entries := logs.All()
+	require.NotEmpty(t, entries)
+	for _, entry := range entries {
+		assert.Equal(t, "req-42", entry.ContextMap()[requestid.LogField], entry.Message)
+	}
+	assert.Equal(t, "Request", entries[len(entries)-1].Message)
+}
+
+func TestServer_Health(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	storeMock := store.NewMockStore(ctrl)
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart,
+		rest.SetSecretKey([]byte(cfg.Rest.Secret)),
+		rest.SetHealth(rest.HealthConfig{ReadyTimeout: time.Second}),
+	)
+	require.NoError(t, err)
+
+	request := func(path string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		server.Engine().ServeHTTP(w, r)
+		result := w.Result()
+		defer func() { _ = result.Body.Close() }()
+		body := map[string]any{}
+		require.NoError(t, json.NewDecoder(result.Body).Decode(&body))
+		return result.StatusCode, body
+	}
+
+	status, body := request("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", body["status"])
+
+	tests := []struct {
+		prepare    func()
+		body       map[string]any
+		name       string
+		statusCode int
+	}{
+		{
+			name: "ready",
+			prepare: func() {
+				storeMock.EXPECT().Ping(gomock.Any()).Return(nil)
+				storeMock.EXPECT().CheckMigrations(gomock.Any()).Return(nil)
+			},
+			statusCode: http.StatusOK,
+			body: map[string]any{
+				"status":        "ready",
+				"database":      map[string]any{"status": "ok"},
+				"migrations":    map[string]any{"status": "ok"},
+				"accrual":       map[string]any{"circuit_breaker": "closed"},
+				"shutting_down": false,
+			},
+		},
+		{
+			name: "database timeout",
+			prepare: func() {
+				storeMock.EXPECT().Ping(gomock.Any()).Return(fmt.Errorf("dial: %w", context.DeadlineExceeded))
+			},
+			statusCode: http.StatusServiceUnavailable,
+			body: map[string]any{
+				"status":        "not_ready",
+				"database":      map[string]any{"status": "fail", "error": "timeout"},
+				"migrations":    map[string]any{"status": "skipped"},
+				"accrual":       map[string]any{"circuit_breaker": "closed"},
+				"shutting_down": false,
+			},
+		},
+		{
+			name: "migrations not applied",
+			prepare: func() {
+				storeMock.EXPECT().Ping(gomock.Any()).Return(nil)
+				storeMock.EXPECT().CheckMigrations(gomock.Any()).
+					Return(fmt.Errorf("%w: orders.merchant_id", errstore.ErrMigrationsNotApplied))
+			},
+			statusCode: http.StatusServiceUnavailable,
+			body: map[string]any{
+				"status":   "not_ready",
+				"database": map[string]any{"status": "ok"},
+				"migrations": map[string]any{"status": "fail",
+					"error": "failed check migrations: migrations not applied: orders.merchant_id"},
+				"accrual":       map[string]any{"circuit_breaker": "closed"},
+				"shutting_down": false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.prepare()
+			status, body := request("/readyz")
+			assert.Equal(t, tt.statusCode, status)
+			assert.Equal(t, tt.body, body)
+		})
+	}
+
+	// во время остановки готовность снимается, даже если зависимости доступны
+	storeMock.EXPECT().Ping(gomock.Any()).Return(nil)
+	storeMock.EXPECT().CheckMigrations(gomock.Any()).Return(nil)
+	server.Drain(ctx)
+	status, body = request("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "not_ready", body["status"])
+	assert.Equal(t, true, body["shutting_down"])
+	status, _ = request("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestServer_CSV(t *testing.T) {
+	ctx := context.Background()
+	uploaded := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	orders := []*model.Order{
+		{Number: "12345678903", Status: model.OrderStateProcessed, Accrual: 500, UserID: 1, CreatedAt: uploaded},
+		{Number: "9278923470", Status: model.OrderStateProcessing, UserID: 2, CreatedAt: uploaded},
+	}
+	withdrawals := []*model.WithdrawBalance{
+		{OderNumber: "2377225624", Sum: 751.5, Balance: model.Balance{UserID: 2}, CreatedAt: uploaded},
+	}
+
+	tests := []struct {
+		prepare     func(storeMock *store.MockStore)
+		name        string
+		path        string
+		body        string
+		role        model.UserRole
+		contentType string
+		statusCode  int
+	}{
+		{
+			name: "user orders",
+			role: model.RoleUser,
+			path: "/api/user/orders.csv",
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().
+					StreamOrders(gomock.Any(), uint(1), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ uint, _ model.ListQuery, fn func(*model.Order) error) error {
+						return fn(orders[0])
+					})
+			},
+			statusCode:  http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body: "number,status,accrual,uploaded_at\n" +
+				"12345678903,PROCESSED,500.00,2024-05-01T10:00:00Z\n",
+		},
+		{
+			name: "user withdrawals empty",
+			role: model.RoleUser,
+			path: "/api/user/withdrawals.csv",
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().
+					StreamWithdrawals(gomock.Any(), uint(1), gomock.Any(), gomock.Any()).
+					Return(nil)
+			},
+			statusCode:  http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body:        "order,sum,processed_at\n",
+		},
+		{
+			name:        "invalid list query",
+			role:        model.RoleUser,
+			path:        "/api/user/orders.csv?status=UNKNOWN",
+			prepare:     func(*store.MockStore) {},
+			statusCode:  http.StatusBadRequest,
+			contentType: "application/problem+json",
+		},
+		{
+			name: "store fails before first row",
+			role: model.RoleUser,
+			path: "/api/user/withdrawals.csv",
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().
+					StreamWithdrawals(gomock.Any(), uint(1), gomock.Any(), gomock.Any()).
+					Return(errors.New("connection reset"))
+			},
+			statusCode:  http.StatusInternalServerError,
+			contentType: "application/problem+json",
+		},
+		{
+			name:        "user can not export all orders",
+			role:        model.RoleUser,
+			path:        "/api/admin/export/orders.csv",
+			prepare:     func(*store.MockStore) {},
+			statusCode:  http.StatusForbidden,
+			contentType: "application/problem+json",
+		},
+		{
+			name: "admin exports all orders",
+			role: model.RoleAdmin,
+			path: "/api/admin/export/orders.csv",
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().AddAuditEvent(gomock.Any(), gomock.Any()).Return(nil)
+				storeMock.EXPECT().
+					StreamOrders(gomock.Any(), uint(0), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ uint, _ model.ListQuery, fn func(*model.Order) error) error {
+						for _, order := range orders {
+							if err := fn(order); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
+			},
+			statusCode:  http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body: "user_id,number,status,accrual,uploaded_at\n" +
+				"1,12345678903,PROCESSED,500.00,2024-05-01T10:00:00Z\n" +
+				"2,9278923470,PROCESSING,,2024-05-01T10:00:00Z\n",
+		},
+		{
+			name: "admin exports all withdrawals",
+			role: model.RoleAdmin,
+			path: "/api/admin/export/withdrawals.csv",
+			prepare: func(storeMock *store.MockStore) {
+				storeMock.EXPECT().AddAuditEvent(gomock.Any(), gomock.Any()).Return(nil)
+				storeMock.EXPECT().
+					StreamWithdrawals(gomock.Any(), uint(0), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(
+						_ context.Context, _ uint, _ model.ListQuery, fn func(*model.WithdrawBalance) error,
+					) error {
+						return fn(withdrawals[0])
+					})
+			},
+			statusCode:  http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body: "user_id,order,sum,processed_at\n" +
+				"2,2377225624,751.50,2024-05-01T10:00:00Z\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cfg, err := config.Init()
+			require.NoError(t, err)
+			cfg.Gophermart.GorutineEnabled = false
+
+			storeMock := store.NewMockStore(ctrl)
+			storeMock.EXPECT().
+				GetUserByID(gomock.Any(), uint(1)).
+				Return(model.User{ID: 1, Role: tt.role}, nil).
+				AnyTimes()
+			tt.prepare(storeMock)
+
+			mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+			server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, http.NoBody)
+			signedCookie, err := userToken(jwt.New([]byte(cfg.Rest.Secret)), "1")
+			require.NoError(t, err)
+			r.AddCookie(&http.Cookie{Name: "token", Value: signedCookie, Path: "/"})
+			server.Engine().ServeHTTP(w, r)
+
+			result := w.Result()
+			defer func() { _ = result.Body.Close() }()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, tt.contentType, result.Header.Get("Content-Type"))
+			if tt.body != "" {
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.body, string(body))
+			}
+		})
+	}
+}
+
+func TestServer_BodyLimit(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByLogin(gomock.Any(), "user").
+		Return(model.User{ID: 1, PasswordHash: "wrong pass"}, nil).
+		AnyTimes()
+	storeMock.EXPECT().AddAuditEvent(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart, rest.SetBodyLimits(rest.BodyConfig{MaxSize: 1024, MaxDecompressedSize: 4096}))
+	require.NoError(t, err)
+
+	credentials := `{"login":"user", "password":"pass"}`
+	// пробелы после JSON допустимы, ими тело дополняется до нужного размера
+	padded := func(size int) []byte {
+		return []byte(credentials + strings.Repeat(" ", size-len(credentials)))
+	}
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		require.NoError(t, err)
+		_, err = zw.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+	random := make([]byte, 2048)
+	_, err = rand.Read(random)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		code       string
+		detail     string
+		body       []byte
+		statusCode int
+		gzip       bool
+		noLength   bool
+	}{
+		{
+			name:       "plain body within limit",
+			body:       padded(1024),
+			statusCode: http.StatusUnauthorized,
+			code:       "credentials_not_valid",
+		},
+		{
+			name:       "plain body over limit",
+			body:       padded(1025),
+			statusCode: http.StatusRequestEntityTooLarge,
+			code:       "body_too_large",
+		},
+		{
+			name:       "plain body over limit without content length",
+			body:       padded(4096),
+			noLength:   true,
+			statusCode: http.StatusRequestEntityTooLarge,
+			code:       "body_too_large",
+		},
+		{
+			name:       "gzip body within limit",
+			body:       compress(padded(4096)),
+			gzip:       true,
+			statusCode: http.StatusUnauthorized,
+			code:       "credentials_not_valid",
+		},
+		{
+			name:       "gzip bomb",
+			body:       compress(padded(512 << 10)),
+			gzip:       true,
+			statusCode: http.StatusRequestEntityTooLarge,
+			code:       "body_too_large",
+			detail:     "decompressed body exceeds 4096 bytes",
+		},
+		{
+			name:       "compressed body over limit",
+			body:       compress(random),
+			gzip:       true,
+			noLength:   true,
+			statusCode: http.StatusRequestEntityTooLarge,
+			code:       "body_too_large",
+			detail:     "request body exceeds 1024 bytes",
+		},
+		{
+			name:       "corrupted gzip",
+			body:       append(compress(padded(512))[:20], make([]byte, 64)...),
+			gzip:       true,
+			statusCode: http.StatusBadRequest,
+			code:       "request_not_valid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/user/login", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.gzip {
+				r.Header.Set("Content-Encoding", "gzip")
+			}
+			if tt.noLength {
+				r.ContentLength = -1
+			}
+			server.Engine().ServeHTTP(w, r)
+
+			result := w.Result()
+			defer func() { _ = result.Body.Close() }()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			problem := map[string]any{}
+			require.NoError(t, json.NewDecoder(result.Body).Decode(&problem))
+			assert.Equal(t, tt.code, problem["code"])
+			if tt.detail != "" {
+				assert.Contains(t, problem["detail"], tt.detail)
+			}
+		})
+	}
+}
+
+func TestServer_tokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg, err := config.Init()
+	require.NoError(t, err)
+	cfg.Gophermart.GorutineEnabled = false
+
+	storeMock := store.NewMockStore(ctrl)
+	storeMock.EXPECT().
+		GetUserByID(gomock.Any(), uint(1)).
+		Return(model.User{ID: 1}, nil).
+		AnyTimes()
+	storeMock.EXPECT().
+		GetUserBalance(gomock.Any(), uint(1)).
+		Return(model.Balance{Current: 500}, nil).
+		AnyTimes()
+
+	mart := gophermart.New(ctx, cfg.Gophermart, storeMock)
+	server, err := rest.New(mart, rest.SetSecretKey([]byte(cfg.Rest.Secret)))
+	require.NoError(t, err)
+
+	tokens := jwt.New([]byte(cfg.Rest.Secret))
+	valid, err := userToken(tokens, "1")
+	require.NoError(t, err)
+	expired, err := tokens.CreateExpiring(map[string]string{cookieKey: "1"}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	unlimited, err := tokens.Create(cookieKey, "1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "valid", token: valid, status: http.StatusOK},
+		{name: "expired", token: expired, status: http.StatusUnauthorized},
+		{name: "without expiry", token: unlimited, status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/user/balance", http.NoBody)
+			r.AddCookie(&http.Cookie{Name: "token", Value: tt.token, Path: "/"})
+			server.Engine().ServeHTTP(w, r)
+			result := w.Result()
+			assert.Equal(t, tt.status, result.StatusCode)
+			assert.NoError(t, result.Body.Close())
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/core/gophermart"
+	"go.uber.org/zap"
+)
+
+//	@Summary	Login second step
+//	@Schemes
+//	@Description	finish login with TOTP code or recovery code after `POST /api/user/login` answered 202
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		plain
+//	@Param			code	body	tTOTPCode	true	"code"
+//	@Success		200		"пользователь успешно аутентифицирован"
+//	@failure		400		"неверный формат запроса"
+//	@failure		401		"неверный код или истек срок ввода кода"
+//	@failure		423		"ввод кода временно заблокирован после серии неудачных попыток"
+//	@failure		429		"слишком частые попытки, повторить через Retry-After"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/user/login/2fa [post]
+//	@Router			/api/v2/user/login/2fa [post]
+func (s *Server) handlerLoginSecondFactor(c *gin.Context) {
+	userID, err := s.secondFactorUser(c)
+	if err != nil {
+		if !errors.Is(err, errUnauthorize) {
+			s.logger(c).Error("failed check second factor token", zap.Error(err))
+			writeProblem(c, 0, err)
+			return
+		}
+		writeProblem(c, 0, errUnauthorize)
+		return
+	}
+
+	jBody := tTOTPCode{}
+	if !s.bindJSON(c, &jBody) {
+		return
+	}
+
+	user, err := s.service.AuthorizationSecondFactor(c.Request.Context(), userID, jBody.Code, c.ClientIP())
+	if err != nil {
+		if status, ok := loginAttemptsStatus(c, err); ok {
+			writeProblem(c, status, err)
+			return
+		}
+		if errors.Is(err, gophermart.ErrTOTPCodeNotValid) ||
+			errors.Is(err, gophermart.ErrTOTPNotEnrolled) ||
+			errors.Is(err, errstore.ErrNotFoundData) {
+			writeProblem(c, http.StatusUnauthorized, gophermart.ErrTOTPCodeNotValid)
+			return
+		}
+		s.logger(c).Error("second factor authorization failed", zap.Uint("userID", userID), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	if err := s.setToken(c, user); err != nil {
+		s.logger(c).Error("failed set token after second factor", zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+	http.SetCookie(c.Writer, s.newNamedCookie(mfaCookieName, "", -1))
+
+	c.Writer.WriteHeader(http.StatusOK)
+}
+
+//	@Summary	Enroll TOTP
+//	@Schemes
+//	@Description	generate authenticator secret and provisioning URI, 2FA is enabled after confirmation
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	tTOTPEnrollment	"секрет создан, нужно подтвердить первый код"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		409	"двухфакторная аутентификация уже включена"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/user/2fa/enroll [post]
+func (s *Server) handlerEnrollTOTP(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	secret, uri, err := s.service.EnrollTOTP(c.Request.Context(), user.ID)
+	if err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed enroll totp", zap.Uint("userID", user.ID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tTOTPEnrollment{
+		Secret: secret,
+		URI:    uri,
+	})
+}
+
+//	@Summary	Confirm TOTP
+//	@Schemes
+//	@Description	confirm first authenticator code, enable 2FA and issue one-time recovery codes
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			code	body	tTOTPCode	true	"code"
+//	@Success		200		{object}	tRecoveryCodes	"двухфакторная аутентификация включена"
+//	@failure		400		"неверный формат запроса"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		409		"секрет не создан или двухфакторная аутентификация уже включена"
+//	@failure		422		"неверный код"
+//	@failure		423		"ввод кода временно заблокирован после серии неудачных попыток"
+//	@failure		429		"слишком частые попытки, повторить через Retry-After"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/user/2fa/confirm [post]
+func (s *Server) handlerConfirmTOTP(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	jBody := tTOTPCode{}
+	if !s.bindJSON(c, &jBody) {
+		return
+	}
+
+	codes, err := s.service.ConfirmTOTP(c.Request.Context(), user.ID, jBody.Code)
+	if err != nil {
+		if status, ok := loginAttemptsStatus(c, err); ok {
+			writeProblem(c, status, err)
+			return
+		}
+		if !isProblem(err) {
+			s.logger(c).Error("failed confirm totp", zap.Uint("userID", user.ID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tRecoveryCodes{RecoveryCodes: codes})
+}
+
+//	@Summary	Disable TOTP
+//	@Schemes
+//	@Description	disable 2FA, requires authenticator code or recovery code
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		plain
+//	@Param			code	body	tTOTPCode	true	"code"
+//	@Success		200		"двухфакторная аутентификация выключена"
+//	@failure		400		"неверный формат запроса"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		403		"неверный код"
+//	@failure		409		"двухфакторная аутентификация не включена"
+//	@failure		423		"ввод кода временно заблокирован после серии неудачных попыток"
+//	@failure		429		"слишком частые попытки, повторить через Retry-After"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/user/2fa [delete]
+func (s *Server) handlerDisableTOTP(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	jBody := tTOTPCode{}
+	if !s.bindJSON(c, &jBody) {
+		return
+	}
+
+	if err := s.service.DisableTOTP(c.Request.Context(), user.ID, jBody.Code); err != nil {
+		if status, ok := loginAttemptsStatus(c, err); ok {
+			writeProblem(c, status, err)
+			return
+		}
+		if errors.Is(err, gophermart.ErrTOTPCodeNotValid) {
+			writeProblem(c, http.StatusForbidden, err)
+			return
+		}
+		if !isProblem(err) {
+			s.logger(c).Error("failed disable totp", zap.Uint("userID", user.ID), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusOK)
+}
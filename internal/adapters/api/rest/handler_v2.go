@@ -0,0 +1,166 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"go.uber.org/zap"
+)
+
+//	@Summary	List user orders
+//	@Schemes
+//	@Description	get user orders, v2: пустой список вместо 204, суммы строками, время загрузки и обработки, id заказа
+//	@Tags			v2
+//	@Produce		json
+//	@Param			limit	query	integer	false	"размер страницы, от 1 до 1000"
+//	@Param			cursor	query	string	false	"курсор следующей страницы из заголовка X-Next-Cursor"
+//	@Param			sort	query	string	false	"порядок по времени загрузки: desc (по умолчанию) или asc"
+//	@Param			from	query	string	false	"загруженные не раньше, RFC3339"
+//	@Param			to		query	string	false	"загруженные раньше, RFC3339"
+//	@Param			status	query	string	false	"статусы через запятую: NEW,PROCESSING,INVALID,PROCESSED"
+//	@Success		200		{array}	tOrderV2	"заказы пользователя, возможно пустой список"
+//	@Success		304		"данные не менялись, ETag совпал с If-None-Match"
+//	@failure		400		"неверные параметры списка"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/v2/user/orders [get]
+func (s *Server) handlerV2GetUserOrders(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	query, ok := s.listQuery(c, true)
+	if !ok {
+		return
+	}
+
+	orders, next, err := s.service.GetUserOrders(c.Request.Context(), user.ID, query)
+	if err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		s.logger(c).Error("failed get orders by user", zap.Uint("userID", user.ID), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	setNextCursor(c, next)
+	c.JSON(http.StatusOK, newOrdersV2(orders))
+}
+
+//	@Summary	User order
+//	@Schemes
+//	@Description	get user order with status history, v2
+//	@Tags			v2
+//	@Produce		json
+//	@Param			number	path		string	true	"номер заказа"
+//	@Success		200		{object}	tOrderDetailsV2	"заказ и история статусов"
+//	@Success		304		"данные не менялись, ETag совпал с If-None-Match"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		404		"заказ не найден"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/v2/user/orders/{number} [get]
+func (s *Server) handlerV2GetUserOrder(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	number := c.Param("number")
+	order, history, err := s.service.GetUserOrder(c.Request.Context(), user.ID, number)
+	if err != nil {
+		if !isProblem(err) {
+			s.logger(c).Error("failed get order", zap.Uint("userID", user.ID), zap.String("number", number), zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newOrderDetailsV2(&order, history))
+}
+
+//	@Summary	User balance
+//	@Schemes
+//	@Description	get user balance, v2: суммы строками
+//	@Tags			v2
+//	@Produce		json
+//	@Success		200	{object}	tBalanceV2	"баланс пользователя"
+//	@Success		304	"данные не менялись, ETag совпал с If-None-Match"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/v2/user/balance [get]
+func (s *Server) handlerV2GetUserBalance(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	balance, err := s.service.GetUserBalance(c.Request.Context(), user.ID)
+	if err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		s.logger(c).Error("failed getting balance by user", zap.Uint("userID", user.ID), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newBalanceV2(&balance))
+}
+
+//	@Summary	Withdraw from user balans
+//	@Schemes
+//	@Description	Withdraw from user balans, v2: сумма строкой
+//	@Tags			v2
+//	@Accept			json
+//	@Param			withdraw	body	tWithdrawV2	true	"withdraw"
+//	@Produce		json
+//	@Success		200	"успешная обработка запроса"
+//	@failure		400	"неверный формат запроса"
+//	@failure		401	"пользователь не авторизован"
+//	@failure		402	"на счету недостаточно средств"
+//	@failure		415	"тело запроса не application/json"
+//	@failure		422	"неверный номер заказа или сумма"
+//	@failure		500	"внутренняя ошибка сервера"
+//	@Router			/api/v2/user/balance/withdraw [post]
+func (s *Server) handlerV2UserBalanceWithdraw(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	withdraw := tWithdrawV2{}
+	if !s.bindJSON(c, &withdraw) {
+		return
+	}
+
+	sum, _ := parseDecimal(withdraw.Sum)
+	err := s.service.WithdrawFromBalanceUser(c.Request.Context(), user.ID, withdraw.Order, sum)
+	s.withdrawResponse(c, err)
+}
+
+//	@Summary	User withdrawals
+//	@Schemes
+//	@Description	get user withdrawals, v2: пустой список вместо 204, суммы строками, id списания
+//	@Tags			v2
+//	@Produce		json
+//	@Param			limit	query	integer	false	"размер страницы, от 1 до 1000"
+//	@Param			cursor	query	string	false	"курсор следующей страницы из заголовка X-Next-Cursor"
+//	@Param			sort	query	string	false	"порядок по времени списания: desc (по умолчанию) или asc"
+//	@Param			from	query	string	false	"списания не раньше, RFC3339"
+//	@Param			to		query	string	false	"списания раньше, RFC3339"
+//	@Success		200		{array}	tWithdrawalV2	"списания пользователя, возможно пустой список"
+//	@Success		304		"данные не менялись, ETag совпал с If-None-Match"
+//	@failure		400		"неверные параметры списка"
+//	@failure		401		"пользователь не авторизован"
+//	@failure		500		"внутренняя ошибка сервера"
+//	@Router			/api/v2/user/withdrawals [get]
+func (s *Server) handlerV2UserWithdrawals(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	query, ok := s.listQuery(c, false)
+	if !ok {
+		return
+	}
+
+	withdrawals, next, err := s.service.GetWithdrawalsByUser(c.Request.Context(), user.ID, query)
+	if err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		s.logger(c).Error("failed getting withdrawals by user", zap.Uint("userID", user.ID), zap.Error(err))
+		writeProblem(c, 0, err)
+		return
+	}
+
+	setNextCursor(c, next)
+	c.JSON(http.StatusOK, newWithdrawalsV2(withdrawals))
+}
+
+// isOrderFinal сообщает, что система расчета начислений закончила обработку заказа.
+func isOrderFinal(status model.OrderStatus) bool {
+	return status == model.OrderStateProcessed || status == model.OrderStateInvalid
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+var (
+	wsReadLimit int64 = 512
+	wsUpgrader        = websocket.Upgrader{}
+)
+
+// tWSMessage событие пользователя в WebSocket, поля как у событий /api/user/events.
+type tWSMessage struct {
+	Data tEvent `json:"data"`
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+//	@Summary	User events over WebSocket
+//	@Schemes
+//	@Description	те же события, что /api/user/events, в сообщениях {"id", "type", "data"}. Сервер шлет ping раз в WS_PING_INTERVAL, при отставании клиента закрывает соединение с кодом 1013
+//	@Tags			events
+//	@Param			last_event_id	query	integer	false	"идентификатор последнего полученного события"
+//	@Success		101				{object}	tWSMessage	"соединение установлено"
+//	@failure		400				"неверный last_event_id или запрос не WebSocket"
+//	@failure		401				"пользователь не авторизован"
+//	@Router			/api/user/ws [get]
+func (s *Server) handlerUserWS(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	lastEventID, ok := readLastEventID(c)
+	if !ok {
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade сам отвечает клиенту ошибкой
+		s.logger(c).Debug("failed upgrade to websocket", zap.Uint("userID", user.ID), zap.Error(err))
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	events, cancel := s.service.SubscribeEvents(user.ID, lastEventID)
+	defer cancel()
+
+	done := make(chan struct{})
+	go s.wsRead(conn, done)
+
+	ping := time.NewTicker(s.webSocket.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-events:
+			if !ok {
+				// подписка закрыта из-за отставания клиента или остановки сервиса
+				s.wsClose(c, conn, websocket.CloseTryAgainLater, "reconnect with last_event_id")
+				return
+			}
+			if err := conn.SetWriteDeadline(time.Now().Add(s.webSocket.WriteTimeout)); err != nil {
+				return
+			}
+			err := conn.WriteJSON(tWSMessage{
+				ID:   strconv.FormatUint(event.ID, 10),
+				Type: string(event.Type),
+				Data: newEvent(&event),
+			})
+			if err != nil {
+				s.logger(c).Debug("failed write websocket message", zap.Uint("userID", user.ID), zap.Error(err))
+				return
+			}
+		case <-ping.C:
+			deadline := time.Now().Add(s.webSocket.WriteTimeout)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsRead читает соединение, чтобы обрабатывать pong и close от клиента. Сообщения клиента
+// не используются. Если клиент не ответил на два ping подряд, соединение закрывается.
+func (s *Server) wsRead(conn *websocket.Conn, done chan<- struct{}) {
+	defer close(done)
+
+	pongWait := 2 * s.webSocket.PingInterval
+	conn.SetReadLimit(wsReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) wsClose(c *gin.Context, conn *websocket.Conn, code int, reason string) {
+	deadline := time.Now().Add(s.webSocket.WriteTimeout)
+	if err := conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline); err != nil {
+		s.logger(c).Debug("failed close websocket", zap.Error(err))
+	}
+}
@@ -0,0 +1,153 @@
+package rest
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+)
+
+var (
+	errListQueryNotValid = errors.New("list query not valid")
+
+	listLimitMax     = 1000
+	nextCursorHeader = "X-Next-Cursor"
+	orderStatuses    = map[model.OrderStatus]bool{
+		model.OrderStateNew:        true,
+		model.OrderStateProcessing: true,
+		model.OrderStateInvalid:    true,
+		model.OrderStateProcessed:  true,
+	}
+)
+
+// parseListQuery разбирает параметры списка: limit, cursor, sort (asc или desc),
+// from и to в формате RFC3339, status через запятую, если withStatus.
+// Без параметров выбираются все записи от новых к старым, v1 меняет порядок через listQueryV1.
+func parseListQuery(c *gin.Context, withStatus bool) (model.ListQuery, error) {
+	query := model.ListQuery{}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > listLimitMax {
+			return query, fmt.Errorf("%w: limit must be in [1, %d]", errListQueryNotValid, listLimitMax)
+		}
+		query.Limit = limit
+	}
+
+	switch c.Query("sort") {
+	case "", "desc":
+	case "asc":
+		query.Asc = true
+	default:
+		return query, fmt.Errorf("%w: sort must be asc or desc", errListQueryNotValid)
+	}
+
+	var err error
+	if query.From, err = parseListTime(c, "from"); err != nil {
+		return query, err
+	}
+	if query.To, err = parseListTime(c, "to"); err != nil {
+		return query, err
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		if query.After, err = decodeCursor(v); err != nil {
+			return query, err
+		}
+	}
+
+	if v := c.Query("status"); v != "" {
+		if !withStatus {
+			return query, fmt.Errorf("%w: status filter not supported", errListQueryNotValid)
+		}
+		for _, item := range strings.Split(v, ",") {
+			status := model.OrderStatus(strings.ToUpper(strings.TrimSpace(item)))
+			if !orderStatuses[status] {
+				return query, fmt.Errorf("%w: unknown status `%s`", errListQueryNotValid, item)
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+
+	return query, nil
+}
+
+func parseListTime(c *gin.Context, name string) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, fmt.Errorf("%w: %s must be in RFC3339 format", errListQueryNotValid, name)
+	}
+	return t, nil
+}
+
+func encodeCursor(cursor model.ListCursor) string {
+	raw := strconv.FormatInt(cursor.CreatedAt.UnixNano(), 10) + "." + strconv.FormatUint(uint64(cursor.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*model.ListCursor, error) {
+	errCursor := fmt.Errorf("%w: cursor not valid", errListQueryNotValid)
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, errCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errCursor
+	}
+	i, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return nil, errCursor
+	}
+
+	return &model.ListCursor{CreatedAt: time.Unix(0, n), ID: uint(i)}, nil
+}
+
+// setNextCursor сообщает курсор следующей страницы в заголовке X-Next-Cursor
+// и ссылку на нее в заголовке Link.
+func setNextCursor(c *gin.Context, next *model.ListCursor) {
+	if next == nil {
+		return
+	}
+	cursor := encodeCursor(*next)
+	u := *c.Request.URL
+	q := u.Query()
+	q.Set("cursor", cursor)
+	u.RawQuery = q.Encode()
+
+	c.Header(nextCursorHeader, cursor)
+	c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", u.RequestURI()))
+}
+
+// listQuery разбирает параметры списка и отвечает 400, если они неверны.
+func (s *Server) listQuery(c *gin.Context, withStatus bool) (model.ListQuery, bool) {
+	query, err := parseListQuery(c, withStatus)
+	if err != nil {
+		writeProblem(c, 0, err)
+		return query, false
+	}
+	return query, true
+}
+
+// listQueryV1 разбирает параметры списков /api/user. Без параметра sort v1 сохраняет прежний порядок
+// от старых записей к новым.
+func (s *Server) listQueryV1(c *gin.Context, withStatus bool) (model.ListQuery, bool) {
+	query, ok := s.listQuery(c, withStatus)
+	if ok && c.Query("sort") == "" {
+		query.Asc = true
+	}
+	return query, ok
+}
@@ -2,11 +2,17 @@ package rest
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"github.com/playmixer/gophermart/internal/core/gophermart"
+	"github.com/playmixer/gophermart/pkg/requestid"
 	"go.uber.org/zap"
 )
 
@@ -19,27 +25,116 @@ func (s *Server) Authentication() gin.HandlerFunc {
 		userID, err := s.checkAuth(c)
 		if err != nil {
 			if !errors.Is(err, errUnauthorize) {
-				c.Writer.WriteHeader(http.StatusInternalServerError)
-			} else {
-				c.Writer.WriteHeader(http.StatusUnauthorized)
+				s.logger(c).Error("failed authenticate user", zap.Error(err))
 			}
-			c.Abort()
+			writeProblem(c, 0, err)
+			return
 		}
 
-		if err == nil && userID == 0 {
-			c.Writer.WriteHeader(http.StatusUnauthorized)
-			c.Abort()
+		if userID == 0 {
+			writeProblem(c, 0, errUnauthorize)
+			return
 		}
 
 		c.Next()
 	}
 }
 
+// RequireRole пропускает только пользователей с одной из ролей. Роль берется из базы,
+// поэтому должен вызываться после Authentication.
+func (s *Server) RequireRole(roles ...model.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			writeProblem(c, 0, errUnauthorize)
+			return
+		}
+		if !slices.Contains(roles, user.Role) {
+			s.logger(c).Info("access denied by role",
+				zap.Uint("userID", user.ID),
+				zap.String("role", string(user.Role)),
+				zap.String("uri", c.Request.RequestURI),
+			)
+			writeProblem(c, 0, errForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// MerchantAuthentication проверяет API ключ магазина из заголовка `Authorization: Bearer <key>`.
+// Каждый вызов магазина пишется в лог с идентификатором магазина.
+func (s *Server) MerchantAuthentication() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || apiKey == "" {
+			writeProblem(c, 0, gophermart.ErrMerchantKeyNotValid)
+			return
+		}
+
+		key, err := s.service.AuthenticateMerchant(c.Request.Context(), apiKey)
+		if err != nil {
+			if !isProblem(err) {
+				s.logger(c).Error("failed authenticate merchant", zap.Error(err))
+			}
+			writeProblem(c, 0, err)
+			return
+		}
+		c.Set(ctxMerchantKey, key)
+
+		c.Next()
+
+		s.logger(c).Info("merchant call",
+			zap.Uint("merchantID", key.MerchantID),
+			zap.Uint("keyID", key.ID),
+			zap.String("uri", c.Request.RequestURI),
+			zap.Int("status", c.Writer.Status()),
+		)
+	}
+}
+
+// RequireScope пропускает только запросы магазина с разрешением scope.
+// Должен вызываться после MerchantAuthentication.
+func (s *Server) RequireScope(scope model.MerchantScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := currentMerchantKey(c)
+		if !ok {
+			writeProblem(c, 0, gophermart.ErrMerchantKeyNotValid)
+			return
+		}
+		if !gophermart.HasScope(key, scope) {
+			writeProblem(c, 0, fmt.Errorf("%w: scope `%s` required", errForbidden, scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequestID принимает идентификатор запроса из заголовка X-Request-ID или создает новый,
+// если заголовка нет или он неверный. Идентификатор сохраняется в контексте запроса
+// и возвращается клиенту в том же заголовке.
+func (s *Server) RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Header(requestid.Header, id)
+		c.Next()
+	}
+}
+
+// logger возвращает лог с идентификатором текущего запроса.
+func (s *Server) logger(c *gin.Context) *zap.Logger {
+	return requestid.Logger(c.Request.Context(), s.log)
+}
+
 func (s *Server) Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		c.Next()
-		s.log.Info(
+		s.logger(c).Info(
 			"Request",
 			zap.String("uri", c.Request.RequestURI),
 			zap.Duration("duration", time.Since(start)),
@@ -50,19 +145,37 @@ func (s *Server) Logger() gin.HandlerFunc {
 	}
 }
 
+// BodyLimit ограничивает тело запроса в том виде, как оно передано клиентом. Тело с Content-Length
+// больше лимита отклоняется сразу, без Content-Length превышение обнаруживается при чтении.
+func (s *Server) BodyLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > s.body.MaxSize {
+			writeProblem(c, 0, fmt.Errorf("%w: body exceeds %d bytes", errBodyTooLarge, s.body.MaxSize))
+			return
+		}
+		c.Request.Body = limitReadCloser{
+			limitReader: newLimitReader(c.Request.Body, "request", s.body.MaxSize),
+			Closer:      c.Request.Body,
+		}
+		c.Next()
+	}
+}
+
 func (s *Server) GzipDecompress() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if ok := strings.Contains(c.Request.Header.Get("Content-Encoding"), "gzip"); ok {
-			gr, err := NewGzipReader(c.Request.Body)
+			gr, err := NewGzipReader(c.Request.Body, s.body.MaxDecompressedSize)
 			if err != nil {
-				c.Writer.WriteHeader(http.StatusBadRequest)
-				c.Abort()
+				if !errors.Is(err, errBodyTooLarge) {
+					err = fmt.Errorf("%w: body is not valid gzip", errRequestNotValid)
+				}
+				writeProblem(c, 0, err)
 				return
 			}
 			c.Request.Body = gr
 			defer func() {
 				if err := gr.Close(); err != nil {
-					s.log.Info("failed close gzip reader", zap.Error(err))
+					s.logger(c).Info("failed close gzip reader", zap.Error(err))
 				}
 			}()
 		}
@@ -72,15 +185,21 @@ func (s *Server) GzipDecompress() gin.HandlerFunc {
 
 func (s *Server) GzipCompress() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if ok := strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip"); ok {
+		// после upgrade соединение передается WebSocket, сжимать нечего
+		gzipAccepted := strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip")
+		if gzipAccepted && !websocket.IsWebSocketUpgrade(c.Request) {
 			c.Writer.Header().Set("Content-Encoding", "gzip")
 
 			cw := NewGzipWriter(c)
 			c.Writer = cw
 
 			defer func() {
+				// ответы 204 и 304 без тела, сжимать нечего
+				if status := c.Writer.Status(); status == http.StatusNoContent || status == http.StatusNotModified {
+					return
+				}
 				if err := cw.writer.Close(); err != nil {
-					s.log.Info("failed close gzip writer", zap.Error(err))
+					s.logger(c).Info("failed close gzip writer", zap.Error(err))
 				}
 			}()
 		}
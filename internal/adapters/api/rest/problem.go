@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/core/gophermart"
+	"github.com/playmixer/gophermart/pkg/requestid"
+)
+
+var (
+	errRequestNotValid     = errors.New("request not valid")
+	errCredentialsNotValid = errors.New("credentials not valid")
+	errForbidden           = errors.New("forbidden")
+	errInternal            = errors.New("internal error")
+
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:gophermart:problem:"
+)
+
+// tProblem тело ответа с ошибкой в формате RFC 7807. Code повторяет окончание Type
+// и не меняется между версиями API, клиентам стоит ориентироваться на него.
+// RequestID совпадает с заголовком X-Request-ID и помогает найти запрос в логах.
+type tProblem struct {
+	Type      string        `json:"type"`
+	Title     string        `json:"title"`
+	Detail    string        `json:"detail,omitempty"`
+	Code      string        `json:"code"`
+	RequestID string        `json:"request_id,omitempty"`
+	Errors    []tFieldError `json:"errors,omitempty"`
+	Status    int           `json:"status"`
+}
+
+type problemType struct {
+	err    error
+	code   string
+	title  string
+	status int
+	detail bool
+}
+
+// problemTypes сопоставляет ошибки сервиса и хранилища с кодами ошибок API.
+// Ошибки проверяются по порядку через errors.Is. Для ошибок с detail текст ошибки
+// передается клиенту, остальные ошибки могут содержать внутренние подробности.
+var problemTypes = []problemType{
+	{err: errUnauthorize, status: http.StatusUnauthorized, code: "unauthorized",
+		title: "Пользователь не авторизован"},
+	{err: errCredentialsNotValid, status: http.StatusUnauthorized, code: "credentials_not_valid",
+		title: "Неверная пара логин/пароль"},
+	{err: errForbidden, status: http.StatusForbidden, code: "forbidden",
+		title: "Недостаточно прав"},
+	{err: errRequestNotValid, status: http.StatusBadRequest, code: "request_not_valid",
+		title: "Неверный формат запроса", detail: true},
+	{err: errListQueryNotValid, status: http.StatusBadRequest, code: "list_query_not_valid",
+		title: "Неверные параметры списка", detail: true},
+	{err: errContentTypeNotSupported, status: http.StatusUnsupportedMediaType, code: "content_type_not_supported",
+		title: "Неподдерживаемый тип содержимого", detail: true},
+	{err: errValidation, status: http.StatusUnprocessableEntity, code: "validation_failed",
+		title: "Неверные значения полей"},
+	{err: errBodyTooLarge, status: http.StatusRequestEntityTooLarge, code: "body_too_large",
+		title: "Слишком большое тело запроса", detail: true},
+	{err: errRateLimited, status: http.StatusTooManyRequests, code: "rate_limited",
+		title: "Слишком много запросов", detail: true},
+
+	{err: errstore.ErrLoginNotUnique, status: http.StatusConflict, code: "login_not_unique",
+		title: "Логин уже занят"},
+	{err: errstore.ErrNotFoundData, status: http.StatusNotFound, code: "not_found",
+		title: "Данные не найдены"},
+	{err: errstore.ErrOrderWasCreatedAnotherUser, status: http.StatusConflict, code: "order_owned_by_another_user",
+		title: "Номер заказа уже был загружен другим пользователем"},
+	{err: errstore.ErrBalansNotEnough, status: http.StatusPaymentRequired, code: "balance_not_enough",
+		title: "На счету недостаточно средств"},
+	{err: errstore.ErrMerchantNotUnique, status: http.StatusConflict, code: "merchant_not_unique",
+		title: "Магазин с таким названием уже есть"},
+
+	{err: gophermart.ErrLoginNotValid, status: http.StatusBadRequest, code: "login_not_valid",
+		title: "Неверный формат логина"},
+	{err: gophermart.ErrPasswordNotValid, status: http.StatusBadRequest, code: "password_not_valid",
+		title: "Неверный формат пароля"},
+	{err: gophermart.ErrPasswordNotEquale, status: http.StatusForbidden, code: "password_not_equal",
+		title: "Неверный текущий пароль"},
+	{err: gophermart.ErrPasswordNotChanged, status: http.StatusBadRequest, code: "password_not_changed",
+		title: "Новый пароль совпадает с текущим"},
+	{err: gophermart.ErrOrderNumberNotValid, status: http.StatusUnprocessableEntity, code: "order_number_not_valid",
+		title: "Неверный формат номера заказа"},
+	{err: gophermart.ErrSumNotValid, status: http.StatusUnprocessableEntity, code: "sum_not_valid",
+		title: "Сумма списания должна быть больше нуля"},
+	{err: gophermart.ErrRoleNotValid, status: http.StatusBadRequest, code: "role_not_valid",
+		title: "Неизвестная роль"},
+	{err: gophermart.ErrAdjustmentNotValid, status: http.StatusBadRequest, code: "adjustment_not_valid",
+		title: "Неверная корректировка баланса"},
+	{err: gophermart.ErrLoginThrottled, status: http.StatusTooManyRequests, code: "login_throttled",
+		title: "Слишком частые попытки входа"},
+	{err: gophermart.ErrLoginLocked, status: http.StatusLocked, code: "login_locked",
+		title: "Вход временно заблокирован"},
+	{err: gophermart.ErrMerchantNotValid, status: http.StatusBadRequest, code: "merchant_not_valid",
+		title: "Неверное название магазина"},
+	{err: gophermart.ErrScopeNotValid, status: http.StatusBadRequest, code: "scope_not_valid",
+		title: "Неизвестное разрешение ключа"},
+	{err: gophermart.ErrMerchantKeyNotValid, status: http.StatusUnauthorized, code: "merchant_key_not_valid",
+		title: "Ключ магазина неверный или отозван"},
+	{err: gophermart.ErrMerchantNotLinked, status: http.StatusForbidden, code: "merchant_not_linked",
+		title: "Магазин не связан с пользователем"},
+	{err: gophermart.ErrTOTPAlreadyEnabled, status: http.StatusConflict, code: "totp_already_enabled",
+		title: "Двухфакторная аутентификация уже включена"},
+	{err: gophermart.ErrTOTPNotEnrolled, status: http.StatusConflict, code: "totp_not_enrolled",
+		title: "Двухфакторная аутентификация не подключена"},
+	{err: gophermart.ErrTOTPCodeNotValid, status: http.StatusUnprocessableEntity, code: "totp_code_not_valid",
+		title: "Неверный код"},
+}
+
+var problemInternal = problemType{
+	err: errInternal, status: http.StatusInternalServerError, code: "internal_error",
+	title: "Внутренняя ошибка сервера",
+}
+
+func lookupProblem(err error) (problemType, bool) {
+	for _, item := range problemTypes {
+		if errors.Is(err, item.err) {
+			return item, true
+		}
+	}
+	return problemInternal, false
+}
+
+// isProblem сообщает, что ошибка ожидаема и имеет свой код ошибки API.
+// Остальные ошибки отдаются клиенту как internal_error и должны попадать в лог.
+func isProblem(err error) bool {
+	_, ok := lookupProblem(err)
+	return ok
+}
+
+func newProblem(status int, err error) tProblem {
+	pt, _ := lookupProblem(err)
+
+	p := tProblem{
+		Type:   problemTypePrefix + pt.code,
+		Title:  pt.title,
+		Code:   pt.code,
+		Status: pt.status,
+	}
+	if pt.detail {
+		p.Detail = err.Error()
+	}
+	var fieldsErr *fieldsError
+	if errors.As(err, &fieldsErr) {
+		p.Errors = fieldsErr.fields
+	}
+	if status > 0 {
+		p.Status = status
+	}
+
+	return p
+}
+
+// writeProblem прерывает обработку запроса и отвечает ошибкой в формате RFC 7807.
+// Код ошибки определяется по err, status больше нуля заменяет статус по умолчанию
+// там, где ошибка означает для эндпоинта другое.
+func writeProblem(c *gin.Context, status int, err error) {
+	p := newProblem(status, err)
+	p.RequestID = requestid.FromContext(c.Request.Context())
+	c.Writer.Header().Del(etagHeader)
+	c.Header("Content-Type", problemContentType)
+	c.Abort()
+	c.Render(p.Status, render.JSON{Data: p})
+}
@@ -0,0 +1,156 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errRateLimited = errors.New("rate limited")
+
+	rateLimitPruneInterval = time.Minute
+)
+
+// RateLimit лимит запросов за окно в формате `120/1m`. Пустое значение или `0` отключает лимит.
+type RateLimit struct {
+	Window time.Duration
+	Limit  int
+}
+
+func (r *RateLimit) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	if value == "" || value == "0" {
+		*r = RateLimit{}
+		return nil
+	}
+	sLimit, sWindow, ok := strings.Cut(value, "/")
+	if !ok {
+		return fmt.Errorf("rate limit `%s` must be in format <requests>/<window>", value)
+	}
+	limit, err := strconv.Atoi(sLimit)
+	if err != nil || limit < 0 {
+		return fmt.Errorf("rate limit `%s` has wrong number of requests", value)
+	}
+	window, err := time.ParseDuration(sWindow)
+	if err != nil || window <= 0 {
+		return fmt.Errorf("rate limit `%s` has wrong window", value)
+	}
+	*r = RateLimit{Limit: limit, Window: window}
+	return nil
+}
+
+func (r RateLimit) enabled() bool {
+	return r.Limit > 0 && r.Window > 0
+}
+
+// RateLimitConfig лимиты запросов по группам маршрутов. User общий для /api/user и /api/v2/user.
+type RateLimitConfig struct {
+	User     RateLimit `env:"RATE_LIMIT_USER" envDefault:"120/1m"`
+	Admin    RateLimit `env:"RATE_LIMIT_ADMIN" envDefault:"300/1m"`
+	Merchant RateLimit `env:"RATE_LIMIT_MERCHANT" envDefault:"600/1m"`
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimiter считает запросы по ключу в фиксированных окнах.
+type rateLimiter struct {
+	lastPrune time.Time
+	mu        *sync.Mutex
+	windows   map[string]*rateWindow
+	now       func() time.Time
+	cfg       RateLimit
+}
+
+func newRateLimiter(cfg RateLimit) *rateLimiter {
+	return &rateLimiter{
+		mu:      &sync.Mutex{},
+		windows: make(map[string]*rateWindow),
+		now:     time.Now,
+		cfg:     cfg,
+	}
+}
+
+// allow учитывает запрос и возвращает остаток лимита и время до начала следующего окна.
+func (rl *rateLimiter) allow(key string) (remaining int, reset time.Duration, ok bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.prune(now)
+
+	w, found := rl.windows[key]
+	if !found || now.Sub(w.start) >= rl.cfg.Window {
+		w = &rateWindow{start: now}
+		rl.windows[key] = w
+	}
+	reset = w.start.Add(rl.cfg.Window).Sub(now)
+	if w.count >= rl.cfg.Limit {
+		return 0, reset, false
+	}
+	w.count++
+
+	return rl.cfg.Limit - w.count, reset, true
+}
+
+func (rl *rateLimiter) prune(now time.Time) {
+	if now.Sub(rl.lastPrune) < rateLimitPruneInterval {
+		return
+	}
+	rl.lastPrune = now
+	for key, w := range rl.windows {
+		if now.Sub(w.start) >= rl.cfg.Window {
+			delete(rl.windows, key)
+		}
+	}
+}
+
+// RateLimit ограничивает число запросов к группе маршрутов, ключ выбирает rateLimitKey. В ответах передаются
+// заголовки RateLimit-Limit, RateLimit-Remaining и RateLimit-Reset, при превышении лимита
+// ответ 429 с Retry-After. Стоит первым в группе, до проверки пользователя или ключа в базе,
+// поэтому запросы с неверными учетными данными тоже считаются по IP адресу. В группе магазинов
+// второй лимит после MerchantAuthentication считает запросы по магазину.
+func (s *Server) RateLimit(cfg RateLimit) gin.HandlerFunc {
+	if !cfg.enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	limiter := newRateLimiter(cfg)
+
+	return func(c *gin.Context) {
+		remaining, reset, ok := limiter.allow(s.rateLimitKey(c))
+		resetSeconds := strconv.Itoa(int(math.Ceil(reset.Seconds())))
+		c.Header("RateLimit-Limit", strconv.Itoa(cfg.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("RateLimit-Reset", resetSeconds)
+		if !ok {
+			c.Header("Retry-After", resetSeconds)
+			writeProblem(c, 0, fmt.Errorf("%w: %d requests per %s", errRateLimited, cfg.Limit, cfg.Window))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitKey считает запросы магазина по магазину, запросы с действительным токеном по пользователю,
+// остальные по IP адресу клиента. Все ключи одного магазина и все адреса одного пользователя делят лимит.
+func (s *Server) rateLimitKey(c *gin.Context) string {
+	if key, ok := currentMerchantKey(c); ok {
+		return "merchant:" + strconv.FormatUint(uint64(key.MerchantID), 10)
+	}
+	if userID, _, err := s.tokenClaims(c); err == nil {
+		return "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return "ip:" + c.ClientIP()
+}
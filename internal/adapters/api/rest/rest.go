@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -21,25 +24,79 @@ import (
 )
 
 var (
-	cookieName = "token"
-	cookieKey  = "UserID"
+	cookieName      = "token"
+	mfaCookieName   = "mfa_token"
+	mfaUserKey      = "MFAUserID"
+	cookieKey       = "UserID"
+	tokenVersionKey = "TokenVersion"
+	tokenRoleKey    = "Role"
+	ctxUserKey      = "user"
+	ctxMerchantKey  = "merchantKey"
 )
 
 type gophermartI interface {
 	Register(ctx context.Context, login, password string) error
-	Authorization(ctx context.Context, login, password string) (model.User, error)
+	Authorization(ctx context.Context, login, password, ip string) (model.User, error)
 	UploadOrder(ctx context.Context, userID uint, orderNumber string) error
-	GetUserOrders(ctx context.Context, userID uint) ([]*model.Order, error)
+	UploadOrders(ctx context.Context, userID uint, orderNumbers []string) ([]model.OrderUpload, error)
+	GetUserOrders(ctx context.Context, userID uint, query model.ListQuery) ([]*model.Order, *model.ListCursor, error)
+	GetUserOrder(ctx context.Context, userID uint, number string) (model.Order, []*model.OrderStatusChange, error)
 	GetUserBalance(ctx context.Context, userID uint) (model.Balance, error)
 	WithdrawFromBalanceUser(ctx context.Context, userID uint, order string, sum float32) error
-	GetWithdrawalsByUser(ctx context.Context, userID uint) ([]*model.WithdrawBalance, error)
+	GetWithdrawalsByUser(
+		ctx context.Context, userID uint, query model.ListQuery,
+	) ([]*model.WithdrawBalance, *model.ListCursor, error)
+	GetUser(ctx context.Context, userID uint) (model.User, error)
+	ChangePassword(ctx context.Context, userID uint, oldPassword, newPassword string) (model.User, error)
+	SearchUsers(ctx context.Context, login string) ([]*model.User, error)
+	SetUserRole(ctx context.Context, actorID, userID uint, role model.UserRole) error
+	AdjustBalance(ctx context.Context, actorID, userID uint, amount float32, reason string) (model.Balance, error)
+	CreateMerchant(ctx context.Context, actorID uint, name string) (model.Merchant, error)
+	CreateMerchantKey(
+		ctx context.Context,
+		actorID, merchantID uint,
+		scopes []model.MerchantScope,
+	) (string, model.MerchantKey, error)
+	RevokeMerchantKey(ctx context.Context, actorID, merchantID, keyID uint) error
+	LinkMerchantUser(ctx context.Context, actorID, merchantID, userID uint) error
+	UnlinkMerchantUser(ctx context.Context, actorID, merchantID, userID uint) error
+	AuthenticateMerchant(ctx context.Context, apiKey string) (model.MerchantKey, error)
+	MerchantUploadOrder(ctx context.Context, merchantID, userID uint, orderNumber string) error
+	MerchantWithdraw(ctx context.Context, merchantID, userID uint, order string, sum float32) error
+	AuthorizationSecondFactor(ctx context.Context, userID uint, code, ip string) (model.User, error)
+	EnrollTOTP(ctx context.Context, userID uint) (secret, uri string, err error)
+	ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID uint, code string) error
+	ExportUserData(ctx context.Context, userID uint) (gophermart.UserExport, error)
+	DeleteUser(ctx context.Context, userID uint, password string) error
+	SubscribeEvents(userID uint, lastEventID uint64) (events <-chan gophermart.Event, cancel func())
+	PingStore(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
+	AccrualState() string
+	StreamUserOrders(ctx context.Context, userID uint, query model.ListQuery, fn func(*model.Order) error) error
+	StreamUserWithdrawals(
+		ctx context.Context, userID uint, query model.ListQuery, fn func(*model.WithdrawBalance) error,
+	) error
+	StreamAllOrders(ctx context.Context, actorID uint, query model.ListQuery, fn func(*model.Order) error) error
+	StreamAllWithdrawals(
+		ctx context.Context, actorID uint, query model.ListQuery, fn func(*model.WithdrawBalance) error,
+	) error
 }
 
 type Server struct {
-	srv     *http.Server
-	log     *zap.Logger
-	service gophermartI
-	secret  []byte
+	srv             *http.Server
+	log             *zap.Logger
+	service         gophermartI
+	jwt             *jwt.JWT
+	trustedProxies  []string
+	cookie          CookieConfig
+	cookieSameSite  http.SameSite
+	eventsKeepAlive time.Duration
+	webSocket       WebSocketConfig
+	rateLimits      RateLimitConfig
+	health          HealthConfig
+	body            BodyConfig
+	draining        atomic.Bool
 }
 
 type Option func(*Server)
@@ -58,7 +115,108 @@ func SetAddress(address string) Option {
 
 func SetSecretKey(key []byte) Option {
 	return func(s *Server) {
-		s.secret = key
+		s.jwt = jwt.New(key)
+	}
+}
+
+// SetKeySet задает набор ключей подписи токенов с поддержкой ротации.
+func SetKeySet(keys *jwt.KeySet) Option {
+	return func(s *Server) {
+		s.jwt = jwt.NewWithKeySet(keys)
+	}
+}
+
+// NewKeySet собирает набор ключей из конфигурации. Secret остается ключом без `kid`,
+// чтобы токены, выданные до включения ротации, продолжали приниматься.
+func NewKeySet(cfg *Config) (*jwt.KeySet, error) {
+	keys, err := jwt.ParseKeys(cfg.JWTKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed parse jwt keys: %w", err)
+	}
+	pemKeys, err := jwt.ParsePEMFiles(cfg.JWTPEMKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed load jwt pem keys: %w", err)
+	}
+	keys = append([]jwt.Key{jwt.NewHMACKey("", []byte(cfg.Secret))}, append(keys, pemKeys...)...)
+
+	var keySet *jwt.KeySet
+	if cfg.JWTKeysDir != "" {
+		keySet, err = jwt.NewDirKeySet(cfg.JWTKeysDir, cfg.JWTActiveKey, keys...)
+	} else {
+		keySet, err = jwt.NewKeySet(cfg.JWTActiveKey, keys...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed load jwt keys: %w", err)
+	}
+
+	return keySet, nil
+}
+
+// SetCookie задает атрибуты cookie с токеном. По умолчанию cookie HttpOnly, Secure и SameSite=Strict.
+func SetCookie(cfg CookieConfig) Option {
+	return func(s *Server) {
+		s.cookie = cfg
+	}
+}
+
+// EventsKeepAlive задает интервал комментариев-пингов в потоке событий, чтобы прокси не закрывали
+// соединение без событий.
+func EventsKeepAlive(interval time.Duration) Option {
+	return func(s *Server) {
+		if interval > 0 {
+			s.eventsKeepAlive = interval
+		}
+	}
+}
+
+// SetWebSocket задает интервал ping и таймаут записи для /api/user/ws.
+func SetWebSocket(cfg WebSocketConfig) Option {
+	return func(s *Server) {
+		if cfg.PingInterval > 0 {
+			s.webSocket.PingInterval = cfg.PingInterval
+		}
+		if cfg.WriteTimeout > 0 {
+			s.webSocket.WriteTimeout = cfg.WriteTimeout
+		}
+	}
+}
+
+// SetRateLimits задает лимиты запросов по группам маршрутов. Конфигурация из окружения по умолчанию
+// ограничивает пользователей 120/1m, администраторов 300/1m и магазины 600/1m. Без этой опции лимитов нет.
+func SetRateLimits(cfg RateLimitConfig) Option {
+	return func(s *Server) {
+		s.rateLimits = cfg
+	}
+}
+
+// SetHealth задает таймаут проверки готовности и задержку перед остановкой сервера.
+func SetHealth(cfg HealthConfig) Option {
+	return func(s *Server) {
+		if cfg.ReadyTimeout > 0 {
+			s.health.ReadyTimeout = cfg.ReadyTimeout
+		}
+		s.health.DrainDelay = cfg.DrainDelay
+	}
+}
+
+// SetBodyLimits задает максимальные размеры тела запроса до и после распаковки.
+// Нулевые значения оставляют размеры по умолчанию.
+func SetBodyLimits(cfg BodyConfig) Option {
+	return func(s *Server) {
+		if cfg.MaxSize > 0 {
+			s.body.MaxSize = cfg.MaxSize
+		}
+		if cfg.MaxDecompressedSize > 0 {
+			s.body.MaxDecompressedSize = cfg.MaxDecompressedSize
+		}
+	}
+}
+
+// TrustedProxies задает прокси, которым разрешено передавать адрес клиента в X-Forwarded-For.
+// По умолчанию заголовку не доверяем и адрес клиента берется из соединения.
+func TrustedProxies(proxies []string) Option {
+	return func(s *Server) {
+		s.trustedProxies = proxies
 	}
 }
 
@@ -70,37 +228,140 @@ func SetSecretKey(key []byte) Option {
 
 func New(service gophermartI, options ...Option) (*Server, error) {
 	s := &Server{
-		srv:     &http.Server{},
-		log:     zap.NewNop(),
-		service: service,
+		srv:             &http.Server{},
+		log:             zap.NewNop(),
+		service:         service,
+		jwt:             jwt.New(nil),
+		cookie:          defaultCookieConfig,
+		eventsKeepAlive: defaultEventsKeepAlive,
+		webSocket:       defaultWebSocketConfig,
+		health:          defaultHealthConfig,
+		body:            defaultBodyConfig,
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	var err error
+	if s.cookieSameSite, err = parseSameSite(s.cookie.SameSite); err != nil {
+		return nil, fmt.Errorf("failed parse cookie config: %w", err)
+	}
+	if s.cookieSameSite == http.SameSiteNoneMode && !s.cookie.Secure {
+		return nil, errors.New("cookie with SameSite=None must be Secure")
 	}
 
 	r := gin.New()
+	if err := r.SetTrustedProxies(s.trustedProxies); err != nil {
+		return nil, fmt.Errorf("failed set trusted proxies: %w", err)
+	}
+	// пробы балансировщика и оркестратора регистрируются до middleware, чтобы не засорять лог запросов
+	r.GET("/healthz", s.handlerHealthz)
+	r.GET("/readyz", s.handlerReadyz)
 	r.Use(
+		s.RequestID(),
 		s.Logger(),
+		s.BodyLimit(),
 		s.GzipDecompress(),
 	)
+	userRateLimit := s.RateLimit(s.rateLimits.User)
 	apiUser := r.Group("/api/user")
-	apiUser.Use(s.GzipCompress())
+	apiUser.Use(userRateLimit, s.GzipCompress())
 	{
 		apiUser.POST("/register", s.handlerRegister)
 		apiUser.POST("/login", s.handlerLogin)
+		apiUser.POST("/login/2fa", s.handlerLoginSecondFactor)
+		apiUser.DELETE("", s.Authentication(), s.handlerDeleteUser)
 
 		authAPIUser := apiUser.Group("/")
 		authAPIUser.Use(s.Authentication())
 		{
 			authAPIUser.POST("/orders", s.handlerLoadUserOrders)
-			authAPIUser.GET("/orders", s.handlerGetUserOrders)
-			authAPIUser.GET("/balance", s.handlerGetUserBalance)
+			authAPIUser.POST("/orders/batch", s.handlerLoadUserOrdersBatch)
+			authAPIUser.GET("/orders", s.ETag(), s.handlerGetUserOrders)
+			authAPIUser.GET("/orders/:number", s.ETag(), s.handlerGetUserOrder)
+			authAPIUser.GET("/orders.csv", s.handlerUserOrdersCSV)
+			authAPIUser.GET("/balance", s.ETag(), s.handlerGetUserBalance)
 			authAPIUser.POST("/balance/withdraw", s.handlerUserBalanceWithdraw)
-			authAPIUser.GET("/withdrawals", s.handlerUserWithdrawals)
+			authAPIUser.GET("/withdrawals", s.ETag(), s.handlerUserWithdrawals)
+			authAPIUser.GET("/withdrawals.csv", s.handlerUserWithdrawalsCSV)
+			authAPIUser.POST("/password", s.handlerChangePassword)
+			authAPIUser.POST("/2fa/enroll", s.handlerEnrollTOTP)
+			authAPIUser.POST("/2fa/confirm", s.handlerConfirmTOTP)
+			authAPIUser.DELETE("/2fa", s.handlerDisableTOTP)
+			authAPIUser.GET("/export", s.handlerExportUserData)
+			authAPIUser.GET("/events", s.handlerUserEvents)
+			authAPIUser.GET("/ws", s.handlerUserWS)
 		}
 	}
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// /api/user заморожен как v1, новые представления только в /api/v2
+	apiV2User := r.Group("/api/v2/user")
+	apiV2User.Use(userRateLimit, s.GzipCompress())
+	{
+		apiV2User.POST("/register", s.handlerRegister)
+		apiV2User.POST("/login", s.handlerLogin)
+		apiV2User.POST("/login/2fa", s.handlerLoginSecondFactor)
 
-	for _, opt := range options {
-		opt(s)
+		authAPIV2User := apiV2User.Group("/")
+		authAPIV2User.Use(s.Authentication())
+		{
+			authAPIV2User.POST("/orders", s.handlerLoadUserOrders)
+			authAPIV2User.POST("/orders/batch", s.handlerLoadUserOrdersBatch)
+			authAPIV2User.GET("/orders", s.ETag(), s.handlerV2GetUserOrders)
+			authAPIV2User.GET("/orders/:number", s.ETag(), s.handlerV2GetUserOrder)
+			authAPIV2User.GET("/balance", s.ETag(), s.handlerV2GetUserBalance)
+			authAPIV2User.POST("/balance/withdraw", s.handlerV2UserBalanceWithdraw)
+			authAPIV2User.GET("/withdrawals", s.ETag(), s.handlerV2UserWithdrawals)
+		}
 	}
+	apiAdmin := r.Group("/api/admin")
+	apiAdmin.Use(
+		s.RateLimit(s.rateLimits.Admin),
+		s.GzipCompress(),
+		s.Authentication(),
+		s.RequireRole(model.RoleSupport, model.RoleAdmin),
+	)
+	{
+		apiAdmin.GET("/users", s.handlerAdminSearchUsers)
+		apiAdmin.GET("/users/:id", s.handlerAdminGetUser)
+		apiAdmin.GET("/users/:id/orders", s.handlerAdminGetUserOrders)
+		apiAdmin.GET("/users/:id/orders/:number", s.handlerAdminGetUserOrder)
+		apiAdmin.GET("/users/:id/withdrawals", s.handlerAdminGetUserWithdrawals)
+		apiAdmin.GET("/users/:id/balance", s.handlerAdminGetUserBalance)
+
+		onlyAdmin := apiAdmin.Group("/")
+		onlyAdmin.Use(s.RequireRole(model.RoleAdmin))
+		{
+			onlyAdmin.POST("/users/:id/balance/adjustments", s.handlerAdminAdjustBalance)
+			onlyAdmin.PUT("/users/:id/role", s.handlerAdminSetUserRole)
+			onlyAdmin.GET("/export/orders.csv", s.handlerAdminOrdersCSV)
+			onlyAdmin.GET("/export/withdrawals.csv", s.handlerAdminWithdrawalsCSV)
+			onlyAdmin.POST("/merchants", s.handlerAdminCreateMerchant)
+			onlyAdmin.POST("/merchants/:id/keys", s.handlerAdminCreateMerchantKey)
+			onlyAdmin.DELETE("/merchants/:id/keys/:keyID", s.handlerAdminRevokeMerchantKey)
+			onlyAdmin.PUT("/merchants/:id/users/:userID", s.handlerAdminLinkMerchantUser)
+			onlyAdmin.DELETE("/merchants/:id/users/:userID", s.handlerAdminUnlinkMerchantUser)
+		}
+	}
+	apiMerchant := r.Group("/api/merchant")
+	apiMerchant.Use(
+		s.RateLimit(s.rateLimits.Merchant),
+		s.GzipCompress(),
+		s.MerchantAuthentication(),
+		s.RateLimit(s.rateLimits.Merchant),
+	)
+	{
+		apiMerchant.POST("/users/:id/orders",
+			s.RequireScope(model.ScopeOrdersWrite), s.handlerMerchantUploadOrder)
+		apiMerchant.POST("/users/:id/balance/withdraw",
+			s.RequireScope(model.ScopeBalanceWithdraw), s.handlerMerchantWithdraw)
+	}
+	r.GET("/.well-known/jwks.json", s.handlerJWKS)
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.NoRoute(func(c *gin.Context) {
+		writeProblem(c, http.StatusNotFound, errstore.ErrNotFoundData)
+	})
+
 	s.srv.Handler = r.Handler()
 
 	return s, nil
@@ -118,7 +379,9 @@ func (s *Server) Run() error {
 	return nil
 }
 
+// Shutdown останавливает сервер, /readyz с этого момента отвечает not-ready.
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
 	if err := s.srv.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed shutfdown servr: %w", err)
 	}
@@ -126,37 +389,116 @@ func (s *Server) Shutdown(ctx context.Context) error {
 }
 
 func (s *Server) checkAuth(c *gin.Context) (userID uint, err error) {
-	var ok bool
-	var userIDS string
+	if user, ok := currentUser(c); ok {
+		return user.ID, nil
+	}
+
+	user, err := s.authenticate(c)
+	if err != nil {
+		return 0, err
+	}
+	c.Set(ctxUserKey, user)
+
+	return user.ID, nil
+}
+
+// currentUser возвращает пользователя, загруженного при проверке токена.
+func currentUser(c *gin.Context) (model.User, bool) {
+	value, ok := c.Get(ctxUserKey)
+	if !ok {
+		return model.User{}, false
+	}
+	user, ok := value.(model.User)
+	return user, ok
+}
+
+// authenticate проверяет токен из cookie и сверяет версию токена с текущей версией пользователя.
+func (s *Server) authenticate(c *gin.Context) (model.User, error) {
+	var user model.User
+	userID, tokenVersion, err := s.tokenClaims(c)
+	if err != nil {
+		return user, err
+	}
+
+	user, err = s.service.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			return user, fmt.Errorf("user of token not found: %w", errUnauthorize)
+		}
+		return user, fmt.Errorf("failed getting user: %w", err)
+	}
+
+	if tokenVersion != user.TokenVersion {
+		return user, fmt.Errorf("token was revoked: %w", errUnauthorize)
+	}
+
+	return user, nil
+}
+
+// tokenClaims проверяет подпись токена пользователя из cookie и возвращает его идентификатор
+// и версию токена. Отзыв токена и существование пользователя не проверяются.
+func (s *Server) tokenClaims(c *gin.Context) (userID uint, tokenVersion uint, err error) {
 	cookieUserID, err := c.Request.Cookie(cookieName)
 	if err != nil {
-		return 0, fmt.Errorf("failed reade user cookie: %w %w", err, errUnauthorize)
+		return 0, 0, fmt.Errorf("failed reade user cookie: %w %w", err, errUnauthorize)
 	}
 
-	jwtRest := jwt.New(s.secret)
-	userIDS, ok, err = jwtRest.Verify(cookieUserID.Value, cookieKey)
+	claims, err := s.jwt.ExpiringClaims(cookieUserID.Value)
 	if err != nil {
-		return 0, fmt.Errorf("failed verify token: %w %w", err, errUnauthorize)
+		return 0, 0, fmt.Errorf("failed verify token: %w %w", err, errUnauthorize)
 	}
 
-	if !ok {
-		return 0, fmt.Errorf("unverify usercookie: %w", errUnauthorize)
+	userIDS, ok := claims[cookieKey]
+	if !ok || userIDS == "" {
+		return 0, 0, fmt.Errorf("unverify usercookie: %w", errUnauthorize)
 	}
 
 	userID64, err := strconv.ParseUint(userIDS, 10, 32)
 	if err != nil {
-		return 0, fmt.Errorf("can't convert string userID to uint: %w", err)
+		return 0, 0, fmt.Errorf("can't convert string userID to uint: %w", err)
+	}
+
+	var version uint64
+	if versionS, ok := claims[tokenVersionKey]; ok {
+		version, err = strconv.ParseUint(versionS, 10, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("can't convert token version to uint: %w %w", err, errUnauthorize)
+		}
 	}
 
-	return uint(userID64), nil
+	return uint(userID64), uint(version), nil
 }
 
-func unauthorize(c *gin.Context) {
-	userCookie := &http.Cookie{
-		Name:  cookieName,
-		Value: "",
-		Path:  "/",
+// currentMerchantKey возвращает API ключ магазина, проверенный в MerchantAuthentication.
+func currentMerchantKey(c *gin.Context) (model.MerchantKey, bool) {
+	value, ok := c.Get(ctxMerchantKey)
+	if !ok {
+		return model.MerchantKey{}, false
 	}
+	key, ok := value.(model.MerchantKey)
+	return key, ok
+}
+
+// newCookie создает cookie с токеном. maxAge меньше нуля удаляет cookie в браузере.
+func (s *Server) newCookie(value string, maxAge int) *http.Cookie {
+	return s.newNamedCookie(cookieName, value, maxAge)
+}
+
+func (s *Server) newNamedCookie(name, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   s.cookie.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: s.cookie.HTTPOnly,
+		Secure:   s.cookie.Secure,
+		SameSite: s.cookieSameSite,
+	}
+}
+
+func (s *Server) unauthorize(c *gin.Context) {
+	userCookie := s.newCookie("", -1)
 	c.Request.AddCookie(userCookie)
 	http.SetCookie(c.Writer, userCookie)
 }
@@ -165,57 +507,142 @@ func (s *Server) authorization(c *gin.Context, login, password string) error {
 	ctx := c.Request.Context()
 	var err error
 	var user model.User
-	if user, err = s.service.Authorization(ctx, login, password); err != nil {
+	if user, err = s.service.Authorization(ctx, login, password, c.ClientIP()); err != nil {
+		if errors.Is(err, gophermart.ErrSecondFactorRequired) {
+			if err := s.setSecondFactorToken(c, user); err != nil {
+				return err
+			}
+		}
 		return fmt.Errorf("failed authorization: %w", err)
 	}
 
-	jwtRest := jwt.New(s.secret)
-	signedCookie, err := jwtRest.Create(cookieKey, strconv.Itoa(int(user.ID)))
+	return s.setToken(c, user)
+}
+
+// setSecondFactorToken выпускает короткоживущий токен, подтверждающий проверку пароля.
+// Токен не дает доступа к API, его можно обменять на токен пользователя только вместе с кодом.
+func (s *Server) setSecondFactorToken(c *gin.Context, user model.User) error {
+	signed, err := s.jwt.CreateExpiring(map[string]string{
+		mfaUserKey:      strconv.Itoa(int(user.ID)),
+		tokenVersionKey: strconv.Itoa(int(user.TokenVersion)),
+	}, time.Now().Add(s.cookie.SecondFactorMaxAge))
 	if err != nil {
-		return fmt.Errorf("can't create cookie data: %w", err)
+		return fmt.Errorf("can't create second factor token: %w", err)
 	}
 
-	userCookie := &http.Cookie{
-		Name:  cookieName,
-		Value: signedCookie,
-		Path:  "/",
+	http.SetCookie(c.Writer, s.newNamedCookie(mfaCookieName, signed, int(s.cookie.SecondFactorMaxAge.Seconds())))
+
+	return nil
+}
+
+// secondFactorUser возвращает пользователя из токена, выданного после проверки пароля.
+// Токен отклоняется, если версия токенов пользователя изменилась после его выпуска.
+func (s *Server) secondFactorUser(c *gin.Context) (uint, error) {
+	cookie, err := c.Request.Cookie(mfaCookieName)
+	if err != nil {
+		return 0, fmt.Errorf("failed read second factor cookie: %w %w", err, errUnauthorize)
 	}
+	claims, err := s.jwt.ExpiringClaims(cookie.Value)
+	if err != nil {
+		return 0, fmt.Errorf("failed verify second factor token: %w %w", err, errUnauthorize)
+	}
+	userID, err := strconv.ParseUint(claims[mfaUserKey], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("second factor token without user: %w", errUnauthorize)
+	}
+	version, err := strconv.ParseUint(claims[tokenVersionKey], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("second factor token without version: %w", errUnauthorize)
+	}
+
+	user, err := s.service.GetUser(c.Request.Context(), uint(userID))
+	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			return 0, fmt.Errorf("user of second factor token not found: %w", errUnauthorize)
+		}
+		return 0, fmt.Errorf("failed getting user: %w", err)
+	}
+	if uint(version) != user.TokenVersion {
+		return 0, fmt.Errorf("second factor token was revoked: %w", errUnauthorize)
+	}
+
+	return user.ID, nil
+}
+
+// setToken выпускает токен пользователя со сроком действия cookie и записывает его в cookie.
+func (s *Server) setToken(c *gin.Context, user model.User) error {
+	signedCookie, err := s.jwt.CreateExpiring(map[string]string{
+		cookieKey:       strconv.Itoa(int(user.ID)),
+		tokenVersionKey: strconv.Itoa(int(user.TokenVersion)),
+		tokenRoleKey:    string(user.Role),
+	}, time.Now().Add(s.cookie.MaxAge))
+	if err != nil {
+		return fmt.Errorf("can't create cookie data: %w", err)
+	}
+
+	userCookie := s.newCookie(signedCookie, int(s.cookie.MaxAge.Seconds()))
 	c.Request.AddCookie(userCookie)
 	http.SetCookie(c.Writer, userCookie)
 
 	return nil
 }
 
-func (s *Server) login(c *gin.Context, login, password string) (int, string) {
-	if err := s.authorization(c, login, password); err != nil {
-		if errors.Is(err, gophermart.ErrLoginNotValid) || errors.Is(err, gophermart.ErrPasswordNotValid) {
-			if errors.Is(err, gophermart.ErrLoginNotValid) {
-				return http.StatusBadRequest, "Не верный формат логина"
-			}
-			if errors.Is(err, gophermart.ErrPasswordNotValid) {
-				return http.StatusBadRequest, "Не верный формат пароля"
-			}
-			return http.StatusInternalServerError, ""
-		}
-		if errors.Is(err, gophermart.ErrPasswordNotEquale) || errors.Is(err, errstore.ErrNotFoundData) {
-			return http.StatusUnauthorized, ""
-		}
-		s.log.Error("authorization failed", zap.Error(err))
-		return http.StatusInternalServerError, ""
+// login авторизует пользователя и отвечает на запрос входа.
+func (s *Server) login(c *gin.Context, login, password string) {
+	err := s.authorization(c, login, password)
+	if err == nil {
+		c.Writer.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if status, ok := loginAttemptsStatus(c, err); ok {
+		writeProblem(c, status, err)
+		return
+	}
+	if errors.Is(err, gophermart.ErrSecondFactorRequired) {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Требуется код двухфакторной аутентификации",
+		})
+		return
+	}
+	if errors.Is(err, gophermart.ErrPasswordNotEquale) || errors.Is(err, errstore.ErrNotFoundData) {
+		writeProblem(c, 0, errCredentialsNotValid)
+		return
 	}
-	return http.StatusOK, ""
+	if !isProblem(err) {
+		s.logger(c).Error("authorization failed", zap.Error(err))
+	}
+	writeProblem(c, 0, err)
 }
 
-func (s *Server) readBody(c *gin.Context) ([]byte, int) {
+// loginAttemptsStatus возвращает статус ответа, если попытка входа отклонена защитой от перебора.
+func loginAttemptsStatus(c *gin.Context, err error) (int, bool) {
+	var attemptsErr *gophermart.LoginAttemptsError
+	if !errors.As(err, &attemptsErr) {
+		return 0, false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(attemptsErr.RetryAfter.Seconds()))))
+	if errors.Is(err, gophermart.ErrLoginLocked) {
+		return http.StatusLocked, true
+	}
+	return http.StatusTooManyRequests, true
+}
+
+// readBody читает тело запроса. Превышение размера тела отклоняется с 413, поврежденный gzip с 400,
+// остальные ошибки чтения с 500.
+func (s *Server) readBody(c *gin.Context) ([]byte, bool) {
 	bBody, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		s.log.Error("failed read body", zap.Error(err))
-		return []byte{}, http.StatusInternalServerError
+		if !isProblem(err) {
+			s.logger(c).Error("failed read body", zap.Error(err))
+		}
+		writeProblem(c, 0, err)
+		return []byte{}, false
 	}
 	defer func() {
 		if err := c.Request.Body.Close(); err != nil {
-			s.log.Error(msgErrorCloseBody, zap.Error(err))
+			s.logger(c).Error(msgErrorCloseBody, zap.Error(err))
 		}
 	}()
-	return bBody, 0
+	return bBody, true
 }
@@ -1,9 +1,14 @@
 package rest
 
 import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"github.com/playmixer/gophermart/internal/core/gophermart"
 )
 
 type tRegistration struct {
@@ -16,6 +21,11 @@ type tAuthorization struct {
 	Password string `json:"password"`
 }
 
+type tChangePassword struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
 type tOrderByUser struct {
 	uploadedAt time.Time
 	Accrual    *float32          `json:"accrual,omitempty"`
@@ -29,11 +39,100 @@ func (o *tOrderByUser) Prepare() *tOrderByUser {
 	return o
 }
 
+func newOrdersByUser(orders []*model.Order) []tOrderByUser {
+	response := []tOrderByUser{}
+	for _, order := range orders {
+		resOrder := tOrderByUser{
+			Number:     order.Number,
+			Status:     order.Status,
+			uploadedAt: order.UpdatedAt,
+		}
+		if order.Status == model.OrderStateProcessed {
+			_accrual := &order.Accrual
+			resOrder.Accrual = _accrual
+		}
+		resOrder.Prepare()
+		response = append(response, resOrder)
+	}
+	return response
+}
+
+type tOrderUpload struct {
+	Number string                  `json:"number"`
+	Result model.OrderUploadResult `json:"result"`
+}
+
+func newOrderUploads(uploads []model.OrderUpload) []tOrderUpload {
+	response := make([]tOrderUpload, 0, len(uploads))
+	for _, upload := range uploads {
+		response = append(response, tOrderUpload{Number: upload.Number, Result: upload.Result})
+	}
+	return response
+}
+
+type tOrderStatusChange struct {
+	Accrual   *float32          `json:"accrual,omitempty"`
+	Status    model.OrderStatus `json:"status"`
+	ChangedAt string            `json:"changed_at"`
+}
+
+type tOrderDetails struct {
+	Accrual    *float32             `json:"accrual,omitempty"`
+	Number     string               `json:"number"`
+	Status     model.OrderStatus    `json:"status"`
+	UploadedAt string               `json:"uploaded_at"`
+	History    []tOrderStatusChange `json:"history"`
+}
+
+func newOrderDetails(order *model.Order, history []*model.OrderStatusChange) tOrderDetails {
+	details := tOrderDetails{
+		Number:     order.Number,
+		Status:     order.Status,
+		UploadedAt: order.CreatedAt.Format(time.RFC3339),
+		History:    []tOrderStatusChange{},
+	}
+	if order.Status == model.OrderStateProcessed {
+		details.Accrual = &order.Accrual
+	}
+	for _, change := range history {
+		item := tOrderStatusChange{
+			Status:    change.Status,
+			ChangedAt: change.CreatedAt.Format(time.RFC3339),
+		}
+		if change.Status == model.OrderStateProcessed {
+			item.Accrual = &change.Accrual
+		}
+		details.History = append(details.History, item)
+	}
+
+	return details
+}
+
 type tBalanceByUser struct {
 	Current   float32 `json:"current"`
 	Withdrawn float32 `json:"withdrawn"`
 }
 
+// tEvent данные события пользователя, заполнено одно из полей по типу события.
+type tEvent struct {
+	Order   *tOrderByUser   `json:"order,omitempty"`
+	Balance *tBalanceByUser `json:"balance,omitempty"`
+}
+
+func newEvent(event *gophermart.Event) tEvent {
+	result := tEvent{}
+	if event.Order != nil {
+		result.Order = &newOrdersByUser([]*model.Order{event.Order})[0]
+	}
+	if event.Balance != nil {
+		result.Balance = &tBalanceByUser{
+			Current:   event.Balance.Current,
+			Withdrawn: event.Balance.Withdrawn,
+		}
+	}
+	return result
+}
+
 type tWithdraw struct {
 	Order string  `json:"order"`
 	Sum   float32 `json:"sum"`
@@ -50,3 +149,304 @@ func (w *tWithdrawBalance) Prepare() *tWithdrawBalance {
 	w.ProcessedAt = w.processedAt.Format(time.RFC3339)
 	return w
 }
+
+func newWithdrawalsByUser(withdrawals []*model.WithdrawBalance) []tWithdrawBalance {
+	result := []tWithdrawBalance{}
+	for _, withdrawal := range withdrawals {
+		wd := tWithdrawBalance{
+			Order:       withdrawal.OderNumber,
+			Sum:         withdrawal.Sum,
+			processedAt: withdrawal.UpdatedAt,
+		}
+		result = append(result, *wd.Prepare())
+	}
+	return result
+}
+
+type tAdminUser struct {
+	Login     string         `json:"login"`
+	Role      model.UserRole `json:"role"`
+	CreatedAt string         `json:"created_at"`
+	ID        uint           `json:"id"`
+}
+
+func newAdminUser(user *model.User) tAdminUser {
+	return tAdminUser{
+		ID:        user.ID,
+		Login:     user.Login,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+type tUserRole struct {
+	Role model.UserRole `json:"role"`
+}
+
+type tBalanceAdjustment struct {
+	Reason string  `json:"reason"`
+	Amount float32 `json:"amount"`
+}
+
+type tMerchantName struct {
+	Name string `json:"name"`
+}
+
+type tMerchant struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+	ID        uint   `json:"id"`
+}
+
+func newMerchant(merchant *model.Merchant) tMerchant {
+	return tMerchant{
+		ID:        merchant.ID,
+		Name:      merchant.Name,
+		CreatedAt: merchant.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+type tMerchantKeyScopes struct {
+	Scopes []model.MerchantScope `json:"scopes"`
+}
+
+type tMerchantKey struct {
+	Key       string   `json:"key"`
+	CreatedAt string   `json:"created_at"`
+	Scopes    []string `json:"scopes"`
+	ID        uint     `json:"id"`
+}
+
+type tTOTPCode struct {
+	Code string `json:"code"`
+}
+
+type tTOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type tRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type tDeleteUser struct {
+	Password string `json:"password"`
+}
+
+type tExportProfile struct {
+	Login            string         `json:"login"`
+	Role             model.UserRole `json:"role"`
+	CreatedAt        string         `json:"created_at"`
+	ID               uint           `json:"id"`
+	TwoFactorEnabled bool           `json:"two_factor_enabled"`
+}
+
+// tBalanceOperation запись истории баланса: начисление за заказ, списание или ручная корректировка.
+type tBalanceOperation struct {
+	at     time.Time
+	Type   string  `json:"type"`
+	Order  string  `json:"order,omitempty"`
+	Reason string  `json:"reason,omitempty"`
+	At     string  `json:"at"`
+	Amount float32 `json:"amount"`
+}
+
+type tExportBalance struct {
+	History []tBalanceOperation `json:"history"`
+	tBalanceByUser
+}
+
+func newExportBalance(export *gophermart.UserExport) tExportBalance {
+	history := []tBalanceOperation{}
+	for _, order := range export.Orders {
+		if order.Status == model.OrderStateProcessed && order.Accrual > 0 {
+			history = append(history, tBalanceOperation{
+				Type:   "accrual",
+				Order:  order.Number,
+				Amount: order.Accrual,
+				at:     order.UpdatedAt,
+			})
+		}
+	}
+	for _, withdrawal := range export.Withdrawals {
+		history = append(history, tBalanceOperation{
+			Type:   "withdrawal",
+			Order:  withdrawal.OderNumber,
+			Amount: -withdrawal.Sum,
+			at:     withdrawal.UpdatedAt,
+		})
+	}
+	for _, adjustment := range export.Adjustments {
+		history = append(history, tBalanceOperation{
+			Type:   "adjustment",
+			Reason: adjustment.Reason,
+			Amount: adjustment.Amount,
+			at:     adjustment.CreatedAt,
+		})
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].at.Before(history[j].at)
+	})
+	for i := range history {
+		history[i].At = history[i].at.Format(time.RFC3339)
+	}
+
+	return tExportBalance{
+		tBalanceByUser: tBalanceByUser{
+			Current:   export.Balance.Current,
+			Withdrawn: export.Balance.Withdrawn,
+		},
+		History: history,
+	}
+}
+
+// Представления /api/v2: суммы передаются строками с двумя знаками после точки,
+// время в RFC3339, списки всегда массивы.
+
+type tOrderV2 struct {
+	Accrual     *string           `json:"accrual"`
+	ProcessedAt *string           `json:"processed_at"`
+	Number      string            `json:"number"`
+	Status      model.OrderStatus `json:"status"`
+	UploadedAt  string            `json:"uploaded_at"`
+	ID          uint              `json:"id"`
+}
+
+// newOrderV2 заполняет время загрузки из CreatedAt, время обработки из истории статусов.
+func newOrderV2(order *model.Order) tOrderV2 {
+	result := tOrderV2{
+		ID:         order.ID,
+		Number:     order.Number,
+		Status:     order.Status,
+		UploadedAt: order.CreatedAt.Format(time.RFC3339),
+	}
+	if order.Status == model.OrderStateProcessed {
+		accrual := formatDecimal(order.Accrual)
+		result.Accrual = &accrual
+	}
+	if isOrderFinal(order.Status) && order.ProcessedAt != nil {
+		processedAt := order.ProcessedAt.Format(time.RFC3339)
+		result.ProcessedAt = &processedAt
+	}
+	return result
+}
+
+func newOrdersV2(orders []*model.Order) []tOrderV2 {
+	result := make([]tOrderV2, 0, len(orders))
+	for _, order := range orders {
+		result = append(result, newOrderV2(order))
+	}
+	return result
+}
+
+type tOrderStatusChangeV2 struct {
+	Accrual   *string           `json:"accrual"`
+	Status    model.OrderStatus `json:"status"`
+	ChangedAt string            `json:"changed_at"`
+	ID        uint              `json:"id"`
+}
+
+type tOrderDetailsV2 struct {
+	tOrderV2
+	History []tOrderStatusChangeV2 `json:"history"`
+}
+
+func newOrderDetailsV2(order *model.Order, history []*model.OrderStatusChange) tOrderDetailsV2 {
+	details := tOrderDetailsV2{
+		tOrderV2: newOrderV2(order),
+		History:  make([]tOrderStatusChangeV2, 0, len(history)),
+	}
+	for _, change := range history {
+		item := tOrderStatusChangeV2{
+			ID:        change.ID,
+			Status:    change.Status,
+			ChangedAt: change.CreatedAt.Format(time.RFC3339),
+		}
+		if change.Status == model.OrderStateProcessed {
+			accrual := formatDecimal(change.Accrual)
+			item.Accrual = &accrual
+		}
+		details.History = append(details.History, item)
+	}
+	return details
+}
+
+type tBalanceV2 struct {
+	Current   string `json:"current"`
+	Withdrawn string `json:"withdrawn"`
+}
+
+func newBalanceV2(balance *model.Balance) tBalanceV2 {
+	return tBalanceV2{
+		Current:   formatDecimal(balance.Current),
+		Withdrawn: formatDecimal(balance.Withdrawn),
+	}
+}
+
+type tWithdrawV2 struct {
+	Order string `json:"order"`
+	Sum   string `json:"sum"`
+}
+
+type tWithdrawalV2 struct {
+	Order       string `json:"order"`
+	Sum         string `json:"sum"`
+	ProcessedAt string `json:"processed_at"`
+	ID          uint   `json:"id"`
+}
+
+func newWithdrawalsV2(withdrawals []*model.WithdrawBalance) []tWithdrawalV2 {
+	result := make([]tWithdrawalV2, 0, len(withdrawals))
+	for _, withdrawal := range withdrawals {
+		result = append(result, tWithdrawalV2{
+			ID:          withdrawal.ID,
+			Order:       withdrawal.OderNumber,
+			Sum:         formatDecimal(withdrawal.Sum),
+			ProcessedAt: withdrawal.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return result
+}
+
+func formatDecimal(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', 2, 32)
+}
+
+var decimalPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]{1,2})?$`)
+
+// parseDecimal разбирает неотрицательную сумму не больше чем с двумя знаками после точки.
+func parseDecimal(value string) (float32, error) {
+	if !decimalPattern.MatchString(value) {
+		return 0, fmt.Errorf("decimal `%s` has wrong format", value)
+	}
+	v, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0, fmt.Errorf("failed parse decimal `%s`: %w", value, err)
+	}
+	return float32(v), nil
+}
+
+type tHealth struct {
+	Status string `json:"status" example:"ok"`
+}
+
+// tHealthCheck результат проверки зависимости: ok, fail или skipped, если проверка не выполнялась.
+type tHealthCheck struct {
+	Status string `json:"status" example:"ok"`
+	Error  string `json:"error,omitempty" example:"timeout"`
+}
+
+type tAccrualHealth struct {
+	CircuitBreaker string `json:"circuit_breaker" example:"closed"`
+}
+
+// tReadiness результат /readyz. Состояние circuit breaker системы начислений на готовность не влияет:
+// пока она недоступна, сервис принимает заказы и начисляет баллы после восстановления.
+type tReadiness struct {
+	Status       string         `json:"status" example:"ready"`
+	Accrual      tAccrualHealth `json:"accrual"`
+	Database     tHealthCheck   `json:"database"`
+	Migrations   tHealthCheck   `json:"migrations"`
+	ShuttingDown bool           `json:"shutting_down"`
+}
@@ -0,0 +1,246 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errContentTypeNotSupported = errors.New("content type not supported")
+	errValidation              = errors.New("validation failed")
+
+	contentTypeJSON  = "application/json"
+	contentTypePlain = "text/plain"
+
+	orderBatchMax = 1000
+
+	msgFieldRequired = "обязательное поле"
+	msgFieldUnknown  = "неизвестное поле"
+	msgFieldPositive = "должно быть больше нуля"
+	msgFieldDecimal  = "должно быть числом в строке, например \"42.50\""
+)
+
+// tFieldError ошибка значения поля запроса.
+type tFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// fieldsError ошибка запроса с подробностями по полям, поля передаются клиенту в errors.
+type fieldsError struct {
+	err    error
+	fields []tFieldError
+}
+
+func (e *fieldsError) Error() string {
+	items := make([]string, 0, len(e.fields))
+	for _, f := range e.fields {
+		items = append(items, f.Field+": "+f.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.err, strings.Join(items, "; "))
+}
+
+func (e *fieldsError) Unwrap() error {
+	return e.err
+}
+
+// validator проверяет значения полей запроса после разбора JSON.
+type validator interface {
+	validate() []tFieldError
+}
+
+// checkContentType отвечает 415, если тип содержимого запроса не из списка.
+// Запрос без заголовка Content-Type принимается.
+func checkContentType(c *gin.Context, types ...string) bool {
+	contentType := c.ContentType()
+	if contentType == "" || slices.Contains(types, contentType) {
+		return true
+	}
+	writeProblem(c, 0, fmt.Errorf("%w: expected %s, got %s",
+		errContentTypeNotSupported, strings.Join(types, " or "), contentType))
+	return false
+}
+
+// bindJSON читает тело запроса в dst. Неверный тип содержимого отклоняется с 415,
+// синтаксические ошибки, неизвестные поля и поля неверного типа с 400,
+// ошибки проверки значений полей с 422.
+func (s *Server) bindJSON(c *gin.Context, dst any) bool {
+	if !checkContentType(c, contentTypeJSON) {
+		return false
+	}
+	bBody, ok := s.readBody(c)
+	if !ok {
+		return false
+	}
+	if err := decodeJSON(bBody, dst); err != nil {
+		writeProblem(c, 0, err)
+		return false
+	}
+	if v, ok := dst.(validator); ok {
+		if fields := v.validate(); len(fields) > 0 {
+			writeProblem(c, 0, &fieldsError{err: errValidation, fields: fields})
+			return false
+		}
+	}
+
+	return true
+}
+
+func decodeJSON(body []byte, dst any) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.Is(err, io.EOF):
+			return fmt.Errorf("%w: body is empty", errRequestNotValid)
+		case errors.As(err, &typeErr):
+			field := typeErr.Field
+			if field == "" {
+				field = "body"
+			}
+			return &fieldsError{err: errRequestNotValid, fields: []tFieldError{{
+				Field:   field,
+				Message: "должно быть " + jsonTypeName(typeErr.Type),
+			}}}
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			return &fieldsError{err: errRequestNotValid, fields: []tFieldError{{
+				Field:   field,
+				Message: msgFieldUnknown,
+			}}}
+		default:
+			return fmt.Errorf("%w: %w", errRequestNotValid, err)
+		}
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: unexpected data after json object", errRequestNotValid)
+	}
+
+	return nil
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "строкой"
+	case reflect.Bool:
+		return "логическим значением"
+	case reflect.Slice, reflect.Array:
+		return "массивом"
+	case reflect.Struct, reflect.Map:
+		return "объектом"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "числом"
+	default:
+		return t.String()
+	}
+}
+
+// readOrderNumber читает номер заказа из тела text/plain, пробелы по краям отбрасываются.
+func (s *Server) readOrderNumber(c *gin.Context) (string, bool) {
+	if !checkContentType(c, contentTypePlain) {
+		return "", false
+	}
+	bBody, ok := s.readBody(c)
+	if !ok {
+		return "", false
+	}
+	orderNumber := strings.TrimSpace(string(bBody))
+	if orderNumber == "" {
+		writeProblem(c, 0, fmt.Errorf("%w: order number is empty", errRequestNotValid))
+		return "", false
+	}
+
+	return orderNumber, true
+}
+
+// readOrderNumbers читает пакет номеров заказов: JSON-массив строк или text/plain
+// по номеру в строке, пустые строки пропускаются.
+func (s *Server) readOrderNumbers(c *gin.Context) ([]string, bool) {
+	if !checkContentType(c, contentTypeJSON, contentTypePlain) {
+		return nil, false
+	}
+	bBody, ok := s.readBody(c)
+	if !ok {
+		return nil, false
+	}
+
+	numbers := []string{}
+	if c.ContentType() == contentTypeJSON {
+		if err := decodeJSON(bBody, &numbers); err != nil {
+			writeProblem(c, 0, err)
+			return nil, false
+		}
+		for i, number := range numbers {
+			numbers[i] = strings.TrimSpace(number)
+		}
+	} else {
+		for _, line := range strings.Split(string(bBody), "\n") {
+			if number := strings.TrimSpace(line); number != "" {
+				numbers = append(numbers, number)
+			}
+		}
+	}
+
+	if len(numbers) == 0 {
+		writeProblem(c, 0, fmt.Errorf("%w: order numbers are empty", errRequestNotValid))
+		return nil, false
+	}
+	if len(numbers) > orderBatchMax {
+		writeProblem(c, 0, fmt.Errorf("%w: batch must contain at most %d order numbers", errRequestNotValid, orderBatchMax))
+		return nil, false
+	}
+
+	return numbers, true
+}
+
+func requiredField(fields []tFieldError, name, value string) []tFieldError {
+	if strings.TrimSpace(value) == "" {
+		fields = append(fields, tFieldError{Field: name, Message: msgFieldRequired})
+	}
+	return fields
+}
+
+func (w tWithdraw) validate() []tFieldError {
+	fields := requiredField(nil, "order", w.Order)
+	if w.Sum <= 0 {
+		fields = append(fields, tFieldError{Field: "sum", Message: msgFieldPositive})
+	}
+	return fields
+}
+
+func (w tWithdrawV2) validate() []tFieldError {
+	fields := requiredField(nil, "order", w.Order)
+	sum, err := parseDecimal(w.Sum)
+	switch {
+	case err != nil:
+		fields = append(fields, tFieldError{Field: "sum", Message: msgFieldDecimal})
+	case sum <= 0:
+		fields = append(fields, tFieldError{Field: "sum", Message: msgFieldPositive})
+	}
+	return fields
+}
+
+func (p tChangePassword) validate() []tFieldError {
+	fields := requiredField(nil, "old_password", p.OldPassword)
+	return requiredField(fields, "new_password", p.NewPassword)
+}
+
+func (t tTOTPCode) validate() []tFieldError {
+	return requiredField(nil, "code", t.Code)
+}
+
+func (d tDeleteUser) validate() []tFieldError {
+	return requiredField(nil, "password", d.Password)
+}
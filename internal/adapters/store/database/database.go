@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -14,6 +16,24 @@ import (
 	"gorm.io/gorm"
 )
 
+// models модели, таблицы которых создаются миграцией.
+var models = []any{
+	&model.User{},
+	&model.Order{},
+	&model.Balance{},
+	&model.WithdrawBalance{},
+	&model.AuditEvent{},
+	&model.BalanceAdjustment{},
+	&model.Merchant{},
+	&model.MerchantKey{},
+	&model.MerchantUser{},
+	&model.RecoveryCode{},
+	&model.OrderStatusChange{},
+}
+
+// uploadOrdersAttempts число попыток загрузки пакета заказов при конфликте с параллельной загрузкой.
+var uploadOrdersAttempts = 3
+
 type Store struct {
 	db  *gorm.DB
 	log *zap.Logger
@@ -34,23 +54,22 @@ func New(ctx context.Context, cfg *Config, options ...option) (*Store, error) {
 	s := &Store{
 		log: zap.NewNop(),
 	}
-	db, err := gorm.Open(postgres.Open(cfg.DSN), &gorm.Config{})
+	for _, opt := range options {
+		opt(s)
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.DSN), &gorm.Config{Logger: newGormLogger(s.log)})
 	if err != nil {
 		return nil, fmt.Errorf("failed connect to database: %w", err)
 	}
 
 	s.db = db.WithContext(ctx)
 
-	for _, opt := range options {
-		opt(s)
+	if err = migrateTimeColumns(s.db); err != nil {
+		return nil, fmt.Errorf("migration failed: %w", err)
 	}
 
-	err = s.db.AutoMigrate(
-		&model.User{},
-		&model.Order{},
-		&model.Balance{},
-		&model.WithdrawBalance{},
-	)
+	err = s.db.AutoMigrate(models...)
 
 	if err != nil {
 		return nil, fmt.Errorf("migration failed: %w", err)
@@ -75,8 +94,9 @@ func (s *Store) RegisterUser(ctx context.Context, login, hashPassword string) er
 	user := model.User{
 		Login:        login,
 		PasswordHash: hashPassword,
+		Role:         model.RoleUser,
 	}
-	result := s.db.Create(&user)
+	result := s.db.WithContext(ctx).Create(&user)
 	if err := result.Error; err != nil {
 		var sqlError *pgconn.PgError
 		if errors.As(err, &sqlError) && sqlError.Code == pgerrcode.UniqueViolation {
@@ -101,7 +121,91 @@ func (s *Store) GetUserByLogin(ctx context.Context, login string) (model.User, e
 	return user, nil
 }
 
-func (s *Store) UploadOrder(ctx context.Context, userID uint, orderNumber string) error {
+func (s *Store) GetUserByID(ctx context.Context, userID uint) (model.User, error) {
+	user := model.User{}
+	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, errors.Join(errstore.ErrNotFoundData, err)
+		}
+		return user, fmt.Errorf("error found user id=`%d`: %w", userID, err)
+	}
+
+	return user, nil
+}
+
+// ChangeUserPassword сохраняет новый хеш пароля и увеличивает версию токенов,
+// после чего все ранее выданные токены пользователя перестают приниматься.
+func (s *Store) ChangeUserPassword(ctx context.Context, userID uint, hashPassword string) (model.User, error) {
+	user := model.User{}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"password_hash": hashPassword,
+			"token_version": gorm.Expr("token_version + 1"),
+		})
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed update password: %w", err)
+		}
+		if result.RowsAffected == 0 {
+			return errstore.ErrNotFoundData
+		}
+		if err := tx.First(&user, userID).Error; err != nil {
+			return fmt.Errorf("failed reload user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return user, fmt.Errorf("failed complite transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// UpdateUserPasswordHash заменяет хеш того же пароля, версия токенов не меняется.
+func (s *Store) UpdateUserPasswordHash(ctx context.Context, userID uint, hashPassword string) error {
+	err := s.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", userID).
+		Update("password_hash", hashPassword).Error
+	if err != nil {
+		return fmt.Errorf("failed update password hash of user id=`%d`: %w", userID, err)
+	}
+
+	return nil
+}
+
+func (s *Store) SearchUsers(ctx context.Context, login string, limit int) ([]*model.User, error) {
+	users := []*model.User{}
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(login) + "%"
+	err := s.db.WithContext(ctx).
+		Where("login ILIKE ?", pattern).
+		Order("id").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed search users: %w", err)
+	}
+	if len(users) == 0 {
+		return users, errstore.ErrNotFoundData
+	}
+
+	return users, nil
+}
+
+// SetUserRole меняет роль пользователя и отзывает его токены, так как роль передается в токене.
+func (s *Store) SetUserRole(ctx context.Context, userID uint, role model.UserRole) error {
+	result := s.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"role":          role,
+		"token_version": gorm.Expr("token_version + 1"),
+	})
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed update role of user id=`%d`: %w", userID, err)
+	}
+	if result.RowsAffected == 0 {
+		return errstore.ErrNotFoundData
+	}
+
+	return nil
+}
+
+func (s *Store) UploadOrder(ctx context.Context, userID uint, orderNumber string, merchantID uint) error {
 	tx := s.db.WithContext(ctx)
 	err := tx.Transaction(func(tx *gorm.DB) error {
 		order := model.Order{}
@@ -109,10 +213,14 @@ func (s *Store) UploadOrder(ctx context.Context, userID uint, orderNumber string
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				order.UserID = userID
 				order.Number = orderNumber
+				order.MerchantID = merchantID
 				if err := tx.Create(&order).Error; err != nil {
 					return fmt.Errorf("failed create order: %w", err)
 				}
-				return nil
+				if err := addOrderStatusChange(tx, &order); err != nil {
+					return err
+				}
+				return bumpUserDataVersion(tx, userID)
 			}
 			return fmt.Errorf("failed select order: %w", err)
 		}
@@ -128,9 +236,76 @@ func (s *Store) UploadOrder(ctx context.Context, userID uint, orderNumber string
 	return nil
 }
 
+// UploadOrders загружает пакет заказов пользователя в одной транзакции и возвращает результат
+// по каждому номеру. Номера, уже загруженные кем-либо, не меняются. Если номер из пакета
+// параллельно загрузил другой запрос, транзакция повторяется, и номер получает результат по записи
+// этого запроса вместо ошибки всего пакета.
+func (s *Store) UploadOrders(
+	ctx context.Context, userID uint, orderNumbers []string,
+) (map[string]model.OrderUploadResult, error) {
+	var err error
+	for range uploadOrdersAttempts {
+		var results map[string]model.OrderUploadResult
+		results, err = s.uploadOrders(ctx, userID, orderNumbers)
+		var sqlError *pgconn.PgError
+		if !errors.As(err, &sqlError) || sqlError.Code != pgerrcode.UniqueViolation {
+			return results, err
+		}
+	}
+
+	return nil, err
+}
+
+func (s *Store) uploadOrders(
+	ctx context.Context, userID uint, orderNumbers []string,
+) (map[string]model.OrderUploadResult, error) {
+	results := make(map[string]model.OrderUploadResult, len(orderNumbers))
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing := []*model.Order{}
+		if err := tx.Where("number IN ?", orderNumbers).Find(&existing).Error; err != nil {
+			return fmt.Errorf("failed select orders: %w", err)
+		}
+		for _, order := range existing {
+			results[order.Number] = model.OrderUploadOwnedByAnotherUser
+			if order.UserID == userID {
+				results[order.Number] = model.OrderUploadAlreadyUploaded
+			}
+		}
+
+		orders := []*model.Order{}
+		for _, number := range orderNumbers {
+			if _, ok := results[number]; ok {
+				continue
+			}
+			results[number] = model.OrderUploadAccepted
+			orders = append(orders, &model.Order{UserID: userID, Number: number, Status: model.OrderStateNew})
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+		if err := tx.Create(&orders).Error; err != nil {
+			return fmt.Errorf("failed create orders: %w", err)
+		}
+		changes := make([]*model.OrderStatusChange, 0, len(orders))
+		for _, order := range orders {
+			changes = append(changes, &model.OrderStatusChange{OrderID: order.ID, Status: order.Status})
+		}
+		if err := tx.Create(&changes).Error; err != nil {
+			return fmt.Errorf("failed save status of orders: %w", err)
+		}
+
+		return bumpUserDataVersion(tx, userID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed complite transaction: %w", err)
+	}
+
+	return results, nil
+}
+
 func (s *Store) GetUserBalance(ctx context.Context, userID uint) (model.Balance, error) {
 	balance := model.Balance{}
-	if err := s.db.Where(&model.Balance{UserID: userID}).First(&balance).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where(&model.Balance{UserID: userID}).First(&balance).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return balance, errstore.ErrNotFoundData
 		}
@@ -140,8 +315,14 @@ func (s *Store) GetUserBalance(ctx context.Context, userID uint) (model.Balance,
 	return balance, nil
 }
 
-func (s *Store) WithdrawFromUserBalance(ctx context.Context, userID uint, order string, sum float32) error {
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+func (s *Store) WithdrawFromUserBalance(
+	ctx context.Context,
+	userID uint,
+	order string,
+	sum float32,
+	merchantID uint,
+) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		balance := model.Balance{}
 		err := tx.Where(&model.Balance{UserID: userID}).First(&balance).Error
 		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -162,12 +343,13 @@ func (s *Store) WithdrawFromUserBalance(ctx context.Context, userID uint, order
 			OderNumber: order,
 			Sum:        sum,
 			BalanceID:  balance.ID,
+			MerchantID: merchantID,
 		}
 		if err := tx.Save(&withdraw).Error; err != nil {
 			return fmt.Errorf("failed save withdraw: %w", err)
 		}
 
-		return nil
+		return bumpUserDataVersion(tx, userID)
 	})
 
 	if err != nil {
@@ -177,9 +359,13 @@ func (s *Store) WithdrawFromUserBalance(ctx context.Context, userID uint, order
 	return nil
 }
 
-func (s *Store) GetUserOrders(ctx context.Context, userID uint) ([]*model.Order, error) {
+func (s *Store) GetUserOrders(ctx context.Context, userID uint, query model.ListQuery) ([]*model.Order, error) {
 	orders := []*model.Order{}
-	if err := s.db.Where(&model.Order{UserID: userID}).Find(&orders).Error; err != nil {
+	tx := selectOrders(s.db.WithContext(ctx)).Where(&model.Order{UserID: userID})
+	if len(query.Statuses) > 0 {
+		tx = tx.Where("status IN ?", query.Statuses)
+	}
+	if err := applyListQuery(tx, query).Find(&orders).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return orders, errstore.ErrNotFoundData
 		}
@@ -192,9 +378,75 @@ func (s *Store) GetUserOrders(ctx context.Context, userID uint) ([]*model.Order,
 	return orders, nil
 }
 
-func (s *Store) GetWithdrawalsFromBalance(ctx context.Context, balanceID uint) ([]*model.WithdrawBalance, error) {
+// GetUserOrder возвращает заказ пользователя по номеру. Заказ другого пользователя не выдается.
+func (s *Store) GetUserOrder(ctx context.Context, userID uint, number string) (model.Order, error) {
+	order := model.Order{}
+	err := selectOrders(s.db.WithContext(ctx)).Where(&model.Order{UserID: userID, Number: number}).First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return order, errstore.ErrNotFoundData
+		}
+		return order, fmt.Errorf("failed get order: %w", err)
+	}
+
+	return order, nil
+}
+
+func (s *Store) GetOrderStatusHistory(ctx context.Context, orderID uint) ([]*model.OrderStatusChange, error) {
+	history := []*model.OrderStatusChange{}
+	err := s.db.WithContext(ctx).Where(&model.OrderStatusChange{OrderID: orderID}).Order("id").Find(&history).Error
+	if err != nil {
+		return history, fmt.Errorf("failed get order status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// bumpUserDataVersion увеличивает версию данных пользователя. Вызывается в транзакции,
+// изменяющей заказы или баланс пользователя, по версии строятся ETag ответов API.
+func bumpUserDataVersion(tx *gorm.DB, userID uint) error {
+	err := tx.Model(&model.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("data_version", gorm.Expr("data_version + 1")).Error
+	if err != nil {
+		return fmt.Errorf("failed bump data version of user id=`%d`: %w", userID, err)
+	}
+
+	return nil
+}
+
+// selectOrders выбирает заказы вместе с временем обработки: временем первой записи истории
+// с конечным статусом. У заказов, обработанных до появления истории, оно пустое.
+func selectOrders(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&model.Order{}).Select(
+		"orders.*, (SELECT MIN(created_at) FROM order_status_changes "+
+			"WHERE order_id = orders.id AND status IN ?) AS processed_at",
+		[]model.OrderStatus{model.OrderStateProcessed, model.OrderStateInvalid},
+	)
+}
+
+func addOrderStatusChange(tx *gorm.DB, order *model.Order) error {
+	change := model.OrderStatusChange{
+		OrderID: order.ID,
+		Status:  order.Status,
+		Accrual: order.Accrual,
+	}
+	if change.Status == "" {
+		change.Status = model.OrderStateNew
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return fmt.Errorf("failed save status of order id=`%d`: %w", order.ID, err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetWithdrawalsFromBalance(
+	ctx context.Context, balanceID uint, query model.ListQuery,
+) ([]*model.WithdrawBalance, error) {
 	withdrawals := []*model.WithdrawBalance{}
-	if err := s.db.Where(&model.WithdrawBalance{BalanceID: balanceID}).Find(&withdrawals).Error; err != nil {
+	tx := s.db.WithContext(ctx).Where(&model.WithdrawBalance{BalanceID: balanceID})
+	if err := applyListQuery(tx, query).Find(&withdrawals).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return withdrawals, errstore.ErrNotFoundData
 		}
@@ -207,6 +459,56 @@ func (s *Store) GetWithdrawalsFromBalance(ctx context.Context, balanceID uint) (
 	return withdrawals, nil
 }
 
+func (s *Store) GetBalanceAdjustments(ctx context.Context, balanceID uint) ([]*model.BalanceAdjustment, error) {
+	adjustments := []*model.BalanceAdjustment{}
+	err := s.db.WithContext(ctx).Where(&model.BalanceAdjustment{BalanceID: balanceID}).Order("id").Find(&adjustments).Error
+	if err != nil {
+		return adjustments, fmt.Errorf("failed get balance adjustments: %w", err)
+	}
+
+	return adjustments, nil
+}
+
+// AnonymizeUser заменяет логин, удаляет учетные данные и второй фактор пользователя и отзывает его токены.
+// Логин заменяется и в событиях аудита. Заказы, списания и баланс не меняются.
+func (s *Store) AnonymizeUser(ctx context.Context, userID uint, login string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("id = ? AND deleted_at IS NULL", userID).Updates(map[string]any{
+			"login":          login,
+			"password_hash":  "",
+			"role":           model.RoleUser,
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+			"deleted_at":     time.Now(),
+			"token_version":  gorm.Expr("token_version + 1"),
+		})
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed anonymize user: %w", err)
+		}
+		if result.RowsAffected == 0 {
+			return errstore.ErrNotFoundData
+		}
+		if err := tx.Where(&model.RecoveryCode{UserID: userID}).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed delete recovery codes: %w", err)
+		}
+		if err := tx.Where(&model.MerchantUser{UserID: userID}).Delete(&model.MerchantUser{}).Error; err != nil {
+			return fmt.Errorf("failed delete merchant links: %w", err)
+		}
+		err := tx.Model(&model.AuditEvent{}).Where("user_id = ?", userID).
+			Updates(map[string]any{"login": login, "ip": ""}).Error
+		if err != nil {
+			return fmt.Errorf("failed anonymize audit events: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed complite transaction: %w", err)
+	}
+
+	return nil
+}
+
 func (s *Store) GetOrdersNotPrecessed(ctx context.Context) ([]*model.Order, error) {
 	orders := []*model.Order{}
 	db := s.db.WithContext(ctx)
@@ -218,12 +520,22 @@ func (s *Store) GetOrdersNotPrecessed(ctx context.Context) ([]*model.Order, erro
 	return orders, nil
 }
 
-func (s *Store) AddAccrual(ctx context.Context, order *model.Order) error {
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+// AddAccrual сохраняет ответ системы расчета начислений и возвращает баланс пользователя после начисления.
+func (s *Store) AddAccrual(ctx context.Context, order *model.Order) (model.Balance, error) {
+	balance := model.Balance{UserID: order.UserID}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current := model.Order{}
+		if err := tx.Select("status", "accrual").First(&current, order.ID).Error; err != nil {
+			return fmt.Errorf("failed get order id=`%d`: %w", order.ID, err)
+		}
 		if err := tx.Save(order).Error; err != nil {
 			return fmt.Errorf("failed update order id=`%d`: %w", order.ID, err)
 		}
-		balance := model.Balance{UserID: order.UserID}
+		if current.Status != order.Status || current.Accrual != order.Accrual {
+			if err := addOrderStatusChange(tx, order); err != nil {
+				return err
+			}
+		}
 		if err := tx.Where(&balance).First(&balance).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf("failed getting balance by user `%d`: %w", order.ID, err)
 		}
@@ -232,11 +544,61 @@ func (s *Store) AddAccrual(ctx context.Context, order *model.Order) error {
 			return fmt.Errorf("failed update balance by user `%d`: %w", order.UserID, err)
 		}
 
-		return nil
+		return bumpUserDataVersion(tx, order.UserID)
 	})
 	if err != nil {
-		return fmt.Errorf("failed complite transaction: %w", err)
+		return balance, fmt.Errorf("failed complite transaction: %w", err)
+	}
+
+	return balance, nil
+}
+
+func (s *Store) AddAuditEvent(ctx context.Context, event *model.AuditEvent) error {
+	if err := s.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed save audit event `%s`: %w", event.Action, err)
 	}
 
 	return nil
 }
+
+// AdjustUserBalance изменяет баланс пользователя на adjustment.Amount и в той же транзакции
+// сохраняет корректировку и событие аудита.
+func (s *Store) AdjustUserBalance(
+	ctx context.Context,
+	userID uint,
+	adjustment *model.BalanceAdjustment,
+	event *model.AuditEvent,
+) (model.Balance, error) {
+	balance := model.Balance{UserID: userID}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(&model.Balance{UserID: userID}).First(&balance).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed get balance: %w", err)
+		}
+
+		if balance.Current+adjustment.Amount < 0 {
+			return fmt.Errorf("%w: %f", errstore.ErrBalansNotEnough, adjustment.Amount)
+		}
+
+		balance.Current += adjustment.Amount
+		if err := tx.Save(&balance).Error; err != nil {
+			return fmt.Errorf("failed save balance: %w", err)
+		}
+
+		adjustment.BalanceID = balance.ID
+		if err := tx.Create(adjustment).Error; err != nil {
+			return fmt.Errorf("failed save balance adjustment: %w", err)
+		}
+
+		if err := tx.Create(event).Error; err != nil {
+			return fmt.Errorf("failed save audit event: %w", err)
+		}
+
+		return bumpUserDataVersion(tx, userID)
+	})
+	if err != nil {
+		return balance, fmt.Errorf("failed complite transaction: %w", err)
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+)
+
+// StreamOrders передает заказы в fn по одному по мере чтения из базы, выборка целиком в память
+// не загружается. userID 0 выбирает заказы всех пользователей. Ошибка fn прерывает чтение.
+func (s *Store) StreamOrders(
+	ctx context.Context, userID uint, query model.ListQuery, fn func(*model.Order) error,
+) error {
+	tx := s.db.WithContext(ctx).Model(&model.Order{}).
+		Select("id", "number", "status", "accrual", "user_id", "created_at", "updated_at")
+	if userID > 0 {
+		tx = tx.Where("user_id = ?", userID)
+	}
+	if len(query.Statuses) > 0 {
+		tx = tx.Where("status IN ?", query.Statuses)
+	}
+	rows, err := applyListQuery(tx, query).Rows()
+	if err != nil {
+		return fmt.Errorf("failed select orders: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		order := model.Order{}
+		err := rows.Scan(&order.ID, &order.Number, &order.Status, &order.Accrual, &order.UserID,
+			&order.CreatedAt, &order.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed scan order: %w", err)
+		}
+		if err := fn(&order); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed read orders: %w", err)
+	}
+
+	return nil
+}
+
+// StreamWithdrawals передает списания в fn по одному, как StreamOrders. Пользователь списания
+// заполняется в Balance.UserID. userID 0 выбирает списания всех пользователей.
+func (s *Store) StreamWithdrawals(
+	ctx context.Context, userID uint, query model.ListQuery, fn func(*model.WithdrawBalance) error,
+) error {
+	// пользователь выбирается подзапросом, чтобы фильтры и сортировка списка
+	// ссылались только на колонки withdraw_balances
+	balanceUser := s.db.Model(&model.Balance{}).Select("user_id").Where("balances.id = withdraw_balances.balance_id")
+	tx := s.db.WithContext(ctx).Model(&model.WithdrawBalance{}).
+		Select("id, oder_number, sum, balance_id, merchant_id, created_at, updated_at, (?) AS user_id", balanceUser)
+	if userID > 0 {
+		tx = tx.Where("balance_id IN (?)", s.db.Model(&model.Balance{}).Select("id").Where("user_id = ?", userID))
+	}
+	rows, err := applyListQuery(tx, query).Rows()
+	if err != nil {
+		return fmt.Errorf("failed select withdrawals: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		withdrawal := model.WithdrawBalance{}
+		err := rows.Scan(&withdrawal.ID, &withdrawal.OderNumber, &withdrawal.Sum, &withdrawal.BalanceID,
+			&withdrawal.MerchantID, &withdrawal.CreatedAt, &withdrawal.UpdatedAt, &withdrawal.Balance.UserID)
+		if err != nil {
+			return fmt.Errorf("failed scan withdrawal: %w", err)
+		}
+		withdrawal.Balance.ID = withdrawal.BalanceID
+		if err := fn(&withdrawal); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed read withdrawals: %w", err)
+	}
+
+	return nil
+}
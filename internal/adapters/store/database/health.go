@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"gorm.io/gorm"
+)
+
+// Ping проверяет соединение с базой данных.
+func (s *Store) Ping(ctx context.Context) error {
+	db, err := s.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed getting database connection: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed ping database: %w", err)
+	}
+
+	return nil
+}
+
+// CheckMigrations сверяет схему базы с моделями: все таблицы и колонки моделей должны существовать,
+// колонки времени должны быть переведены в timestamptz. Схема читается одним запросом
+// к information_schema, поэтому проверку можно вызывать из проверки готовности.
+func (s *Store) CheckMigrations(ctx context.Context) error {
+	var columns []struct {
+		TableName  string
+		ColumnName string
+		DataType   string
+	}
+	err := s.db.WithContext(ctx).Raw(
+		"SELECT table_name, column_name, data_type FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA()",
+	).Scan(&columns).Error
+	if err != nil {
+		return fmt.Errorf("failed read database schema: %w", err)
+	}
+	types := make(map[string]string, len(columns))
+	for _, column := range columns {
+		types[column.TableName+"."+column.ColumnName] = column.DataType
+	}
+
+	var missing []string
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: s.db}
+		if err := stmt.Parse(m); err != nil {
+			return fmt.Errorf("failed parse model %T: %w", m, err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			name := stmt.Schema.Table + "." + field.DBName
+			if _, ok := types[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+	}
+	for table, names := range timeColumns {
+		for _, column := range names {
+			name := table + "." + column
+			if dataType, ok := types[name]; ok && dataType != "timestamp with time zone" {
+				missing = append(missing, name+" (timestamptz)")
+			}
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("%w: %s", errstore.ErrMigrationsNotApplied, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
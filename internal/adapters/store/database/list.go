@@ -0,0 +1,73 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"gorm.io/gorm"
+)
+
+// timeColumns колонки времени, которые ранние версии схемы создавали с типом time без даты.
+var timeColumns = map[string][]string{
+	"users":             {"created_at", "updated_at"},
+	"orders":            {"created_at", "updated_at"},
+	"balances":          {"created_at", "updated_at"},
+	"withdraw_balances": {"created_at", "updated_at"},
+}
+
+// migrateTimeColumns переводит колонки типа time в timestamptz. Дата старых записей не сохранялась
+// и восстановлена быть не может, поэтому им подставляется последний момент до миграции с тем же временем суток:
+// сегодня, если это время уже прошло, иначе вчера. Так старые записи не оказываются в будущем.
+func migrateTimeColumns(db *gorm.DB) error {
+	for table, columns := range timeColumns {
+		for _, column := range columns {
+			var dataType string
+			err := db.Raw(
+				"SELECT data_type FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = ? AND column_name = ?",
+				table, column,
+			).Scan(&dataType).Error
+			if err != nil {
+				return fmt.Errorf("failed get type of %s.%s: %w", table, column, err)
+			}
+			if dataType != "time without time zone" && dataType != "time with time zone" {
+				continue
+			}
+			err = db.Exec(fmt.Sprintf(
+				"ALTER TABLE %[1]q ALTER COLUMN %[2]q TYPE timestamptz USING (CASE "+
+					"WHEN CURRENT_DATE + %[2]q::time > LOCALTIMESTAMP THEN CURRENT_DATE - 1 + %[2]q::time "+
+					"ELSE CURRENT_DATE + %[2]q::time END)",
+				table, column,
+			)).Error
+			if err != nil {
+				return fmt.Errorf("failed migrate %s.%s: %w", table, column, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyListQuery добавляет к выборке фильтры по времени создания, курсор, сортировку и лимит.
+// Записи упорядочены по времени создания, при равенстве по идентификатору.
+func applyListQuery(tx *gorm.DB, query model.ListQuery) *gorm.DB {
+	if !query.From.IsZero() {
+		tx = tx.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("created_at < ?", query.To)
+	}
+
+	direction, compare := "DESC", "<"
+	if query.Asc {
+		direction, compare = "ASC", ">"
+	}
+	if query.After != nil {
+		tx = tx.Where("(created_at, id) "+compare+" (?, ?)", query.After.CreatedAt, query.After.ID)
+	}
+	tx = tx.Order("created_at " + direction).Order("id " + direction)
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+
+	return tx
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/playmixer/gophermart/pkg/requestid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger пишет сообщения gorm в zap с идентификатором запроса из контекста.
+// Ошибки запросов и медленные запросы пишутся с уровнем Warn, остальные запросы с Debug.
+// Отсутствие записи ошибкой не считается, его обрабатывают методы хранилища.
+type gormLogger struct {
+	log *zap.Logger
+}
+
+func newGormLogger(log *zap.Logger) gormLogger {
+	return gormLogger{log: log.Named("store")}
+}
+
+func (l gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l gormLogger) Info(ctx context.Context, msg string, args ...any) {
+	requestid.Logger(ctx, l.log).Info(fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	requestid.Logger(ctx, l.log).Warn(fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, args ...any) {
+	requestid.Logger(ctx, l.log).Error(fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	log := requestid.Logger(ctx, l.log)
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		log.Warn("query failed",
+			zap.String("sql", sql), zap.Int64("rows", rows), zap.Duration("duration", elapsed), zap.Error(err))
+	case elapsed > slowQueryThreshold:
+		sql, rows := fc()
+		log.Warn("slow query",
+			zap.String("sql", sql), zap.Int64("rows", rows), zap.Duration("duration", elapsed))
+	case log.Core().Enabled(zap.DebugLevel):
+		sql, rows := fc()
+		log.Debug("query",
+			zap.String("sql", sql), zap.Int64("rows", rows), zap.Duration("duration", elapsed))
+	}
+}
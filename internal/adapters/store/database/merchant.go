@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (s *Store) CreateMerchant(ctx context.Context, merchant *model.Merchant) error {
+	if err := s.db.WithContext(ctx).Create(merchant).Error; err != nil {
+		var sqlError *pgconn.PgError
+		if errors.As(err, &sqlError) && sqlError.Code == pgerrcode.UniqueViolation {
+			return errstore.ErrMerchantNotUnique
+		}
+		return fmt.Errorf("failed save merchant: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetMerchant(ctx context.Context, merchantID uint) (model.Merchant, error) {
+	merchant := model.Merchant{}
+	if err := s.db.WithContext(ctx).First(&merchant, merchantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return merchant, errors.Join(errstore.ErrNotFoundData, err)
+		}
+		return merchant, fmt.Errorf("error found merchant id=`%d`: %w", merchantID, err)
+	}
+
+	return merchant, nil
+}
+
+func (s *Store) CreateMerchantKey(ctx context.Context, key *model.MerchantKey) error {
+	if err := s.db.WithContext(ctx).Omit("Merchant").Create(key).Error; err != nil {
+		return fmt.Errorf("failed save merchant key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetMerchantKey(ctx context.Context, prefix string) (model.MerchantKey, error) {
+	key := model.MerchantKey{}
+	err := s.db.WithContext(ctx).Preload("Merchant").Where(&model.MerchantKey{Prefix: prefix}).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return key, errors.Join(errstore.ErrNotFoundData, err)
+		}
+		return key, fmt.Errorf("error found merchant key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *Store) RevokeMerchantKey(ctx context.Context, merchantID, keyID uint) error {
+	result := s.db.WithContext(ctx).Model(&model.MerchantKey{}).
+		Where("id = ? AND merchant_id = ? AND revoked_at IS NULL", keyID, merchantID).
+		Update("revoked_at", time.Now())
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed revoke merchant key: %w", err)
+	}
+	if result.RowsAffected == 0 {
+		return errstore.ErrNotFoundData
+	}
+
+	return nil
+}
+
+func (s *Store) LinkMerchantUser(ctx context.Context, merchantID, userID uint) error {
+	link := model.MerchantUser{MerchantID: merchantID, UserID: userID}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+		return fmt.Errorf("failed save merchant user: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UnlinkMerchantUser(ctx context.Context, merchantID, userID uint) error {
+	result := s.db.WithContext(ctx).
+		Where(&model.MerchantUser{MerchantID: merchantID, UserID: userID}).
+		Delete(&model.MerchantUser{})
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed delete merchant user: %w", err)
+	}
+	if result.RowsAffected == 0 {
+		return errstore.ErrNotFoundData
+	}
+
+	return nil
+}
+
+func (s *Store) IsMerchantUser(ctx context.Context, merchantID, userID uint) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.MerchantUser{}).
+		Where(&model.MerchantUser{MerchantID: merchantID, UserID: userID}).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed check merchant user: %w", err)
+	}
+
+	return count > 0, nil
+}
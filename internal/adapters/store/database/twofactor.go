@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"gorm.io/gorm"
+)
+
+// SetUserTOTPSecret сохраняет секрет аутентификатора, ожидающий подтверждения.
+func (s *Store) SetUserTOTPSecret(ctx context.Context, userID uint, secret string) error {
+	result := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND totp_enabled = ?", userID, false).
+		Update("totp_secret", secret)
+	if err := result.Error; err != nil {
+		return fmt.Errorf("failed update totp secret of user id=`%d`: %w", userID, err)
+	}
+	if result.RowsAffected == 0 {
+		return errstore.ErrNotFoundData
+	}
+
+	return nil
+}
+
+// EnableUserTOTP включает двухфакторную аутентификацию и заменяет коды восстановления.
+func (s *Store) EnableUserTOTP(ctx context.Context, userID uint, step int64, codes []*model.RecoveryCode) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("id = ? AND totp_enabled = ?", userID, false).Updates(map[string]any{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		})
+		if err := result.Error; err != nil {
+			return fmt.Errorf("failed enable totp: %w", err)
+		}
+		if result.RowsAffected == 0 {
+			return errstore.ErrNotFoundData
+		}
+		if err := tx.Where(&model.RecoveryCode{UserID: userID}).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed delete recovery codes: %w", err)
+		}
+		if len(codes) > 0 {
+			if err := tx.Create(codes).Error; err != nil {
+				return fmt.Errorf("failed save recovery codes: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed complite transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DisableUserTOTP(ctx context.Context, userID uint) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed disable totp: %w", err)
+		}
+		if err := tx.Where(&model.RecoveryCode{UserID: userID}).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed delete recovery codes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed complite transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep запоминает шаг принятого кода. Возвращает false, если код этого или более позднего шага
+// уже был использован.
+func (s *Store) UseTOTPStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if err := result.Error; err != nil {
+		return false, fmt.Errorf("failed update totp step of user id=`%d`: %w", userID, err)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// UseRecoveryCode помечает код восстановления использованным. Возвращает false, если кода нет
+// или он уже был использован.
+func (s *Store) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if err := result.Error; err != nil {
+		return false, fmt.Errorf("failed use recovery code of user id=`%d`: %w", userID, err)
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -8,4 +8,6 @@ var (
 	ErrOrderWasCreatedAnotherUser = errors.New("order was created another user")
 	ErrOrderWasCreatedByUser      = errors.New("order was create by user")
 	ErrBalansNotEnough            = errors.New("balance is not enough")
+	ErrMerchantNotUnique          = errors.New("merchant name not unique")
+	ErrMigrationsNotApplied       = errors.New("migrations not applied")
 )
@@ -4,12 +4,28 @@ import (
 	"time"
 )
 
+type UserRole string
+
+const (
+	RoleUser    UserRole = "USER"
+	RoleSupport UserRole = "SUPPORT"
+	RoleAdmin   UserRole = "ADMIN"
+)
+
 type User struct {
-	CreatedAt    time.Time `gorm:"type:time"`
-	UpdatedAt    time.Time `gorm:"type:time"`
-	Login        string    `gorm:"unique"`
-	PasswordHash string    `gorm:"type:string"`
-	ID           uint      `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    *time.Time
+	Login        string   `gorm:"unique"`
+	PasswordHash string   `gorm:"type:string"`
+	Role         UserRole `gorm:"not null;default:USER"`
+	TOTPSecret   string
+	ID           uint  `gorm:"primarykey"`
+	TokenVersion uint  `gorm:"not null;default:0"`
+	TOTPLastStep int64 `gorm:"not null;default:0"`
+	// DataVersion растет при каждом изменении заказов и баланса пользователя.
+	DataVersion uint64 `gorm:"not null;default:0"`
+	TOTPEnabled bool   `gorm:"not null;default:false"`
 }
 
 type OrderStatus string
@@ -22,19 +38,48 @@ const (
 )
 
 type Order struct {
-	CreatedAt time.Time   `gorm:"type:time"`
-	UpdatedAt time.Time   `gorm:"type:time"`
-	Number    string      `gorm:"unique,index"`
-	Status    OrderStatus `gorm:"default:NEW"`
-	User      User
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// ProcessedAt время перехода в конечный статус по истории статусов, только для чтения.
+	ProcessedAt *time.Time  `gorm:"->;-:migration"`
+	Number      string      `gorm:"unique,index"`
+	Status      OrderStatus `gorm:"default:NEW"`
+	User        User
+	ID          uint    `gorm:"primarykey"`
+	UserID      uint    `gorm:"index"`
+	MerchantID  uint    `gorm:"index"`
+	Accrual     float32 `gorm:"type:float"`
+}
+
+// OrderUploadResult результат загрузки номера заказа в пакете.
+type OrderUploadResult string
+
+const (
+	OrderUploadAccepted           OrderUploadResult = "accepted"
+	OrderUploadAlreadyUploaded    OrderUploadResult = "already_uploaded"
+	OrderUploadOwnedByAnotherUser OrderUploadResult = "owned_by_another_user"
+	OrderUploadInvalid            OrderUploadResult = "invalid"
+)
+
+// OrderUpload результат загрузки одного номера из пакета.
+type OrderUpload struct {
+	Number string
+	Result OrderUploadResult
+}
+
+// OrderStatusChange смена статуса заказа. Первая запись создается при загрузке заказа,
+// следующие при каждом новом статусе, полученном от системы расчета начислений.
+type OrderStatusChange struct {
+	CreatedAt time.Time
+	Status    OrderStatus
 	ID        uint    `gorm:"primarykey"`
-	UserID    uint    `gorm:"index"`
+	OrderID   uint    `gorm:"index"`
 	Accrual   float32 `gorm:"type:float"`
 }
 
 type Balance struct {
-	CreatedAt time.Time `gorm:"type:time"`
-	UpdatedAt time.Time `gorm:"type:time"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
 	User      User
 	ID        uint    `gorm:"primarykey"`
 	UserID    uint    `gorm:"unique"`
@@ -43,11 +88,96 @@ type Balance struct {
 }
 
 type WithdrawBalance struct {
-	CreatedAt  time.Time `gorm:"type:time"`
-	UpdatedAt  time.Time `gorm:"type:time"`
-	OderNumber string    `gorm:"type:string"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	OderNumber string `gorm:"type:string"`
 	Balance    Balance
 	ID         uint    `gorm:"primarykey"`
 	BalanceID  uint    `gorm:"index"`
+	MerchantID uint    `gorm:"index"`
 	Sum        float32 `gorm:"type:float"`
 }
+
+type AuditAction string
+
+const (
+	AuditLoginLocked     AuditAction = "LOGIN_LOCKED"
+	AuditPasswordChanged AuditAction = "PASSWORD_CHANGED"
+	AuditRoleChanged     AuditAction = "ROLE_CHANGED"
+	AuditBalanceAdjusted AuditAction = "BALANCE_ADJUSTED"
+	AuditMerchantCreated AuditAction = "MERCHANT_CREATED"
+	AuditMerchantKey     AuditAction = "MERCHANT_KEY_CREATED"
+	AuditMerchantRevoked AuditAction = "MERCHANT_KEY_REVOKED"
+	AuditMerchantLinked  AuditAction = "MERCHANT_USER_LINKED"
+	AuditMerchantUnlink  AuditAction = "MERCHANT_USER_UNLINKED"
+	AuditTOTPEnabled     AuditAction = "TOTP_ENABLED"
+	AuditTOTPDisabled    AuditAction = "TOTP_DISABLED"
+	AuditRecoveryUsed    AuditAction = "RECOVERY_CODE_USED"
+	AuditUserDeleted     AuditAction = "USER_DELETED"
+	AuditDataExported    AuditAction = "DATA_EXPORTED"
+)
+
+type AuditEvent struct {
+	CreatedAt time.Time
+	Action    AuditAction `gorm:"index"`
+	Login     string
+	IP        string
+	Details   string
+	ID        uint `gorm:"primarykey"`
+	UserID    uint `gorm:"index"`
+}
+
+// RecoveryCode одноразовый код восстановления доступа при включенной двухфакторной аутентификации.
+// Хранится только хеш кода.
+type RecoveryCode struct {
+	CreatedAt time.Time
+	UsedAt    *time.Time
+	CodeHash  string `gorm:"index"`
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index"`
+}
+
+// BalanceAdjustment ручная корректировка баланса сотрудником поддержки.
+type BalanceAdjustment struct {
+	CreatedAt time.Time
+	Reason    string
+	Balance   Balance
+	ID        uint    `gorm:"primarykey"`
+	BalanceID uint    `gorm:"index"`
+	ActorID   uint    `gorm:"index"`
+	Amount    float32 `gorm:"type:float"`
+}
+
+type MerchantScope string
+
+const (
+	ScopeOrdersWrite     MerchantScope = "orders:write"
+	ScopeBalanceWithdraw MerchantScope = "balance:withdraw"
+)
+
+// Merchant магазин, который вызывает API от имени связанных с ним пользователей.
+type Merchant struct {
+	CreatedAt time.Time
+	Name      string `gorm:"unique"`
+	ID        uint   `gorm:"primarykey"`
+}
+
+// MerchantKey API ключ магазина. Хранится только хеш ключа, Prefix используется для поиска.
+// Scopes содержит разрешения через запятую.
+type MerchantKey struct {
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+	Prefix     string `gorm:"uniqueIndex"`
+	KeyHash    string
+	Scopes     string
+	Merchant   Merchant
+	ID         uint `gorm:"primarykey"`
+	MerchantID uint `gorm:"index"`
+}
+
+// MerchantUser связь магазина с пользователем, от имени которого магазину разрешено действовать.
+type MerchantUser struct {
+	CreatedAt  time.Time
+	MerchantID uint `gorm:"primaryKey;autoIncrement:false"`
+	UserID     uint `gorm:"primaryKey;autoIncrement:false"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// ListQuery параметры выборки списка заказов или списаний. Нулевое значение выбирает
+// все записи от новых к старым. Statuses применяется только к заказам.
+type ListQuery struct {
+	From     time.Time
+	To       time.Time
+	After    *ListCursor
+	Statuses []OrderStatus
+	Limit    int
+	Asc      bool
+}
+
+// ListCursor позиция в списке: время создания и идентификатор последней выданной записи.
+type ListCursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
@@ -16,13 +16,44 @@ type Config struct {
 type Store interface {
 	RegisterUser(ctx context.Context, login, hashPassword string) error
 	GetUserByLogin(ctx context.Context, login string) (model.User, error)
-	UploadOrder(ctx context.Context, userID uint, orderNumber string) error
-	GetUserOrders(ctx context.Context, userID uint) ([]*model.Order, error)
+	GetUserByID(ctx context.Context, userID uint) (model.User, error)
+	ChangeUserPassword(ctx context.Context, userID uint, hashPassword string) (model.User, error)
+	UpdateUserPasswordHash(ctx context.Context, userID uint, hashPassword string) error
+	SetUserTOTPSecret(ctx context.Context, userID uint, secret string) error
+	EnableUserTOTP(ctx context.Context, userID uint, step int64, codes []*model.RecoveryCode) error
+	DisableUserTOTP(ctx context.Context, userID uint) error
+	UseTOTPStep(ctx context.Context, userID uint, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error)
+	AnonymizeUser(ctx context.Context, userID uint, login string) error
+	SearchUsers(ctx context.Context, login string, limit int) ([]*model.User, error)
+	SetUserRole(ctx context.Context, userID uint, role model.UserRole) error
+	AdjustUserBalance(ctx context.Context, userID uint, adjustment *model.BalanceAdjustment, event *model.AuditEvent) (model.Balance, error)
+	UploadOrder(ctx context.Context, userID uint, orderNumber string, merchantID uint) error
+	UploadOrders(ctx context.Context, userID uint, orderNumbers []string) (map[string]model.OrderUploadResult, error)
+	GetUserOrders(ctx context.Context, userID uint, query model.ListQuery) ([]*model.Order, error)
+	GetUserOrder(ctx context.Context, userID uint, number string) (model.Order, error)
+	GetOrderStatusHistory(ctx context.Context, orderID uint) ([]*model.OrderStatusChange, error)
 	GetUserBalance(ctx context.Context, userID uint) (model.Balance, error)
-	WithdrawFromUserBalance(ctx context.Context, userID uint, order string, sum float32) error
-	GetWithdrawalsFromBalance(ctx context.Context, balanceID uint) ([]*model.WithdrawBalance, error)
+	WithdrawFromUserBalance(ctx context.Context, userID uint, order string, sum float32, merchantID uint) error
+	GetWithdrawalsFromBalance(ctx context.Context, balanceID uint, query model.ListQuery) ([]*model.WithdrawBalance, error)
+	GetBalanceAdjustments(ctx context.Context, balanceID uint) ([]*model.BalanceAdjustment, error)
 	GetOrdersNotPrecessed(ctx context.Context) ([]*model.Order, error)
-	AddAccrual(ctx context.Context, order *model.Order) error
+	AddAccrual(ctx context.Context, order *model.Order) (model.Balance, error)
+	AddAuditEvent(ctx context.Context, event *model.AuditEvent) error
+	CreateMerchant(ctx context.Context, merchant *model.Merchant) error
+	GetMerchant(ctx context.Context, merchantID uint) (model.Merchant, error)
+	CreateMerchantKey(ctx context.Context, key *model.MerchantKey) error
+	GetMerchantKey(ctx context.Context, prefix string) (model.MerchantKey, error)
+	RevokeMerchantKey(ctx context.Context, merchantID, keyID uint) error
+	LinkMerchantUser(ctx context.Context, merchantID, userID uint) error
+	UnlinkMerchantUser(ctx context.Context, merchantID, userID uint) error
+	IsMerchantUser(ctx context.Context, merchantID, userID uint) (bool, error)
+	StreamOrders(ctx context.Context, userID uint, query model.ListQuery, fn func(*model.Order) error) error
+	StreamWithdrawals(
+		ctx context.Context, userID uint, query model.ListQuery, fn func(*model.WithdrawBalance) error,
+	) error
+	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
 	CloseDB() error
 }
 
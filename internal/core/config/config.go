@@ -8,6 +8,7 @@ import (
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
+	"github.com/playmixer/gophermart/internal/adapters/api/grpcapi"
 	"github.com/playmixer/gophermart/internal/adapters/api/rest"
 	"github.com/playmixer/gophermart/internal/adapters/store"
 	"github.com/playmixer/gophermart/internal/adapters/store/database"
@@ -16,6 +17,7 @@ import (
 
 type Config struct {
 	Rest       *rest.Config
+	GRPC       *grpcapi.Config
 	Store      *store.Config
 	Gophermart *gophermart.Config
 	Secret     string `env:"SECRET_KEY" envDefault:"secret_key"`
@@ -26,6 +28,7 @@ type Config struct {
 func Init() (*Config, error) {
 	cfg := &Config{
 		Rest: &rest.Config{},
+		GRPC: &grpcapi.Config{},
 		Store: &store.Config{
 			Database: &database.Config{},
 		},
@@ -41,10 +44,15 @@ func Init() (*Config, error) {
 	}
 
 	regStringFlag(&cfg.Rest.Address, "a", cfg.Rest.Address, "address listen")
+	regStringFlag(&cfg.GRPC.Address, "g", cfg.GRPC.Address, "grpc address listen, empty disables grpc")
 	regStringFlag(&cfg.Store.Database.DSN, "d", cfg.Store.Database.DSN, "database dsn")
 	regStringFlag(&cfg.Gophermart.AccrualAddress, "r", cfg.Gophermart.AccrualAddress, "address accrual system")
 	flag.Parse()
 
+	if err := cfg.Gophermart.Password.Validate(); err != nil {
+		return cfg, fmt.Errorf("failed validate password config: %w", err)
+	}
+
 	return cfg, nil
 }
 
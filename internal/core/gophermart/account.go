@@ -0,0 +1,89 @@
+package gophermart
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+)
+
+var (
+	deletedLoginPrefix = "deleted:"
+	deletedLoginSize   = 16
+)
+
+// UserExport персональные данные пользователя для выгрузки.
+type UserExport struct {
+	User        model.User
+	Balance     model.Balance
+	Orders      []*model.Order
+	Withdrawals []*model.WithdrawBalance
+	Adjustments []*model.BalanceAdjustment
+}
+
+// ExportUserData собирает профиль, заказы, списания и историю изменений баланса пользователя.
+func (g *Gophermart) ExportUserData(ctx context.Context, userID uint) (UserExport, error) {
+	var export UserExport
+	var err error
+	if export.User, err = g.store.GetUserByID(ctx, userID); err != nil {
+		return export, fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+
+	if export.Orders, err = g.store.GetUserOrders(ctx, userID, model.ListQuery{}); err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		return export, fmt.Errorf("failed getting orders: %w", err)
+	}
+
+	export.Balance, err = g.store.GetUserBalance(ctx, userID)
+	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			return export, nil
+		}
+		return export, fmt.Errorf("failed getting balance: %w", err)
+	}
+
+	export.Withdrawals, err = g.store.GetWithdrawalsFromBalance(ctx, export.Balance.ID, model.ListQuery{})
+	if err != nil && !errors.Is(err, errstore.ErrNotFoundData) {
+		return export, fmt.Errorf("failed getting withdrawals: %w", err)
+	}
+
+	if export.Adjustments, err = g.store.GetBalanceAdjustments(ctx, export.Balance.ID); err != nil {
+		return export, fmt.Errorf("failed getting balance adjustments: %w", err)
+	}
+
+	return export, nil
+}
+
+// DeleteUser удаляет учетную запись после проверки пароля. Логин и учетные данные обезличиваются,
+// заказы, списания и баланс сохраняются для бухгалтерского учета. Выданные токены отзываются.
+func (g *Gophermart) DeleteUser(ctx context.Context, userID uint, password string) error {
+	user, err := g.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+
+	if ok, _ := g.hasher.Check(password, user.PasswordHash); !ok {
+		return ErrPasswordNotEquale
+	}
+
+	suffix := make([]byte, deletedLoginSize)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed generate anonymous login: %w", err)
+	}
+	login := deletedLoginPrefix + hex.EncodeToString(suffix)
+
+	if err := g.store.AnonymizeUser(ctx, userID, login); err != nil {
+		return fmt.Errorf("failed anonymize user id=`%d`: %w", userID, err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action: model.AuditUserDeleted,
+		UserID: userID,
+		Login:  login,
+	})
+
+	return nil
+}
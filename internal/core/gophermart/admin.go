@@ -0,0 +1,97 @@
+package gophermart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"go.uber.org/zap"
+)
+
+var (
+	searchUsersLimit = 50
+)
+
+// BootstrapAdmins назначает роль администратора пользователям из Config.AdminLogins.
+func (g *Gophermart) BootstrapAdmins(ctx context.Context) error {
+	for _, login := range g.cfg.AdminLogins {
+		user, err := g.store.GetUserByLogin(ctx, login)
+		if err != nil {
+			if errors.Is(err, errstore.ErrNotFoundData) {
+				g.log.Warn("admin login not registered", zap.String("login", login))
+				continue
+			}
+			return fmt.Errorf("failed getting user `%s`: %w", login, err)
+		}
+		if user.Role == model.RoleAdmin {
+			continue
+		}
+		if err := g.SetUserRole(ctx, 0, user.ID, model.RoleAdmin); err != nil {
+			return fmt.Errorf("failed set admin role to `%s`: %w", login, err)
+		}
+	}
+
+	return nil
+}
+
+func (g *Gophermart) SearchUsers(ctx context.Context, login string) ([]*model.User, error) {
+	users, err := g.store.SearchUsers(ctx, login, searchUsersLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed search users by `%s`: %w", login, err)
+	}
+
+	return users, nil
+}
+
+func (g *Gophermart) SetUserRole(ctx context.Context, actorID, userID uint, role model.UserRole) error {
+	switch role {
+	case model.RoleUser, model.RoleSupport, model.RoleAdmin:
+	default:
+		return ErrRoleNotValid
+	}
+
+	if err := g.store.SetUserRole(ctx, userID, role); err != nil {
+		return fmt.Errorf("failed set role of user id=`%d`: %w", userID, err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action:  model.AuditRoleChanged,
+		UserID:  userID,
+		Details: fmt.Sprintf("actor=%d role=%s", actorID, role),
+	})
+
+	return nil
+}
+
+// AdjustBalance вручную изменяет баланс пользователя. Положительная сумма начисляет баллы,
+// отрицательная списывает. Корректировка и событие аудита сохраняются в одной транзакции.
+func (g *Gophermart) AdjustBalance(
+	ctx context.Context,
+	actorID, userID uint,
+	amount float32,
+	reason string,
+) (model.Balance, error) {
+	if amount == 0 || reason == "" {
+		return model.Balance{}, ErrAdjustmentNotValid
+	}
+
+	adjustment := &model.BalanceAdjustment{
+		ActorID: actorID,
+		Amount:  amount,
+		Reason:  reason,
+	}
+	event := &model.AuditEvent{
+		Action:  model.AuditBalanceAdjusted,
+		UserID:  userID,
+		Details: fmt.Sprintf("actor=%d amount=%.2f reason=%s", actorID, amount, reason),
+	}
+	balance, err := g.store.AdjustUserBalance(ctx, userID, adjustment, event)
+	if err != nil {
+		return balance, fmt.Errorf("failed adjust balance of user id=`%d`: %w", userID, err)
+	}
+	g.events.publish(Event{Type: EventBalance, UserID: userID, Balance: &balance})
+
+	return balance, nil
+}
@@ -1,10 +1,44 @@
 package gophermart
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	ErrPasswordNotValid    = errors.New("password is not valid")
 	ErrLoginNotValid       = errors.New("login is not valid")
 	ErrPasswordNotEquale   = errors.New("password not equale")
 	ErrOrderNumberNotValid = errors.New("order number not valid")
+	ErrSumNotValid         = errors.New("sum must be positive")
+	ErrPasswordNotChanged  = errors.New("new password equals the current one")
+	ErrRoleNotValid        = errors.New("role is not valid")
+	ErrAdjustmentNotValid  = errors.New("balance adjustment is not valid")
+	ErrLoginThrottled      = errors.New("too many login attempts")
+	ErrLoginLocked         = errors.New("login temporarily locked")
+	ErrMerchantNotValid    = errors.New("merchant is not valid")
+	ErrScopeNotValid       = errors.New("merchant key scope is not valid")
+	ErrMerchantKeyNotValid = errors.New("merchant key is not valid")
+	ErrMerchantNotLinked   = errors.New("merchant is not linked to user")
+
+	ErrSecondFactorRequired = errors.New("second factor required")
+	ErrTOTPAlreadyEnabled   = errors.New("totp already enabled")
+	ErrTOTPNotEnrolled      = errors.New("totp is not enrolled")
+	ErrTOTPCodeNotValid     = errors.New("totp code is not valid")
 )
+
+// LoginAttemptsError возвращается, когда попытка входа отклонена защитой от перебора.
+// Err равен ErrLoginThrottled или ErrLoginLocked.
+type LoginAttemptsError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LoginAttemptsError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Err, e.RetryAfter)
+}
+
+func (e *LoginAttemptsError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,219 @@
+package gophermart
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"go.uber.org/zap"
+)
+
+type EventType string
+
+const (
+	EventOrder   EventType = "order"
+	EventBalance EventType = "balance"
+)
+
+// EventsConfig настройки событий пользователей. History событий каждого пользователя хранится
+// HistoryTTL после последнего события для переподключения по Last-Event-ID. SubscriberBuffer
+// размер очереди подписчика: подписчик, не успевающий забирать события, отключается.
+type EventsConfig struct {
+	History          int           `env:"EVENTS_HISTORY" envDefault:"100"`
+	HistoryTTL       time.Duration `env:"EVENTS_HISTORY_TTL" envDefault:"10m"`
+	SubscriberBuffer int           `env:"EVENTS_SUBSCRIBER_BUFFER" envDefault:"32"`
+}
+
+// Event событие пользователя: новый статус заказа или новый баланс.
+type Event struct {
+	CreatedAt time.Time
+	Order     *model.Order
+	Balance   *model.Balance
+	Type      EventType
+	ID        uint64
+	UserID    uint
+}
+
+type eventSubscriber struct {
+	ch chan Event
+}
+
+type eventHistory struct {
+	updatedAt time.Time
+	events    []Event
+}
+
+// eventBus рассылает события подписчикам пользователя внутри процесса. Публикация не блокируется:
+// если очередь подписчика заполнена, его канал закрывается, и клиент переподключается
+// с последним полученным идентификатором. Идентификаторы событий начинаются со времени запуска,
+// поэтому после перезапуска сервиса не повторяют выданные раньше.
+type eventBus struct {
+	lastPrune   time.Time
+	mu          *sync.Mutex
+	subscribers map[uint]map[*eventSubscriber]struct{}
+	history     map[uint]*eventHistory
+	now         func() time.Time
+	cfg         EventsConfig
+	seq         uint64
+	closed      bool
+}
+
+var eventsPruneInterval = time.Minute
+
+func newEventBus(cfg EventsConfig) *eventBus {
+	now := time.Now()
+	return &eventBus{
+		lastPrune:   now,
+		mu:          &sync.Mutex{},
+		subscribers: make(map[uint]map[*eventSubscriber]struct{}),
+		history:     make(map[uint]*eventHistory),
+		now:         time.Now,
+		cfg:         cfg,
+		seq:         uint64(now.UnixMicro()), //nolint:gosec // время после 1970 года положительно
+	}
+}
+
+func (b *eventBus) publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	now := b.now()
+	b.seq++
+	event.ID = b.seq
+	event.CreatedAt = now
+
+	if b.cfg.History > 0 {
+		h, ok := b.history[event.UserID]
+		if !ok {
+			h = &eventHistory{}
+			b.history[event.UserID] = h
+		}
+		h.updatedAt = now
+		h.events = append(h.events, event)
+		if len(h.events) > b.cfg.History {
+			h.events = slices.Clone(h.events[len(h.events)-b.cfg.History:])
+		}
+	}
+
+	for sub := range b.subscribers[event.UserID] {
+		select {
+		case sub.ch <- event:
+		default:
+			b.remove(event.UserID, sub)
+		}
+	}
+
+	b.prune(now)
+}
+
+// subscribe подписывает на события пользователя. Если lastEventID больше нуля, в канал сначала
+// попадают сохраненные события после него. Канал закрывается вызовом cancel, при отключении
+// медленного подписчика и при остановке сервиса.
+func (b *eventBus) subscribe(userID uint, lastEventID uint64) (events <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if h, ok := b.history[userID]; ok && lastEventID > 0 {
+		for _, event := range h.events {
+			if event.ID > lastEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub := &eventSubscriber{ch: make(chan Event, b.cfg.SubscriberBuffer+len(replay))}
+	for _, event := range replay {
+		sub.ch <- event
+	}
+	if b.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+
+	if _, ok := b.subscribers[userID]; !ok {
+		b.subscribers[userID] = make(map[*eventSubscriber]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+
+	return sub.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userID, sub)
+	}
+}
+
+func (b *eventBus) remove(userID uint, sub *eventSubscriber) {
+	subs, ok := b.subscribers[userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(b.subscribers, userID)
+	}
+}
+
+func (b *eventBus) prune(now time.Time) {
+	if now.Sub(b.lastPrune) < eventsPruneInterval {
+		return
+	}
+	b.lastPrune = now
+	for userID, h := range b.history {
+		if now.Sub(h.updatedAt) > b.cfg.HistoryTTL {
+			delete(b.history, userID)
+		}
+	}
+}
+
+// close отключает всех подписчиков, новые подписки сразу закрываются.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for userID, subs := range b.subscribers {
+		for sub := range subs {
+			b.remove(userID, sub)
+		}
+	}
+}
+
+// SubscribeEvents подписывает на события пользователя: смену статусов заказов и баланса.
+// lastEventID идентификатор последнего полученного события при переподключении или 0.
+// Канал закрывается вызовом cancel, при отключении не успевающего подписчика и при остановке
+// сервиса, после закрытия клиенту стоит переподключиться с последним идентификатором.
+func (g *Gophermart) SubscribeEvents(userID uint, lastEventID uint64) (events <-chan Event, cancel func()) {
+	return g.events.subscribe(userID, lastEventID)
+}
+
+// publishAccrual публикует изменения после ответа системы расчета начислений.
+func (g *Gophermart) publishAccrual(order model.Order, previous model.Order, balance model.Balance) {
+	if order.Status != previous.Status || order.Accrual != previous.Accrual {
+		g.events.publish(Event{Type: EventOrder, UserID: order.UserID, Order: &order})
+	}
+	if order.Accrual != 0 {
+		g.events.publish(Event{Type: EventBalance, UserID: order.UserID, Balance: &balance})
+	}
+}
+
+// publishBalance публикует текущий баланс пользователя после списания. Ошибка чтения баланса
+// не отменяет списание и только логируется.
+func (g *Gophermart) publishBalance(ctx context.Context, userID uint) {
+	balance, err := g.store.GetUserBalance(ctx, userID)
+	if err != nil {
+		g.logger(ctx).Error("failed getting balance for event", zap.Uint("userID", userID), zap.Error(err))
+		return
+	}
+	g.events.publish(Event{Type: EventBalance, UserID: userID, Balance: &balance})
+}
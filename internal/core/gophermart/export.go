@@ -0,0 +1,59 @@
+package gophermart
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+)
+
+// StreamUserOrders передает заказы пользователя в fn по одному по мере чтения из хранилища,
+// поэтому выгрузка любого размера не загружается в память. Ошибка fn прерывает выгрузку.
+func (g *Gophermart) StreamUserOrders(
+	ctx context.Context, userID uint, query model.ListQuery, fn func(*model.Order) error,
+) error {
+	if err := g.store.StreamOrders(ctx, userID, query, fn); err != nil {
+		return fmt.Errorf("failed stream orders of user id=`%d`: %w", userID, err)
+	}
+	return nil
+}
+
+// StreamUserWithdrawals передает списания пользователя в fn по одному, как StreamUserOrders.
+func (g *Gophermart) StreamUserWithdrawals(
+	ctx context.Context, userID uint, query model.ListQuery, fn func(*model.WithdrawBalance) error,
+) error {
+	if err := g.store.StreamWithdrawals(ctx, userID, query, fn); err != nil {
+		return fmt.Errorf("failed stream withdrawals of user id=`%d`: %w", userID, err)
+	}
+	return nil
+}
+
+// StreamAllOrders выгрузка заказов всех пользователей администратором, пишется в журнал аудита.
+func (g *Gophermart) StreamAllOrders(
+	ctx context.Context, actorID uint, query model.ListQuery, fn func(*model.Order) error,
+) error {
+	g.audit(ctx, &model.AuditEvent{
+		Action:  model.AuditDataExported,
+		UserID:  actorID,
+		Details: "orders",
+	})
+	if err := g.store.StreamOrders(ctx, 0, query, fn); err != nil {
+		return fmt.Errorf("failed stream orders: %w", err)
+	}
+	return nil
+}
+
+// StreamAllWithdrawals выгрузка списаний всех пользователей администратором, пишется в журнал аудита.
+func (g *Gophermart) StreamAllWithdrawals(
+	ctx context.Context, actorID uint, query model.ListQuery, fn func(*model.WithdrawBalance) error,
+) error {
+	g.audit(ctx, &model.AuditEvent{
+		Action:  model.AuditDataExported,
+		UserID:  actorID,
+		Details: "withdrawals",
+	})
+	if err := g.store.StreamWithdrawals(ctx, 0, query, fn); err != nil {
+		return fmt.Errorf("failed stream withdrawals: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package gophermart
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,38 +11,83 @@ import (
 	"sync"
 	"time"
 
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
 	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"github.com/playmixer/gophermart/pkg/requestid"
 	"go.uber.org/zap"
 )
 
 type Store interface {
 	RegisterUser(ctx context.Context, login, hashPassword string) error
 	GetUserByLogin(ctx context.Context, login string) (model.User, error)
-	UploadOrder(ctx context.Context, userID uint, orderNumber string) error
-	GetUserOrders(ctx context.Context, userID uint) ([]*model.Order, error)
+	GetUserByID(ctx context.Context, userID uint) (model.User, error)
+	ChangeUserPassword(ctx context.Context, userID uint, hashPassword string) (model.User, error)
+	UpdateUserPasswordHash(ctx context.Context, userID uint, hashPassword string) error
+	SetUserTOTPSecret(ctx context.Context, userID uint, secret string) error
+	EnableUserTOTP(ctx context.Context, userID uint, step int64, codes []*model.RecoveryCode) error
+	DisableUserTOTP(ctx context.Context, userID uint) error
+	UseTOTPStep(ctx context.Context, userID uint, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error)
+	AnonymizeUser(ctx context.Context, userID uint, login string) error
+	SearchUsers(ctx context.Context, login string, limit int) ([]*model.User, error)
+	SetUserRole(ctx context.Context, userID uint, role model.UserRole) error
+	AdjustUserBalance(ctx context.Context, userID uint, adjustment *model.BalanceAdjustment, event *model.AuditEvent) (model.Balance, error)
+	UploadOrder(ctx context.Context, userID uint, orderNumber string, merchantID uint) error
+	UploadOrders(ctx context.Context, userID uint, orderNumbers []string) (map[string]model.OrderUploadResult, error)
+	GetUserOrders(ctx context.Context, userID uint, query model.ListQuery) ([]*model.Order, error)
+	GetUserOrder(ctx context.Context, userID uint, number string) (model.Order, error)
+	GetOrderStatusHistory(ctx context.Context, orderID uint) ([]*model.OrderStatusChange, error)
 	GetUserBalance(ctx context.Context, userID uint) (model.Balance, error)
-	WithdrawFromUserBalance(ctx context.Context, userID uint, order string, sum float32) error
-	GetWithdrawalsFromBalance(ctx context.Context, balanceID uint) ([]*model.WithdrawBalance, error)
+	WithdrawFromUserBalance(ctx context.Context, userID uint, order string, sum float32, merchantID uint) error
+	GetWithdrawalsFromBalance(ctx context.Context, balanceID uint, query model.ListQuery) ([]*model.WithdrawBalance, error)
+	GetBalanceAdjustments(ctx context.Context, balanceID uint) ([]*model.BalanceAdjustment, error)
 	GetOrdersNotPrecessed(ctx context.Context) ([]*model.Order, error)
-	AddAccrual(ctx context.Context, order *model.Order) error
+	AddAccrual(ctx context.Context, order *model.Order) (model.Balance, error)
+	AddAuditEvent(ctx context.Context, event *model.AuditEvent) error
+	CreateMerchant(ctx context.Context, merchant *model.Merchant) error
+	GetMerchant(ctx context.Context, merchantID uint) (model.Merchant, error)
+	CreateMerchantKey(ctx context.Context, key *model.MerchantKey) error
+	GetMerchantKey(ctx context.Context, prefix string) (model.MerchantKey, error)
+	RevokeMerchantKey(ctx context.Context, merchantID, keyID uint) error
+	LinkMerchantUser(ctx context.Context, merchantID, userID uint) error
+	UnlinkMerchantUser(ctx context.Context, merchantID, userID uint) error
+	IsMerchantUser(ctx context.Context, merchantID, userID uint) (bool, error)
+	StreamOrders(ctx context.Context, userID uint, query model.ListQuery, fn func(*model.Order) error) error
+	StreamWithdrawals(
+		ctx context.Context, userID uint, query model.ListQuery, fn func(*model.WithdrawBalance) error,
+	) error
+	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
 }
 
 var (
 	delayUpdAccrual         = time.Second * 10
 	delayErrorRequest int64 = 2
+
+	// errAccrualThrottled система расчета начислений просит повторить запрос через Retry-After.
+	errAccrualThrottled = errors.New("accrual service asks to retry later")
 )
 
 type Config struct {
-	AccrualAddress  string `env:"ACCRUAL_SYSTEM_ADDRESS" envDefault:"localhost:8081"`
-	GorutineEnabled bool   `env:"GOROUTINE_ENABLED" envDefault:"true"`
+	AccrualAddress  string   `env:"ACCRUAL_SYSTEM_ADDRESS" envDefault:"localhost:8081"`
+	AdminLogins     []string `env:"ADMIN_LOGINS"`
+	LoginGuard      LoginGuardConfig
+	Password        PasswordConfig
+	TOTP            TOTPConfig
+	Events          EventsConfig
+	GorutineEnabled bool `env:"GOROUTINE_ENABLED" envDefault:"true"`
 }
 
 type Gophermart struct {
-	log    *zap.Logger
-	cfg    *Config
-	wg     *sync.WaitGroup
-	store  Store
-	secret string
+	log     *zap.Logger
+	cfg     *Config
+	wg      *sync.WaitGroup
+	store   Store
+	guard   *loginGuard
+	hasher  *PasswordHasher
+	events  *eventBus
+	accrual *circuitBreaker
+	secret  string
 }
 
 type option func(*Gophermart)
@@ -60,15 +106,22 @@ func Logger(log *zap.Logger) option {
 
 func New(ctx context.Context, cfg *Config, store Store, options ...option) *Gophermart {
 	g := &Gophermart{
-		store: store,
-		cfg:   cfg,
-		wg:    &sync.WaitGroup{},
+		log:     zap.NewNop(),
+		store:   store,
+		cfg:     cfg,
+		wg:      &sync.WaitGroup{},
+		guard:   newLoginGuard(cfg.LoginGuard),
+		hasher:  NewPasswordHasher(cfg.Password),
+		events:  newEventBus(cfg.Events),
+		accrual: newCircuitBreaker(),
 	}
 
 	for _, opt := range options {
 		opt(g)
 	}
 
+	context.AfterFunc(ctx, g.events.close)
+
 	if g.cfg.GorutineEnabled {
 		g.wg.Add(1)
 		outputCh := g.generatorUpdAccrual(ctx)
@@ -88,7 +141,7 @@ func (g *Gophermart) Register(ctx context.Context, login, password string) error
 		return fmt.Errorf("login invalidate: %w", err)
 	}
 
-	hashPass, err := HashPassword(password)
+	hashPass, err := g.hasher.Hash(password)
 	if err != nil {
 		return fmt.Errorf("failed hash password: %w", err)
 	}
@@ -101,7 +154,7 @@ func (g *Gophermart) Register(ctx context.Context, login, password string) error
 	return nil
 }
 
-func (g *Gophermart) Authorization(ctx context.Context, login, password string) (model.User, error) {
+func (g *Gophermart) Authorization(ctx context.Context, login, password, ip string) (model.User, error) {
 	var user model.User
 	var err error
 	if err := validatePassword(password); err != nil {
@@ -112,24 +165,153 @@ func (g *Gophermart) Authorization(ctx context.Context, login, password string)
 		return user, fmt.Errorf("login invalidate: %w", err)
 	}
 
+	byLogin := guardKey{kind: guardByLogin, value: login}
+	byIP := guardKey{kind: guardByIP, value: ip}
+	if err := g.guard.reserve(byLogin, byIP); err != nil {
+		return user, fmt.Errorf("login `%s` from `%s` rejected: %w", login, ip, err)
+	}
+
 	user, err = g.store.GetUserByLogin(ctx, login)
 	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			g.loginFailed(ctx, user, login, ip, byLogin, byIP)
+		} else {
+			g.guard.release(byLogin, byIP)
+		}
 		return user, fmt.Errorf("failed getting user `%s`: %w", login, err)
 	}
 
-	if ok := checkPasswordHash(password, user.PasswordHash); !ok {
+	ok, needsRehash := g.hasher.Check(password, user.PasswordHash)
+	if !ok {
+		g.loginFailed(ctx, user, login, ip, byLogin, byIP)
+		return user, ErrPasswordNotEquale
+	}
+
+	g.guard.success(byLogin)
+	g.guard.release(byIP)
+
+	if needsRehash {
+		g.rehashPassword(ctx, user, password)
+	}
+
+	if user.TOTPEnabled {
+		return user, ErrSecondFactorRequired
+	}
+
+	return user, nil
+}
+
+func (g *Gophermart) GetUser(ctx context.Context, userID uint) (model.User, error) {
+	user, err := g.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return user, fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+
+	return user, nil
+}
+
+// ChangePassword меняет пароль пользователя. Версия токенов пользователя при этом увеличивается,
+// поэтому все выданные ранее токены становятся недействительными.
+func (g *Gophermart) ChangePassword(ctx context.Context, userID uint, oldPassword, newPassword string) (model.User, error) {
+	var user model.User
+	if err := validatePassword(newPassword); err != nil {
+		return user, fmt.Errorf("password invalidate: %w", err)
+	}
+
+	user, err := g.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return user, fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+
+	byLogin := guardKey{kind: guardByLogin, value: user.Login}
+	if err := g.guard.reserve(byLogin); err != nil {
+		return user, fmt.Errorf("password change of `%s` rejected: %w", user.Login, err)
+	}
+	if ok, _ := g.hasher.Check(oldPassword, user.PasswordHash); !ok {
+		g.loginFailed(ctx, user, user.Login, "", byLogin)
 		return user, ErrPasswordNotEquale
 	}
+	g.guard.success(byLogin)
+
+	if oldPassword == newPassword {
+		return user, ErrPasswordNotChanged
+	}
+
+	hashPass, err := g.hasher.Hash(newPassword)
+	if err != nil {
+		return user, fmt.Errorf("failed hash password: %w", err)
+	}
+
+	user, err = g.store.ChangeUserPassword(ctx, userID, hashPass)
+	if err != nil {
+		return user, fmt.Errorf("failed change password: %w", err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action: model.AuditPasswordChanged,
+		UserID: user.ID,
+		Login:  user.Login,
+	})
 
 	return user, nil
 }
 
+// rehashPassword пересчитывает хеш пароля, созданный с устаревшими параметрами.
+// Версия токенов при этом не меняется, ошибка не мешает входу.
+func (g *Gophermart) rehashPassword(ctx context.Context, user model.User, password string) {
+	hashPass, err := g.hasher.Hash(password)
+	if err != nil {
+		g.logger(ctx).Error("failed rehash password", zap.Uint("userID", user.ID), zap.Error(err))
+		return
+	}
+	if err := g.store.UpdateUserPasswordHash(ctx, user.ID, hashPass); err != nil {
+		g.logger(ctx).Error("failed save rehashed password", zap.Uint("userID", user.ID), zap.Error(err))
+		return
+	}
+	g.logger(ctx).Debug("password rehashed", zap.Uint("userID", user.ID))
+}
+
+func (g *Gophermart) loginFailed(ctx context.Context, user model.User, login, ip string, keys ...guardKey) {
+	for _, key := range g.guard.fail(keys...) {
+		details := "login locked after repeated failures"
+		switch key.kind {
+		case guardByIP:
+			details = "ip locked after repeated failures"
+		case guardBySecondFactor:
+			details = "second factor locked after repeated failures"
+		}
+		g.logger(ctx).Warn("login attempts lockout",
+			zap.String("login", login),
+			zap.String("ip", ip),
+			zap.String("details", details),
+		)
+		g.audit(ctx, &model.AuditEvent{
+			Action:  model.AuditLoginLocked,
+			UserID:  user.ID,
+			Login:   login,
+			IP:      ip,
+			Details: details,
+		})
+	}
+}
+
+func (g *Gophermart) audit(ctx context.Context, event *model.AuditEvent) {
+	if err := g.store.AddAuditEvent(ctx, event); err != nil {
+		g.logger(ctx).Error("failed add audit event", zap.String("action", string(event.Action)), zap.Error(err))
+	}
+}
+
 func (g *Gophermart) UploadOrder(ctx context.Context, userID uint, orderNumber string) error {
+	return g.uploadOrder(ctx, userID, orderNumber, 0)
+}
+
+// uploadOrder загружает заказ пользователя, merchantID равен 0, если пользователь загружает заказ сам.
+func (g *Gophermart) uploadOrder(ctx context.Context, userID uint, orderNumber string, merchantID uint) error {
 	if ok := checkLuhn(orderNumber); !ok {
 		return ErrOrderNumberNotValid
 	}
 
-	err := g.store.UploadOrder(ctx, userID, orderNumber)
+	err := g.store.UploadOrder(ctx, userID, orderNumber, merchantID)
 	if err != nil {
 		return fmt.Errorf("failed upload order: %w", err)
 	}
@@ -137,12 +319,87 @@ func (g *Gophermart) UploadOrder(ctx context.Context, userID uint, orderNumber s
 	return nil
 }
 
-func (g *Gophermart) GetUserOrders(ctx context.Context, userID uint) ([]*model.Order, error) {
-	orders, err := g.store.GetUserOrders(ctx, userID)
+// UploadOrders загружает пакет заказов пользователя. Неверные номера не мешают загрузке остальных,
+// результаты возвращаются в порядке номеров в пакете. Повтор номера внутри пакета считается
+// уже загруженным пользователем.
+func (g *Gophermart) UploadOrders(ctx context.Context, userID uint, orderNumbers []string) ([]model.OrderUpload, error) {
+	valid := make([]string, 0, len(orderNumbers))
+	seen := make(map[string]bool, len(orderNumbers))
+	for _, number := range orderNumbers {
+		if checkLuhn(number) && !seen[number] {
+			seen[number] = true
+			valid = append(valid, number)
+		}
+	}
+
+	stored := map[string]model.OrderUploadResult{}
+	if len(valid) > 0 {
+		var err error
+		stored, err = g.store.UploadOrders(ctx, userID, valid)
+		if err != nil {
+			return nil, fmt.Errorf("failed upload orders: %w", err)
+		}
+	}
+
+	results := make([]model.OrderUpload, 0, len(orderNumbers))
+	for _, number := range orderNumbers {
+		result, ok := stored[number]
+		switch {
+		case !ok:
+			result = model.OrderUploadInvalid
+		case !seen[number] && result == model.OrderUploadAccepted:
+			// повтор номера, принятого выше в этом же пакете
+			result = model.OrderUploadAlreadyUploaded
+		}
+		seen[number] = false
+		results = append(results, model.OrderUpload{Number: number, Result: result})
+	}
+
+	return results, nil
+}
+
+// GetUserOrders возвращает страницу заказов пользователя и курсор следующей страницы,
+// курсор равен nil на последней странице.
+func (g *Gophermart) GetUserOrders(
+	ctx context.Context, userID uint, query model.ListQuery,
+) ([]*model.Order, *model.ListCursor, error) {
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit++
+	}
+	orders, err := g.store.GetUserOrders(ctx, userID, query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting order by user: %w", err)
+	}
+	orders, next := nextPage(orders, limit, func(o *model.Order) model.ListCursor {
+		return model.ListCursor{CreatedAt: o.CreatedAt, ID: o.ID}
+	})
+	return orders, next, nil
+}
+
+// GetUserOrder возвращает заказ пользователя и историю его статусов от загрузки до начисления.
+// Для заказов, загруженных до появления истории, загрузка восстанавливается по времени создания заказа.
+func (g *Gophermart) GetUserOrder(
+	ctx context.Context, userID uint, number string,
+) (model.Order, []*model.OrderStatusChange, error) {
+	order, err := g.store.GetUserOrder(ctx, userID, number)
+	if err != nil {
+		return order, nil, fmt.Errorf("failed get order: %w", err)
+	}
+	history, err := g.store.GetOrderStatusHistory(ctx, order.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed getting order by user: %w", err)
+		return order, nil, fmt.Errorf("failed get order status history: %w", err)
+	}
+	if len(history) == 0 || history[0].Status != model.OrderStateNew {
+		uploaded := &model.OrderStatusChange{
+			CreatedAt: order.CreatedAt,
+			Status:    model.OrderStateNew,
+			OrderID:   order.ID,
+		}
+		history = append([]*model.OrderStatusChange{uploaded}, history...)
 	}
-	return orders, nil
+
+	return order, history, nil
 }
 
 func (g *Gophermart) GetUserBalance(ctx context.Context, userID uint) (model.Balance, error) {
@@ -155,29 +412,59 @@ func (g *Gophermart) GetUserBalance(ctx context.Context, userID uint) (model.Bal
 }
 
 func (g *Gophermart) WithdrawFromBalanceUser(ctx context.Context, userID uint, order string, sum float32) error {
+	return g.withdraw(ctx, userID, order, sum, 0)
+}
+
+func (g *Gophermart) withdraw(ctx context.Context, userID uint, order string, sum float32, merchantID uint) error {
 	if ok := checkLuhn(order); !ok {
 		return ErrOrderNumberNotValid
 	}
+	if sum <= 0 {
+		return ErrSumNotValid
+	}
 
-	err := g.store.WithdrawFromUserBalance(ctx, userID, order, sum)
+	err := g.store.WithdrawFromUserBalance(ctx, userID, order, sum, merchantID)
 	if err != nil {
 		return fmt.Errorf("failed with draw from user balance: %w", err)
 	}
+	g.publishBalance(ctx, userID)
 
 	return nil
 }
 
-func (g *Gophermart) GetWithdrawalsByUser(ctx context.Context, userID uint) ([]*model.WithdrawBalance, error) {
+// GetWithdrawalsByUser возвращает страницу списаний пользователя и курсор следующей страницы.
+func (g *Gophermart) GetWithdrawalsByUser(
+	ctx context.Context, userID uint, query model.ListQuery,
+) ([]*model.WithdrawBalance, *model.ListCursor, error) {
 	balance, err := g.store.GetUserBalance(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed get user balance: %w", err)
+		return nil, nil, fmt.Errorf("failed get user balance: %w", err)
+	}
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit++
 	}
-	withdrawals, err := g.store.GetWithdrawalsFromBalance(ctx, balance.ID)
+	withdrawals, err := g.store.GetWithdrawalsFromBalance(ctx, balance.ID, query)
 	if err != nil {
-		return withdrawals, fmt.Errorf("failed get withdrawals: %w", err)
+		return withdrawals, nil, fmt.Errorf("failed get withdrawals: %w", err)
 	}
+	withdrawals, next := nextPage(withdrawals, limit, func(w *model.WithdrawBalance) model.ListCursor {
+		return model.ListCursor{CreatedAt: w.CreatedAt, ID: w.ID}
+	})
 
-	return withdrawals, nil
+	return withdrawals, next, nil
+}
+
+// nextPage обрезает выборку, запрошенную с лимитом на единицу больше, до limit записей
+// и возвращает курсор последней записи, если за ней есть еще записи.
+func nextPage[T any](items []T, limit int, cursor func(T) model.ListCursor) ([]T, *model.ListCursor) {
+	if limit <= 0 || len(items) <= limit {
+		return items, nil
+	}
+	items = items[:limit]
+	next := cursor(items[limit-1])
+
+	return items, &next
 }
 
 func checkLuhn(ccn string) bool {
@@ -186,8 +473,14 @@ func checkLuhn(ccn string) bool {
 	maxDigit := 9
 	parity := len(ccn) % half
 
+	if ccn == "" {
+		return false
+	}
 	for i := range len(ccn) {
-		digit, _ := strconv.Atoi(string(ccn[i]))
+		digit, err := strconv.Atoi(string(ccn[i]))
+		if err != nil {
+			return false
+		}
 		if i%2 == parity {
 			digit *= 2
 			if digit > maxDigit {
@@ -232,15 +525,17 @@ func (g *Gophermart) workerUpdOrders(ctx context.Context, inputCh <-chan *model.
 	g.log.Debug("start gorutin workerUpdOrders")
 	defer g.log.Debug("stopped gorutin workerUpdOrders")
 	defer g.wg.Done()
-	cb := newCircuitBreaker()
 	for {
 		select {
 		case <-ctx.Done():
 			g.log.Info("worker updating order stopping")
 			return
 		case o := <-inputCh:
-			if err := cb.execute(g.requestToAccrual(ctx, o)); err != nil {
-				g.log.Error("circuit braker failed execute", zap.Error(err))
+			// у каждого запроса в систему расчета начислений свой идентификатор для поиска в логах
+			reqCtx := requestid.NewContext(ctx, requestid.New())
+			err := g.accrual.execute(g.requestToAccrual(reqCtx, o))
+			if err != nil && !errors.Is(err, errAccrualThrottled) {
+				g.logger(reqCtx).Error("circuit braker failed execute", zap.String("order", o.Number), zap.Error(err))
 			}
 		}
 	}
@@ -248,7 +543,14 @@ func (g *Gophermart) workerUpdOrders(ctx context.Context, inputCh <-chan *model.
 
 func (g *Gophermart) requestToAccrual(ctx context.Context, order *model.Order) func() (int64, error) {
 	return func() (int64, error) {
-		resp, err := http.Get(g.cfg.AccrualAddress + "/api/orders/" + order.Number)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.cfg.AccrualAddress+"/api/orders/"+order.Number, nil)
+		if err != nil {
+			return delayErrorRequest, fmt.Errorf("failed create accrual request: %w", err)
+		}
+		if id := requestid.FromContext(ctx); id != "" {
+			req.Header.Set(requestid.Header, id)
+		}
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			return delayErrorRequest, fmt.Errorf("request failed from accrual service: %w", err)
 		}
@@ -263,21 +565,23 @@ func (g *Gophermart) requestToAccrual(ctx context.Context, order *model.Order) f
 			if err != nil {
 				return delayErrorRequest, fmt.Errorf("failed unmarshal accrual response body: %w", err)
 			}
+			previous := *order
 			order.Accrual = jBody.Accrual
 			order.Status = model.OrderStatus(jBody.Status)
-			err = g.store.AddAccrual(ctx, order)
+			balance, err := g.store.AddAccrual(ctx, order)
 			if err != nil {
 				return delayErrorRequest, fmt.Errorf("failed add accrual: %w", err)
 			}
+			g.publishAccrual(*order, previous, balance)
 			return delayErrorRequest, nil
 		}
 		if resp.StatusCode == http.StatusNoContent {
-			g.log.Debug("no content by order", zap.String("number", order.Number))
+			g.logger(ctx).Debug("no content by order", zap.String("number", order.Number))
 			return 0, nil
 		}
 		if resp.StatusCode == http.StatusTooManyRequests {
 			sRetryAfter := resp.Header.Get("Retry-After")
-			g.log.Debug("too many requests",
+			g.logger(ctx).Debug("too many requests",
 				zap.String("status", resp.Status),
 				zap.String("Retry-After", sRetryAfter),
 			)
@@ -288,9 +592,9 @@ func (g *Gophermart) requestToAccrual(ctx context.Context, order *model.Order) f
 			if iRetryAfter <= 0 {
 				return delayErrorRequest, fmt.Errorf("`RetryAfter` not valid as seconds: %d", iRetryAfter)
 			}
-			return int64(iRetryAfter), nil
+			return int64(iRetryAfter), errAccrualThrottled
 		}
-		g.log.Info("not correct response",
+		g.logger(ctx).Info("not correct response",
 			zap.String("status", resp.Status),
 			zap.String("order", order.Number),
 			zap.String("body", string(bBody)),
@@ -299,6 +603,11 @@ func (g *Gophermart) requestToAccrual(ctx context.Context, order *model.Order) f
 	}
 }
 
+// logger возвращает лог с идентификатором запроса из контекста.
+func (g *Gophermart) logger(ctx context.Context) *zap.Logger {
+	return requestid.Logger(ctx, g.log)
+}
+
 func (g *Gophermart) Wait() {
 	g.wg.Wait()
 }
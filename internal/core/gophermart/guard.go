@@ -0,0 +1,228 @@
+package gophermart
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type LoginGuardConfig struct {
+	Window          time.Duration `env:"LOGIN_ATTEMPTS_WINDOW" envDefault:"15m"`
+	DelayBase       time.Duration `env:"LOGIN_DELAY_BASE" envDefault:"1s"`
+	DelayMax        time.Duration `env:"LOGIN_DELAY_MAX" envDefault:"30s"`
+	LockoutDuration time.Duration `env:"LOGIN_LOCKOUT_DURATION" envDefault:"15m"`
+	DelayAfter      int           `env:"LOGIN_DELAY_AFTER" envDefault:"3"`
+	LockoutAfter    int           `env:"LOGIN_LOCKOUT_AFTER" envDefault:"10"`
+	IPDelayAfter    int           `env:"LOGIN_IP_DELAY_AFTER" envDefault:"20"`
+	IPLockoutAfter  int           `env:"LOGIN_IP_LOCKOUT_AFTER" envDefault:"100"`
+}
+
+var guardPruneInterval = time.Minute
+
+type guardKind int
+
+const (
+	guardByLogin guardKind = iota
+	guardByIP
+	guardBySecondFactor
+)
+
+type guardKey struct {
+	value string
+	kind  guardKind
+}
+
+type loginAttempts struct {
+	lastFailure time.Time
+	lockedUntil time.Time
+	failures    int
+	reported    bool
+}
+
+// loginGuard считает неудачные попытки входа по логину и по IP адресу.
+// После DelayAfter ошибок следующая попытка разрешается только через нарастающую задержку,
+// после LockoutAfter ошибок ключ блокируется на LockoutDuration.
+// Попытка учитывается как неудачная еще до проверки пароля, в той же блокировке, что и проверка
+// ограничений, поэтому параллельные запросы не проходят мимо счетчика. Удачная попытка
+// возвращается через success или release.
+type loginGuard struct {
+	lastPrune time.Time
+	mu        *sync.Mutex
+	attempts  map[guardKey]*loginAttempts
+	now       func() time.Time
+	cfg       LoginGuardConfig
+}
+
+func newLoginGuard(cfg LoginGuardConfig) *loginGuard {
+	return &loginGuard{
+		mu:       &sync.Mutex{},
+		attempts: make(map[guardKey]*loginAttempts),
+		now:      time.Now,
+		cfg:      cfg,
+	}
+}
+
+func (lg *loginGuard) thresholds(kind guardKind) (delayAfter, lockoutAfter int) {
+	if kind == guardByIP {
+		return lg.cfg.IPDelayAfter, lg.cfg.IPLockoutAfter
+	}
+	return lg.cfg.DelayAfter, lg.cfg.LockoutAfter
+}
+
+// entry возвращает актуальный счетчик по ключу, сбрасывая устаревшие.
+func (lg *loginGuard) entry(key guardKey, now time.Time) (*loginAttempts, bool) {
+	a, ok := lg.attempts[key]
+	if !ok {
+		return nil, false
+	}
+	if !a.lockedUntil.IsZero() {
+		if now.Before(a.lockedUntil) {
+			return a, true
+		}
+		delete(lg.attempts, key)
+		return nil, false
+	}
+	if lg.cfg.Window > 0 && now.Sub(a.lastFailure) > lg.cfg.Window {
+		delete(lg.attempts, key)
+		return nil, false
+	}
+	return a, true
+}
+
+func (lg *loginGuard) delay(failures, delayAfter int) time.Duration {
+	if delayAfter <= 0 || failures < delayAfter || lg.cfg.DelayBase <= 0 {
+		return 0
+	}
+	d := lg.cfg.DelayBase
+	for range failures - delayAfter {
+		d *= 2
+		if lg.cfg.DelayMax > 0 && d >= lg.cfg.DelayMax {
+			return lg.cfg.DelayMax
+		}
+	}
+	return d
+}
+
+// reserve проверяет ключи и учитывает попытку как неудачную. Возвращает *LoginAttemptsError,
+// если хотя бы один из ключей заблокирован или ещё не выждал задержку, попытка тогда не учитывается.
+// Каждая разрешенная попытка завершается вызовом fail, success или release.
+func (lg *loginGuard) reserve(keys ...guardKey) error {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	now := lg.now()
+	if now.Sub(lg.lastPrune) > guardPruneInterval {
+		lg.prune(now)
+		lg.lastPrune = now
+	}
+	var result *LoginAttemptsError
+	for _, key := range keys {
+		a, ok := lg.entry(key, now)
+		if !ok {
+			continue
+		}
+		var current *LoginAttemptsError
+		if now.Before(a.lockedUntil) {
+			current = &LoginAttemptsError{Err: ErrLoginLocked, RetryAfter: a.lockedUntil.Sub(now)}
+		} else {
+			delayAfter, _ := lg.thresholds(key.kind)
+			allowed := a.lastFailure.Add(lg.delay(a.failures, delayAfter))
+			if now.Before(allowed) {
+				current = &LoginAttemptsError{Err: ErrLoginThrottled, RetryAfter: allowed.Sub(now)}
+			}
+		}
+		if worse(current, result) {
+			result = current
+		}
+	}
+	if result != nil {
+		return result
+	}
+
+	for _, key := range keys {
+		a, ok := lg.entry(key, now)
+		if !ok {
+			a = &loginAttempts{}
+			lg.attempts[key] = a
+		}
+		a.failures++
+		a.lastFailure = now
+		_, lockoutAfter := lg.thresholds(key.kind)
+		if lockoutAfter > 0 && a.failures >= lockoutAfter && lg.cfg.LockoutDuration > 0 {
+			a.lockedUntil = now.Add(lg.cfg.LockoutDuration)
+		}
+	}
+	return nil
+}
+
+// fail подтверждает неудачную попытку, учтенную в reserve, и возвращает ключи,
+// о блокировке которых еще не сообщалось.
+func (lg *loginGuard) fail(keys ...guardKey) []guardKey {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	now := lg.now()
+	locked := []guardKey{}
+	for _, key := range keys {
+		a, ok := lg.entry(key, now)
+		if !ok || a.reported || a.lockedUntil.IsZero() {
+			continue
+		}
+		a.reported = true
+		locked = append(locked, key)
+	}
+	return locked
+}
+
+// success сбрасывает счетчик ключа. Счетчик по IP при успешном входе не сбрасывается,
+// иначе перебор можно было бы обнулять входом в собственный аккаунт, для него вызывается release.
+func (lg *loginGuard) success(key guardKey) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	delete(lg.attempts, key)
+}
+
+// release возвращает попытку, учтенную в reserve, если она не была неудачной: пароль верен
+// или проверка не состоялась из-за внутренней ошибки.
+func (lg *loginGuard) release(keys ...guardKey) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	now := lg.now()
+	for _, key := range keys {
+		a, ok := lg.entry(key, now)
+		if !ok {
+			continue
+		}
+		a.failures--
+		if a.failures <= 0 {
+			delete(lg.attempts, key)
+			continue
+		}
+		_, lockoutAfter := lg.thresholds(key.kind)
+		if a.failures < lockoutAfter && !a.reported {
+			a.lockedUntil = time.Time{}
+		}
+	}
+}
+
+func (lg *loginGuard) prune(now time.Time) {
+	for key := range lg.attempts {
+		lg.entry(key, now)
+	}
+}
+
+// worse сообщает, что ошибка a строже b: блокировка важнее задержки, затем больший RetryAfter.
+func worse(a, b *LoginAttemptsError) bool {
+	if a == nil {
+		return false
+	}
+	if b == nil {
+		return true
+	}
+	aLocked, bLocked := errors.Is(a, ErrLoginLocked), errors.Is(b, ErrLoginLocked)
+	if aLocked != bLocked {
+		return aLocked
+	}
+	return a.RetryAfter > b.RetryAfter
+}
@@ -0,0 +1,28 @@
+package gophermart
+
+import (
+	"context"
+	"fmt"
+)
+
+// PingStore проверяет соединение с хранилищем.
+func (g *Gophermart) PingStore(ctx context.Context) error {
+	if err := g.store.Ping(ctx); err != nil {
+		return fmt.Errorf("failed ping store: %w", err)
+	}
+	return nil
+}
+
+// CheckMigrations проверяет, что схема хранилища соответствует текущей версии сервиса.
+func (g *Gophermart) CheckMigrations(ctx context.Context) error {
+	if err := g.store.CheckMigrations(ctx); err != nil {
+		return fmt.Errorf("failed check migrations: %w", err)
+	}
+	return nil
+}
+
+// AccrualState возвращает состояние circuit breaker запросов в систему расчета начислений:
+// closed, open или half_open. Пока breaker открыт, опрос начислений приостановлен.
+func (g *Gophermart) AccrualState() string {
+	return g.accrual.status()
+}
@@ -0,0 +1,207 @@
+package gophermart
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/errstore"
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+)
+
+var (
+	merchantKeyTag       = "gm"
+	merchantKeyPrefixLen = 8
+	merchantKeySecretLen = 32
+)
+
+// CreateMerchant создает магазин. API ключи выпускаются отдельно через CreateMerchantKey.
+func (g *Gophermart) CreateMerchant(ctx context.Context, actorID uint, name string) (model.Merchant, error) {
+	merchant := model.Merchant{Name: strings.TrimSpace(name)}
+	if merchant.Name == "" {
+		return merchant, ErrMerchantNotValid
+	}
+
+	if err := g.store.CreateMerchant(ctx, &merchant); err != nil {
+		return merchant, fmt.Errorf("failed create merchant `%s`: %w", merchant.Name, err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action:  model.AuditMerchantCreated,
+		Details: fmt.Sprintf("actor=%d merchant=%d name=%s", actorID, merchant.ID, merchant.Name),
+	})
+
+	return merchant, nil
+}
+
+// CreateMerchantKey выпускает API ключ магазина с разрешениями scopes.
+// Ключ возвращается только один раз, в базе сохраняется его хеш.
+func (g *Gophermart) CreateMerchantKey(
+	ctx context.Context,
+	actorID, merchantID uint,
+	scopes []model.MerchantScope,
+) (string, model.MerchantKey, error) {
+	key := model.MerchantKey{MerchantID: merchantID}
+	if len(scopes) == 0 {
+		return "", key, ErrScopeNotValid
+	}
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		switch scope {
+		case model.ScopeOrdersWrite, model.ScopeBalanceWithdraw:
+		default:
+			return "", key, fmt.Errorf("%w: `%s`", ErrScopeNotValid, scope)
+		}
+		if !slices.Contains(names, string(scope)) {
+			names = append(names, string(scope))
+		}
+	}
+	key.Scopes = strings.Join(names, ",")
+
+	if _, err := g.store.GetMerchant(ctx, merchantID); err != nil {
+		return "", key, fmt.Errorf("failed getting merchant id=`%d`: %w", merchantID, err)
+	}
+
+	prefix := make([]byte, merchantKeyPrefixLen)
+	secret := make([]byte, merchantKeySecretLen)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", key, fmt.Errorf("failed generate merchant key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", key, fmt.Errorf("failed generate merchant key: %w", err)
+	}
+	key.Prefix = hex.EncodeToString(prefix)
+	apiKey := merchantKeyTag + "_" + key.Prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	key.KeyHash = hashMerchantKey(apiKey)
+
+	if err := g.store.CreateMerchantKey(ctx, &key); err != nil {
+		return "", key, fmt.Errorf("failed save merchant key: %w", err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action:  model.AuditMerchantKey,
+		Details: fmt.Sprintf("actor=%d merchant=%d key=%d scopes=%s", actorID, merchantID, key.ID, key.Scopes),
+	})
+
+	return apiKey, key, nil
+}
+
+func (g *Gophermart) RevokeMerchantKey(ctx context.Context, actorID, merchantID, keyID uint) error {
+	if err := g.store.RevokeMerchantKey(ctx, merchantID, keyID); err != nil {
+		return fmt.Errorf("failed revoke key id=`%d` of merchant id=`%d`: %w", keyID, merchantID, err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action:  model.AuditMerchantRevoked,
+		Details: fmt.Sprintf("actor=%d merchant=%d key=%d", actorID, merchantID, keyID),
+	})
+
+	return nil
+}
+
+// LinkMerchantUser разрешает магазину действовать от имени пользователя.
+func (g *Gophermart) LinkMerchantUser(ctx context.Context, actorID, merchantID, userID uint) error {
+	if _, err := g.store.GetMerchant(ctx, merchantID); err != nil {
+		return fmt.Errorf("failed getting merchant id=`%d`: %w", merchantID, err)
+	}
+	if _, err := g.store.GetUserByID(ctx, userID); err != nil {
+		return fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+
+	if err := g.store.LinkMerchantUser(ctx, merchantID, userID); err != nil {
+		return fmt.Errorf("failed link merchant id=`%d` to user id=`%d`: %w", merchantID, userID, err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action:  model.AuditMerchantLinked,
+		UserID:  userID,
+		Details: fmt.Sprintf("actor=%d merchant=%d", actorID, merchantID),
+	})
+
+	return nil
+}
+
+func (g *Gophermart) UnlinkMerchantUser(ctx context.Context, actorID, merchantID, userID uint) error {
+	if err := g.store.UnlinkMerchantUser(ctx, merchantID, userID); err != nil {
+		return fmt.Errorf("failed unlink merchant id=`%d` from user id=`%d`: %w", merchantID, userID, err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action:  model.AuditMerchantUnlink,
+		UserID:  userID,
+		Details: fmt.Sprintf("actor=%d merchant=%d", actorID, merchantID),
+	})
+
+	return nil
+}
+
+// AuthenticateMerchant проверяет API ключ вида `gm_<prefix>_<secret>` и возвращает его вместе с магазином.
+func (g *Gophermart) AuthenticateMerchant(ctx context.Context, apiKey string) (model.MerchantKey, error) {
+	var key model.MerchantKey
+	partsCount := 3
+	parts := strings.SplitN(apiKey, "_", partsCount)
+	if len(parts) != partsCount || parts[0] != merchantKeyTag || parts[1] == "" {
+		return key, ErrMerchantKeyNotValid
+	}
+
+	key, err := g.store.GetMerchantKey(ctx, parts[1])
+	if err != nil {
+		if errors.Is(err, errstore.ErrNotFoundData) {
+			return key, ErrMerchantKeyNotValid
+		}
+		return key, fmt.Errorf("failed getting merchant key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashMerchantKey(apiKey))) != 1 || key.RevokedAt != nil {
+		return key, ErrMerchantKeyNotValid
+	}
+
+	return key, nil
+}
+
+// HasScope сообщает, выдано ли ключу разрешение scope.
+func HasScope(key model.MerchantKey, scope model.MerchantScope) bool {
+	return slices.Contains(strings.Split(key.Scopes, ","), string(scope))
+}
+
+// MerchantUploadOrder загружает заказ пользователя от имени магазина. Магазин сохраняется в заказе.
+func (g *Gophermart) MerchantUploadOrder(ctx context.Context, merchantID, userID uint, orderNumber string) error {
+	if err := g.checkMerchantUser(ctx, merchantID, userID); err != nil {
+		return err
+	}
+
+	return g.uploadOrder(ctx, userID, orderNumber, merchantID)
+}
+
+// MerchantWithdraw списывает баллы пользователя от имени магазина. Магазин сохраняется в списании.
+func (g *Gophermart) MerchantWithdraw(ctx context.Context, merchantID, userID uint, order string, sum float32) error {
+	if err := g.checkMerchantUser(ctx, merchantID, userID); err != nil {
+		return err
+	}
+
+	return g.withdraw(ctx, userID, order, sum, merchantID)
+}
+
+func (g *Gophermart) checkMerchantUser(ctx context.Context, merchantID, userID uint) error {
+	ok, err := g.store.IsMerchantUser(ctx, merchantID, userID)
+	if err != nil {
+		return fmt.Errorf("failed check link of merchant id=`%d` to user id=`%d`: %w", merchantID, userID, err)
+	}
+	if !ok {
+		return ErrMerchantNotLinked
+	}
+
+	return nil
+}
+
+func hashMerchantKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
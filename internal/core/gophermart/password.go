@@ -0,0 +1,160 @@
+package gophermart
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+var (
+	ErrHashAlgorithmNotSupported = errors.New("password hash algorithm not supported")
+	errHashFormat                = errors.New("password hash has unknown format")
+
+	argon2SaltLen uint32 = 16
+	argon2KeyLen  uint32 = 32
+
+	// Верхние границы параметров argon2id. Параметры проверки берутся из сохраненного хеша,
+	// поэтому без ограничений поврежденная запись может занять всю память и процессор.
+	argon2MaxTime    uint32 = 16
+	argon2MaxMemory  uint32 = 1 << 20
+	argon2MaxThreads uint8  = 16
+	argon2MaxLen            = 64
+)
+
+// PasswordConfig параметры хеширования паролей. Argon2Memory задается в KiB.
+type PasswordConfig struct {
+	Algorithm     string `env:"PASSWORD_HASH_ALGORITHM" envDefault:"bcrypt"`
+	BcryptCost    int    `env:"PASSWORD_BCRYPT_COST" envDefault:"12"`
+	Argon2Time    uint32 `env:"PASSWORD_ARGON2_TIME" envDefault:"2"`
+	Argon2Memory  uint32 `env:"PASSWORD_ARGON2_MEMORY" envDefault:"65536"`
+	Argon2Threads uint8  `env:"PASSWORD_ARGON2_THREADS" envDefault:"2"`
+}
+
+// PasswordHasher хеширует пароли настроенным алгоритмом. Хеши самоописываемые:
+// bcrypt хранит стоимость в хеше, argon2id хранится в формате PHC
+// `$argon2id$v=19$m=65536,t=2,p=2$<salt>$<hash>`, поэтому проверка не зависит от текущих настроек.
+type PasswordHasher struct {
+	cfg PasswordConfig
+}
+
+func (cfg PasswordConfig) Validate() error {
+	switch cfg.Algorithm {
+	case HashBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be in [%d, %d]", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case HashArgon2id:
+		if cfg.Argon2Time == 0 || cfg.Argon2Memory == 0 || cfg.Argon2Threads == 0 {
+			return errors.New("argon2id parameters must be positive")
+		}
+		if cfg.Argon2Time > argon2MaxTime || cfg.Argon2Memory > argon2MaxMemory || cfg.Argon2Threads > argon2MaxThreads {
+			return fmt.Errorf("argon2id parameters must not exceed t=%d, m=%d, p=%d",
+				argon2MaxTime, argon2MaxMemory, argon2MaxThreads)
+		}
+	default:
+		return fmt.Errorf("%w: `%s`", ErrHashAlgorithmNotSupported, cfg.Algorithm)
+	}
+
+	return nil
+}
+
+func NewPasswordHasher(cfg PasswordConfig) *PasswordHasher {
+	return &PasswordHasher{cfg: cfg}
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if err := h.cfg.Validate(); err != nil {
+		return "", err
+	}
+
+	if h.cfg.Algorithm == HashArgon2id {
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("failed generate salt: %w", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, h.cfg.Argon2Time, h.cfg.Argon2Memory, h.cfg.Argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			HashArgon2id, argon2.Version,
+			h.cfg.Argon2Memory, h.cfg.Argon2Time, h.cfg.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	}
+
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed generate bcrypt hash: %w", err)
+	}
+	return string(bytes), nil
+}
+
+// Check сверяет пароль с хешем. needsRehash сообщает, что хеш создан другим алгоритмом
+// или с другими параметрами и его стоит пересчитать с текущими настройками.
+func (h *PasswordHasher) Check(password, hash string) (ok, needsRehash bool) {
+	if strings.HasPrefix(hash, "$"+HashArgon2id+"$") {
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false, false
+		}
+		//nolint:gosec // длина ключа из хеша ограничена argon2MaxLen
+		other := argon2.IDKey([]byte(password), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false
+		}
+		return true, h.cfg.Algorithm != HashArgon2id ||
+			params.Argon2Time != h.cfg.Argon2Time ||
+			params.Argon2Memory != h.cfg.Argon2Memory ||
+			params.Argon2Threads != h.cfg.Argon2Threads ||
+			uint32(len(key)) != argon2KeyLen //nolint:gosec // см. выше
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true, true
+	}
+	return true, h.cfg.Algorithm != HashBcrypt || cost != h.cfg.BcryptCost
+}
+
+func parseArgon2id(hash string) (params PasswordConfig, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=65536,t=2,p=2", "<salt>", "<hash>"
+	parts := strings.Split(hash, "$")
+	partsCount := 6
+	if len(parts) != partsCount {
+		return params, nil, nil, errHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errHashFormat
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Argon2Memory, &params.Argon2Time, &params.Argon2Threads)
+	if err != nil {
+		return params, nil, nil, errHashFormat
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil || len(salt) > argon2MaxLen {
+		return params, nil, nil, errHashFormat
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 || len(key) > argon2MaxLen {
+		return params, nil, nil, errHashFormat
+	}
+	params.Algorithm = HashArgon2id
+	if err := params.Validate(); err != nil {
+		return params, nil, nil, errHashFormat
+	}
+
+	return params, salt, key, nil
+}
@@ -6,8 +6,6 @@ import (
 	"time"
 
 	"errors"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 func validateLogin(login string) error {
@@ -24,17 +22,6 @@ func validatePassword(password string) error {
 	return nil
 }
 
-func HashPassword(password string) (string, error) {
-	cost := 14
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
-	return string(bytes), err
-}
-
-func checkPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
-
 type circuitBreakerState int
 
 const (
@@ -79,24 +66,39 @@ func (cb *circuitBreaker) execute(request func() (int64, error)) error {
 	delay, err := request()
 
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
 	timeDelay := time.Duration(delay) * time.Second
 	if timeDelay > 0 {
 		cb.expireDelay = time.Now().Add(timeDelay).Unix()
 	}
+	// пауза после успешного запроса только сдерживает темп опроса, breaker открывается
+	// при ошибке, в том числе когда система расчета начислений просит подождать
 	if err != nil {
 		cb.state = cbOpen
-		time.Sleep(timeDelay)
-		return fmt.Errorf("request error: %w", err)
+	} else {
+		cb.state = cbClose
 	}
+	cb.mu.Unlock()
 
-	if timeDelay > 0 {
-		cb.state = cbOpen
-		time.Sleep(timeDelay)
-		return nil
+	// ожидание без блокировки, чтобы состояние можно было прочитать для проверки готовности
+	time.Sleep(timeDelay)
+	if err != nil {
+		return fmt.Errorf("request error: %w", err)
 	}
 
-	cb.state = cbClose
 	return nil
 }
+
+// status возвращает состояние: closed, open или half_open.
+func (cb *circuitBreaker) status() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case cbOpen:
+		return "open"
+	case cbHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
@@ -0,0 +1,203 @@
+package gophermart
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/playmixer/gophermart/internal/adapters/store/model"
+	"github.com/playmixer/gophermart/pkg/totp"
+	"go.uber.org/zap"
+)
+
+var (
+	recoveryCodeSize = 5
+	recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+type TOTPConfig struct {
+	Issuer        string `env:"TOTP_ISSUER" envDefault:"Gophermart"`
+	Skew          int64  `env:"TOTP_SKEW" envDefault:"1"`
+	RecoveryCodes int    `env:"TOTP_RECOVERY_CODES" envDefault:"10"`
+}
+
+// EnrollTOTP создает секрет аутентификатора. Двухфакторная аутентификация включается
+// только после подтверждения первого кода в ConfirmTOTP.
+func (g *Gophermart) EnrollTOTP(ctx context.Context, userID uint) (secret, uri string, err error) {
+	user, err := g.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+	if user.TOTPEnabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", fmt.Errorf("failed generate secret: %w", err)
+	}
+	if err := g.store.SetUserTOTPSecret(ctx, userID, secret); err != nil {
+		return "", "", fmt.Errorf("failed save totp secret: %w", err)
+	}
+
+	return secret, totp.URI(g.cfg.TOTP.Issuer, user.Login, secret), nil
+}
+
+// ConfirmTOTP проверяет первый код аутентификатора, включает двухфакторную аутентификацию
+// и возвращает коды восстановления. Коды показываются один раз, в базе хранятся их хеши.
+// Неверные коды учитываются той же защитой от перебора, что и второй шаг входа.
+func (g *Gophermart) ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := g.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	byUser := guardKey{kind: guardBySecondFactor, value: user.Login}
+	if err := g.guard.reserve(byUser); err != nil {
+		return nil, fmt.Errorf("totp confirmation of `%s` rejected: %w", user.Login, err)
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), g.cfg.TOTP.Skew)
+	if !ok {
+		g.loginFailed(ctx, user, user.Login, "", byUser)
+		return nil, ErrTOTPCodeNotValid
+	}
+	g.guard.success(byUser)
+
+	codes := make([]string, 0, g.cfg.TOTP.RecoveryCodes)
+	hashes := make([]*model.RecoveryCode, 0, g.cfg.TOTP.RecoveryCodes)
+	for range g.cfg.TOTP.RecoveryCodes {
+		raw := make([]byte, recoveryCodeSize*2)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed generate recovery code: %w", err)
+		}
+		recovery := recoveryEncoding.EncodeToString(raw)[:recoveryCodeSize*2]
+		codes = append(codes, recovery[:recoveryCodeSize]+"-"+recovery[recoveryCodeSize:])
+		hashes = append(hashes, &model.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(recovery)})
+	}
+
+	if err := g.store.EnableUserTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, fmt.Errorf("failed enable totp: %w", err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action: model.AuditTOTPEnabled,
+		UserID: userID,
+		Login:  user.Login,
+	})
+
+	return codes, nil
+}
+
+// DisableTOTP выключает двухфакторную аутентификацию после проверки кода аутентификатора или кода восстановления.
+func (g *Gophermart) DisableTOTP(ctx context.Context, userID uint, code string) error {
+	user, err := g.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnrolled
+	}
+
+	if err := g.checkSecondFactor(ctx, user, code, ""); err != nil {
+		return err
+	}
+
+	if err := g.store.DisableUserTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed disable totp: %w", err)
+	}
+
+	g.audit(ctx, &model.AuditEvent{
+		Action: model.AuditTOTPDisabled,
+		UserID: userID,
+		Login:  user.Login,
+	})
+
+	return nil
+}
+
+// AuthorizationSecondFactor завершает вход пользователя, прошедшего проверку пароля,
+// кодом аутентификатора или одноразовым кодом восстановления.
+func (g *Gophermart) AuthorizationSecondFactor(ctx context.Context, userID uint, code, ip string) (model.User, error) {
+	user, err := g.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return user, fmt.Errorf("failed getting user id=`%d`: %w", userID, err)
+	}
+	if !user.TOTPEnabled {
+		return user, ErrTOTPNotEnrolled
+	}
+
+	if err := g.checkSecondFactor(ctx, user, code, ip); err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+// checkSecondFactor проверяет код с учетом защиты от перебора. Принятый код аутентификатора
+// запоминается, поэтому повторно его использовать нельзя.
+func (g *Gophermart) checkSecondFactor(ctx context.Context, user model.User, code, ip string) error {
+	byUser := guardKey{kind: guardBySecondFactor, value: user.Login}
+	if err := g.guard.reserve(byUser); err != nil {
+		return fmt.Errorf("second factor of `%s` rejected: %w", user.Login, err)
+	}
+
+	ok, err := g.useSecondFactor(ctx, user, code)
+	if err != nil {
+		g.guard.release(byUser)
+		return err
+	}
+	if !ok {
+		g.loginFailed(ctx, user, user.Login, ip, byUser)
+		return ErrTOTPCodeNotValid
+	}
+
+	g.guard.success(byUser)
+
+	return nil
+}
+
+func (g *Gophermart) useSecondFactor(ctx context.Context, user model.User, code string) (bool, error) {
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), g.cfg.TOTP.Skew); ok {
+		used, err := g.store.UseTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return false, fmt.Errorf("failed save totp step: %w", err)
+		}
+		return used, nil
+	}
+
+	recovery := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(recovery) != recoveryCodeSize*2 {
+		return false, nil
+	}
+	used, err := g.store.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(recovery))
+	if err != nil {
+		return false, fmt.Errorf("failed use recovery code: %w", err)
+	}
+	if used {
+		g.logger(ctx).Info("recovery code used", zap.Uint("userID", user.ID))
+		g.audit(ctx, &model.AuditEvent{
+			Action: model.AuditRecoveryUsed,
+			UserID: user.ID,
+			Login:  user.Login,
+		})
+	}
+
+	return used, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
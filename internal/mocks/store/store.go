@@ -33,11 +33,12 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 }
 
 // AddAccrual mocks base method.
-func (m *MockStore) AddAccrual(ctx context.Context, order *model.Order) error {
+func (m *MockStore) AddAccrual(ctx context.Context, order *model.Order) (model.Balance, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddAccrual", ctx, order)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(model.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // AddAccrual indicates an expected call of AddAccrual.
@@ -46,6 +47,194 @@ func (mr *MockStoreMockRecorder) AddAccrual(ctx, order any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccrual", reflect.TypeOf((*MockStore)(nil).AddAccrual), ctx, order)
 }
 
+// AddAuditEvent mocks base method.
+func (m *MockStore) AddAuditEvent(ctx context.Context, event *model.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAuditEvent", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAuditEvent indicates an expected call of AddAuditEvent.
+func (mr *MockStoreMockRecorder) AddAuditEvent(ctx, event any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAuditEvent", reflect.TypeOf((*MockStore)(nil).AddAuditEvent), ctx, event)
+}
+
+// AdjustUserBalance mocks base method.
+func (m *MockStore) AdjustUserBalance(ctx context.Context, userID uint, adjustment *model.BalanceAdjustment, event *model.AuditEvent) (model.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustUserBalance", ctx, userID, adjustment, event)
+	ret0, _ := ret[0].(model.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustUserBalance indicates an expected call of AdjustUserBalance.
+func (mr *MockStoreMockRecorder) AdjustUserBalance(ctx, userID, adjustment, event any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustUserBalance", reflect.TypeOf((*MockStore)(nil).AdjustUserBalance), ctx, userID, adjustment, event)
+}
+
+// AnonymizeUser mocks base method.
+func (m *MockStore) AnonymizeUser(ctx context.Context, userID uint, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeUser", ctx, userID, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnonymizeUser indicates an expected call of AnonymizeUser.
+func (mr *MockStoreMockRecorder) AnonymizeUser(ctx, userID, login any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUser", reflect.TypeOf((*MockStore)(nil).AnonymizeUser), ctx, userID, login)
+}
+
+// ChangeUserPassword mocks base method.
+func (m *MockStore) ChangeUserPassword(ctx context.Context, userID uint, hashPassword string) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeUserPassword", ctx, userID, hashPassword)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeUserPassword indicates an expected call of ChangeUserPassword.
+func (mr *MockStoreMockRecorder) ChangeUserPassword(ctx, userID, hashPassword any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeUserPassword", reflect.TypeOf((*MockStore)(nil).ChangeUserPassword), ctx, userID, hashPassword)
+}
+
+// CheckMigrations mocks base method.
+func (m *MockStore) CheckMigrations(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckMigrations", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckMigrations indicates an expected call of CheckMigrations.
+func (mr *MockStoreMockRecorder) CheckMigrations(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckMigrations", reflect.TypeOf((*MockStore)(nil).CheckMigrations), ctx)
+}
+
+// CreateMerchant mocks base method.
+func (m *MockStore) CreateMerchant(ctx context.Context, merchant *model.Merchant) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMerchant", ctx, merchant)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMerchant indicates an expected call of CreateMerchant.
+func (mr *MockStoreMockRecorder) CreateMerchant(ctx, merchant any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMerchant", reflect.TypeOf((*MockStore)(nil).CreateMerchant), ctx, merchant)
+}
+
+// CreateMerchantKey mocks base method.
+func (m *MockStore) CreateMerchantKey(ctx context.Context, key *model.MerchantKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMerchantKey", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMerchantKey indicates an expected call of CreateMerchantKey.
+func (mr *MockStoreMockRecorder) CreateMerchantKey(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMerchantKey", reflect.TypeOf((*MockStore)(nil).CreateMerchantKey), ctx, key)
+}
+
+// DisableUserTOTP mocks base method.
+func (m *MockStore) DisableUserTOTP(ctx context.Context, userID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableUserTOTP", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableUserTOTP indicates an expected call of DisableUserTOTP.
+func (mr *MockStoreMockRecorder) DisableUserTOTP(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableUserTOTP", reflect.TypeOf((*MockStore)(nil).DisableUserTOTP), ctx, userID)
+}
+
+// EnableUserTOTP mocks base method.
+func (m *MockStore) EnableUserTOTP(ctx context.Context, userID uint, step int64, codes []*model.RecoveryCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableUserTOTP", ctx, userID, step, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableUserTOTP indicates an expected call of EnableUserTOTP.
+func (mr *MockStoreMockRecorder) EnableUserTOTP(ctx, userID, step, codes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableUserTOTP", reflect.TypeOf((*MockStore)(nil).EnableUserTOTP), ctx, userID, step, codes)
+}
+
+// GetBalanceAdjustments mocks base method.
+func (m *MockStore) GetBalanceAdjustments(ctx context.Context, balanceID uint) ([]*model.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAdjustments", ctx, balanceID)
+	ret0, _ := ret[0].([]*model.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceAdjustments indicates an expected call of GetBalanceAdjustments.
+func (mr *MockStoreMockRecorder) GetBalanceAdjustments(ctx, balanceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAdjustments", reflect.TypeOf((*MockStore)(nil).GetBalanceAdjustments), ctx, balanceID)
+}
+
+// GetMerchant mocks base method.
+func (m *MockStore) GetMerchant(ctx context.Context, merchantID uint) (model.Merchant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMerchant", ctx, merchantID)
+	ret0, _ := ret[0].(model.Merchant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMerchant indicates an expected call of GetMerchant.
+func (mr *MockStoreMockRecorder) GetMerchant(ctx, merchantID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerchant", reflect.TypeOf((*MockStore)(nil).GetMerchant), ctx, merchantID)
+}
+
+// GetMerchantKey mocks base method.
+func (m *MockStore) GetMerchantKey(ctx context.Context, prefix string) (model.MerchantKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMerchantKey", ctx, prefix)
+	ret0, _ := ret[0].(model.MerchantKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMerchantKey indicates an expected call of GetMerchantKey.
+func (mr *MockStoreMockRecorder) GetMerchantKey(ctx, prefix any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerchantKey", reflect.TypeOf((*MockStore)(nil).GetMerchantKey), ctx, prefix)
+}
+
+// GetOrderStatusHistory mocks base method.
+func (m *MockStore) GetOrderStatusHistory(ctx context.Context, orderID uint) ([]*model.OrderStatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderStatusHistory", ctx, orderID)
+	ret0, _ := ret[0].([]*model.OrderStatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderStatusHistory indicates an expected call of GetOrderStatusHistory.
+func (mr *MockStoreMockRecorder) GetOrderStatusHistory(ctx, orderID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderStatusHistory", reflect.TypeOf((*MockStore)(nil).GetOrderStatusHistory), ctx, orderID)
+}
+
 // GetOrdersNotPrecessed mocks base method.
 func (m *MockStore) GetOrdersNotPrecessed(ctx context.Context) ([]*model.Order, error) {
 	m.ctrl.T.Helper()
@@ -76,6 +265,21 @@ func (mr *MockStoreMockRecorder) GetUserBalance(ctx, userID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserBalance", reflect.TypeOf((*MockStore)(nil).GetUserBalance), ctx, userID)
 }
 
+// GetUserByID mocks base method.
+func (m *MockStore) GetUserByID(ctx context.Context, userID uint) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, userID)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockStoreMockRecorder) GetUserByID(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockStore)(nil).GetUserByID), ctx, userID)
+}
+
 // GetUserByLogin mocks base method.
 func (m *MockStore) GetUserByLogin(ctx context.Context, login string) (model.User, error) {
 	m.ctrl.T.Helper()
@@ -91,34 +295,92 @@ func (mr *MockStoreMockRecorder) GetUserByLogin(ctx, login any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLogin", reflect.TypeOf((*MockStore)(nil).GetUserByLogin), ctx, login)
 }
 
+// GetUserOrder mocks base method.
+func (m *MockStore) GetUserOrder(ctx context.Context, userID uint, number string) (model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserOrder", ctx, userID, number)
+	ret0, _ := ret[0].(model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserOrder indicates an expected call of GetUserOrder.
+func (mr *MockStoreMockRecorder) GetUserOrder(ctx, userID, number any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrder", reflect.TypeOf((*MockStore)(nil).GetUserOrder), ctx, userID, number)
+}
+
 // GetUserOrders mocks base method.
-func (m *MockStore) GetUserOrders(ctx context.Context, userID uint) ([]*model.Order, error) {
+func (m *MockStore) GetUserOrders(ctx context.Context, userID uint, query model.ListQuery) ([]*model.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserOrders", ctx, userID)
+	ret := m.ctrl.Call(m, "GetUserOrders", ctx, userID, query)
 	ret0, _ := ret[0].([]*model.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUserOrders indicates an expected call of GetUserOrders.
-func (mr *MockStoreMockRecorder) GetUserOrders(ctx, userID any) *gomock.Call {
+func (mr *MockStoreMockRecorder) GetUserOrders(ctx, userID, query any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrders", reflect.TypeOf((*MockStore)(nil).GetUserOrders), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrders", reflect.TypeOf((*MockStore)(nil).GetUserOrders), ctx, userID, query)
 }
 
 // GetWithdrawalsFromBalance mocks base method.
-func (m *MockStore) GetWithdrawalsFromBalance(ctx context.Context, balanceID uint) ([]*model.WithdrawBalance, error) {
+func (m *MockStore) GetWithdrawalsFromBalance(ctx context.Context, balanceID uint, query model.ListQuery) ([]*model.WithdrawBalance, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdrawalsFromBalance", ctx, balanceID)
+	ret := m.ctrl.Call(m, "GetWithdrawalsFromBalance", ctx, balanceID, query)
 	ret0, _ := ret[0].([]*model.WithdrawBalance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetWithdrawalsFromBalance indicates an expected call of GetWithdrawalsFromBalance.
-func (mr *MockStoreMockRecorder) GetWithdrawalsFromBalance(ctx, balanceID any) *gomock.Call {
+func (mr *MockStoreMockRecorder) GetWithdrawalsFromBalance(ctx, balanceID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalsFromBalance", reflect.TypeOf((*MockStore)(nil).GetWithdrawalsFromBalance), ctx, balanceID, query)
+}
+
+// IsMerchantUser mocks base method.
+func (m *MockStore) IsMerchantUser(ctx context.Context, merchantID, userID uint) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsMerchantUser", ctx, merchantID, userID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsMerchantUser indicates an expected call of IsMerchantUser.
+func (mr *MockStoreMockRecorder) IsMerchantUser(ctx, merchantID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsMerchantUser", reflect.TypeOf((*MockStore)(nil).IsMerchantUser), ctx, merchantID, userID)
+}
+
+// LinkMerchantUser mocks base method.
+func (m *MockStore) LinkMerchantUser(ctx context.Context, merchantID, userID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkMerchantUser", ctx, merchantID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkMerchantUser indicates an expected call of LinkMerchantUser.
+func (mr *MockStoreMockRecorder) LinkMerchantUser(ctx, merchantID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkMerchantUser", reflect.TypeOf((*MockStore)(nil).LinkMerchantUser), ctx, merchantID, userID)
+}
+
+// Ping mocks base method.
+func (m *MockStore) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStoreMockRecorder) Ping(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalsFromBalance", reflect.TypeOf((*MockStore)(nil).GetWithdrawalsFromBalance), ctx, balanceID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStore)(nil).Ping), ctx)
 }
 
 // RegisterUser mocks base method.
@@ -135,30 +397,188 @@ func (mr *MockStoreMockRecorder) RegisterUser(ctx, login, hashPassword any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterUser", reflect.TypeOf((*MockStore)(nil).RegisterUser), ctx, login, hashPassword)
 }
 
+// RevokeMerchantKey mocks base method.
+func (m *MockStore) RevokeMerchantKey(ctx context.Context, merchantID, keyID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeMerchantKey", ctx, merchantID, keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeMerchantKey indicates an expected call of RevokeMerchantKey.
+func (mr *MockStoreMockRecorder) RevokeMerchantKey(ctx, merchantID, keyID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeMerchantKey", reflect.TypeOf((*MockStore)(nil).RevokeMerchantKey), ctx, merchantID, keyID)
+}
+
+// SearchUsers mocks base method.
+func (m *MockStore) SearchUsers(ctx context.Context, login string, limit int) ([]*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, login, limit)
+	ret0, _ := ret[0].([]*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockStoreMockRecorder) SearchUsers(ctx, login, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockStore)(nil).SearchUsers), ctx, login, limit)
+}
+
+// SetUserRole mocks base method.
+func (m *MockStore) SetUserRole(ctx context.Context, userID uint, role model.UserRole) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserRole", ctx, userID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserRole indicates an expected call of SetUserRole.
+func (mr *MockStoreMockRecorder) SetUserRole(ctx, userID, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserRole", reflect.TypeOf((*MockStore)(nil).SetUserRole), ctx, userID, role)
+}
+
+// SetUserTOTPSecret mocks base method.
+func (m *MockStore) SetUserTOTPSecret(ctx context.Context, userID uint, secret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserTOTPSecret", ctx, userID, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserTOTPSecret indicates an expected call of SetUserTOTPSecret.
+func (mr *MockStoreMockRecorder) SetUserTOTPSecret(ctx, userID, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserTOTPSecret", reflect.TypeOf((*MockStore)(nil).SetUserTOTPSecret), ctx, userID, secret)
+}
+
+// StreamOrders mocks base method.
+func (m *MockStore) StreamOrders(ctx context.Context, userID uint, query model.ListQuery, fn func(*model.Order) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamOrders", ctx, userID, query, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamOrders indicates an expected call of StreamOrders.
+func (mr *MockStoreMockRecorder) StreamOrders(ctx, userID, query, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamOrders", reflect.TypeOf((*MockStore)(nil).StreamOrders), ctx, userID, query, fn)
+}
+
+// StreamWithdrawals mocks base method.
+func (m *MockStore) StreamWithdrawals(ctx context.Context, userID uint, query model.ListQuery, fn func(*model.WithdrawBalance) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamWithdrawals", ctx, userID, query, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamWithdrawals indicates an expected call of StreamWithdrawals.
+func (mr *MockStoreMockRecorder) StreamWithdrawals(ctx, userID, query, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamWithdrawals", reflect.TypeOf((*MockStore)(nil).StreamWithdrawals), ctx, userID, query, fn)
+}
+
+// UnlinkMerchantUser mocks base method.
+func (m *MockStore) UnlinkMerchantUser(ctx context.Context, merchantID, userID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlinkMerchantUser", ctx, merchantID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlinkMerchantUser indicates an expected call of UnlinkMerchantUser.
+func (mr *MockStoreMockRecorder) UnlinkMerchantUser(ctx, merchantID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlinkMerchantUser", reflect.TypeOf((*MockStore)(nil).UnlinkMerchantUser), ctx, merchantID, userID)
+}
+
+// UpdateUserPasswordHash mocks base method.
+func (m *MockStore) UpdateUserPasswordHash(ctx context.Context, userID uint, hashPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPasswordHash", ctx, userID, hashPassword)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserPasswordHash indicates an expected call of UpdateUserPasswordHash.
+func (mr *MockStoreMockRecorder) UpdateUserPasswordHash(ctx, userID, hashPassword any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPasswordHash", reflect.TypeOf((*MockStore)(nil).UpdateUserPasswordHash), ctx, userID, hashPassword)
+}
+
 // UploadOrder mocks base method.
-func (m *MockStore) UploadOrder(ctx context.Context, userID uint, orderNumber string) error {
+func (m *MockStore) UploadOrder(ctx context.Context, userID uint, orderNumber string, merchantID uint) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UploadOrder", ctx, userID, orderNumber)
+	ret := m.ctrl.Call(m, "UploadOrder", ctx, userID, orderNumber, merchantID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UploadOrder indicates an expected call of UploadOrder.
-func (mr *MockStoreMockRecorder) UploadOrder(ctx, userID, orderNumber any) *gomock.Call {
+func (mr *MockStoreMockRecorder) UploadOrder(ctx, userID, orderNumber, merchantID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadOrder", reflect.TypeOf((*MockStore)(nil).UploadOrder), ctx, userID, orderNumber, merchantID)
+}
+
+// UploadOrders mocks base method.
+func (m *MockStore) UploadOrders(ctx context.Context, userID uint, orderNumbers []string) (map[string]model.OrderUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadOrders", ctx, userID, orderNumbers)
+	ret0, _ := ret[0].(map[string]model.OrderUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadOrders indicates an expected call of UploadOrders.
+func (mr *MockStoreMockRecorder) UploadOrders(ctx, userID, orderNumbers any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadOrders", reflect.TypeOf((*MockStore)(nil).UploadOrders), ctx, userID, orderNumbers)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockStore) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, userID, codeHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockStoreMockRecorder) UseRecoveryCode(ctx, userID, codeHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockStore)(nil).UseRecoveryCode), ctx, userID, codeHash)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockStore) UseTOTPStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, userID, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockStoreMockRecorder) UseTOTPStep(ctx, userID, step any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadOrder", reflect.TypeOf((*MockStore)(nil).UploadOrder), ctx, userID, orderNumber)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockStore)(nil).UseTOTPStep), ctx, userID, step)
 }
 
 // WithdrawFromUserBalance mocks base method.
-func (m *MockStore) WithdrawFromUserBalance(ctx context.Context, userID uint, order string, sum float32) error {
+func (m *MockStore) WithdrawFromUserBalance(ctx context.Context, userID uint, order string, sum float32, merchantID uint) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WithdrawFromUserBalance", ctx, userID, order, sum)
+	ret := m.ctrl.Call(m, "WithdrawFromUserBalance", ctx, userID, order, sum, merchantID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // WithdrawFromUserBalance indicates an expected call of WithdrawFromUserBalance.
-func (mr *MockStoreMockRecorder) WithdrawFromUserBalance(ctx, userID, order, sum any) *gomock.Call {
+func (mr *MockStoreMockRecorder) WithdrawFromUserBalance(ctx, userID, order, sum, merchantID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawFromUserBalance", reflect.TypeOf((*MockStore)(nil).WithdrawFromUserBalance), ctx, userID, order, sum)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawFromUserBalance", reflect.TypeOf((*MockStore)(nil).WithdrawFromUserBalance), ctx, userID, order, sum, merchantID)
 }
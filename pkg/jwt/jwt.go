@@ -1,26 +1,72 @@
 package jwt
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
+var (
+	// ErrTokenExpired токен просрочен или выпущен без срока действия, когда срок обязателен.
+	ErrTokenExpired = errors.New("token expired")
+
+	headerKeyID  = "kid"
+	claimExpires = "exp"
+)
+
 type JWT struct {
-	secret []byte
+	keys *KeySet
 }
 
+// New создает JWT с единственным ключом без идентификатора.
 func New(secret []byte) *JWT {
+	key := NewHMACKey("", secret)
+	return &JWT{
+		keys: &KeySet{
+			mu:     &sync.RWMutex{},
+			keys:   map[string]Key{key.ID: key},
+			static: []Key{key},
+		},
+	}
+}
+
+func NewWithKeySet(keys *KeySet) *JWT {
 	return &JWT{
-		secret: secret,
+		keys: keys,
 	}
 }
 
 func (s *JWT) Create(key, value string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		key: value,
-	})
-	tokenString, err := token.SignedString(s.secret)
+	return s.CreateClaims(map[string]string{key: value})
+}
+
+func (s *JWT) CreateClaims(claims map[string]string) (string, error) {
+	mapClaims := jwt.MapClaims{}
+	for key, value := range claims {
+		mapClaims[key] = value
+	}
+	return s.sign(mapClaims)
+}
+
+// CreateExpiring подписывает claims со сроком действия exp до expiresAt.
+func (s *JWT) CreateExpiring(claims map[string]string, expiresAt time.Time) (string, error) {
+	mapClaims := jwt.MapClaims{claimExpires: expiresAt.Unix()}
+	for key, value := range claims {
+		mapClaims[key] = value
+	}
+	return s.sign(mapClaims)
+}
+
+func (s *JWT) sign(mapClaims jwt.MapClaims) (string, error) {
+	key := s.keys.signingKey()
+	token := jwt.NewWithClaims(key.method, mapClaims)
+	if key.ID != "" {
+		token.Header[headerKeyID] = key.ID
+	}
+	tokenString, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", fmt.Errorf("failed signe token: %w", err)
 	}
@@ -29,24 +75,82 @@ func (s *JWT) Create(key, value string) (string, error) {
 }
 
 func (s *JWT) Verify(signedData string, key string) (string, bool, error) {
+	claims, err := s.Claims(signedData)
+	if err != nil {
+		return "", false, err
+	}
+
+	if uniqueID, ok := claims[key]; ok && uniqueID != "" {
+		return uniqueID, true, nil
+	}
+
+	return "", false, nil
+}
+
+func (s *JWT) JWKS() JWKSet {
+	return s.keys.JWKS()
+}
+
+// Claims проверяет подпись токена и срок действия, если он указан, и возвращает строковые поля токена.
+func (s *JWT) Claims(signedData string) (map[string]string, error) {
+	claims, err := s.parse(signedData)
+	if err != nil {
+		return nil, err
+	}
+	return stringClaims(claims), nil
+}
+
+// ExpiringClaims как Claims, но принимает только токены со сроком действия.
+func (s *JWT) ExpiringClaims(signedData string) (map[string]string, error) {
+	claims, err := s.parse(signedData)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := claims[claimExpires]; !ok {
+		return nil, fmt.Errorf("token without %s: %w", claimExpires, ErrTokenExpired)
+	}
+	return stringClaims(claims), nil
+}
+
+func (s *JWT) parse(signedData string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(signedData, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header[headerKeyID].(string)
+		key, err := s.keys.lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Algorithm() {
 			return nil, fmt.Errorf("unknown signing method: %v", token.Header["alg"])
 		}
-		return s.secret, nil
+		return key.verifyKey, nil
 	})
 
 	if err != nil {
-		return "", false, fmt.Errorf("failed parse jwt token: %w", err)
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if uniqueID, ok := claims[key].(string); ok {
-			if uniqueID != "" {
-				return uniqueID, true, nil
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) {
+			switch {
+			case validationErr.Errors&jwt.ValidationErrorExpired != 0:
+				err = ErrTokenExpired
+			case validationErr.Inner != nil:
+				err = validationErr.Inner
 			}
 		}
+		return nil, fmt.Errorf("failed parse jwt token: %w", err)
 	}
 
-	return "", false, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return jwt.MapClaims{}, nil
+	}
+	return claims, nil
+}
+
+func stringClaims(claims jwt.MapClaims) map[string]string {
+	result := map[string]string{}
+	for key, value := range claims {
+		if v, ok := value.(string); ok {
+			result[key] = v
+		}
+	}
+	return result
 }
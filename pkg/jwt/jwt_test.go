@@ -0,0 +1,138 @@
+package jwt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/playmixer/gophermart/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySet_rotation(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+	}
+
+	writeFile("2024-01.key", "first secret")
+	keys, err := jwt.NewDirKeySet(dir, "", jwt.NewHMACKey("", []byte("legacy")))
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01", keys.ActiveKeyID())
+
+	legacy, err := jwt.New([]byte("legacy")).Create("UserID", "1")
+	require.NoError(t, err)
+
+	tokens := jwt.NewWithKeySet(keys)
+	first, err := tokens.Create("UserID", "2")
+	require.NoError(t, err)
+
+	writeFile("2024-02.key", "second secret")
+	require.NoError(t, keys.Reload())
+	assert.Equal(t, "2024-02", keys.ActiveKeyID())
+
+	second, err := tokens.Create("UserID", "3")
+	require.NoError(t, err)
+
+	for token, want := range map[string]string{legacy: "1", first: "2", second: "3"} {
+		userID, ok, err := tokens.Verify(token, "UserID")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, want, userID)
+	}
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "2024-01.key")))
+	require.NoError(t, keys.Reload())
+	_, _, err = tokens.Verify(first, "UserID")
+	assert.ErrorIs(t, err, jwt.ErrKeyNotFound)
+
+	writeFile("active", "missing")
+	assert.ErrorIs(t, keys.Reload(), jwt.ErrKeyNotFound)
+	assert.Equal(t, "2024-02", keys.ActiveKeyID())
+}
+
+func TestKeySet_asymmetric(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		private any
+		public  any
+		name    string
+		alg     string
+		kty     string
+	}{
+		{name: "rsa", private: rsaKey, public: &rsaKey.PublicKey, alg: "RS256", kty: "RSA"},
+		{name: "ed25519", private: edKey, public: edKey.Public(), alg: "EdDSA", kty: "OKP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privateDER, err := x509.MarshalPKCS8PrivateKey(tt.private)
+			require.NoError(t, err)
+			publicDER, err := x509.MarshalPKIXPublicKey(tt.public)
+			require.NoError(t, err)
+
+			signKey, err := jwt.ParsePEMKey(tt.name, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+			require.NoError(t, err)
+			verifyKey, err := jwt.ParsePEMKey(tt.name, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+			require.NoError(t, err)
+			assert.Equal(t, tt.alg, signKey.Algorithm())
+			assert.False(t, verifyKey.CanSign())
+
+			signer, err := jwt.NewKeySet(tt.name, signKey)
+			require.NoError(t, err)
+			token, err := jwt.NewWithKeySet(signer).Create("UserID", "1")
+			require.NoError(t, err)
+
+			_, err = jwt.NewKeySet(tt.name, verifyKey)
+			assert.ErrorIs(t, err, jwt.ErrKeyCanNotSign)
+
+			jwks := signer.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, tt.name, jwks.Keys[0].Kid)
+			assert.Equal(t, tt.kty, jwks.Keys[0].Kty)
+
+			verifier, err := jwt.NewKeySet("", jwt.NewHMACKey("", []byte("secret")), verifyKey)
+			require.NoError(t, err)
+			userID, ok, err := jwt.NewWithKeySet(verifier).Verify(token, "UserID")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, "1", userID)
+		})
+	}
+}
+
+func TestJWT_expiring(t *testing.T) {
+	tokens := jwt.New([]byte("secret"))
+
+	valid, err := tokens.CreateExpiring(map[string]string{"UserID": "1"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	claims, err := tokens.ExpiringClaims(valid)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"UserID": "1"}, claims)
+
+	expired, err := tokens.CreateExpiring(map[string]string{"UserID": "1"}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = tokens.Claims(expired)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	_, err = tokens.ExpiringClaims(expired)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+
+	// токен без срока действия принимает только Claims
+	unlimited, err := tokens.Create("UserID", "1")
+	require.NoError(t, err)
+	_, err = tokens.Claims(unlimited)
+	assert.NoError(t, err)
+	_, err = tokens.ExpiringClaims(unlimited)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
@@ -0,0 +1,161 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrKeyNotSupported = errors.New("key type not supported")
+	ErrKeyCanNotSign   = errors.New("key can not sign tokens")
+)
+
+// Key ключ подписи токенов. Для HMAC ключа подпись и проверка выполняются одним секретом,
+// для RS256 и EdDSA ключей проверка выполняется открытым ключом, который публикуется в JWKS.
+type Key struct {
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	ID        string
+}
+
+func NewHMACKey(id string, secret []byte) Key {
+	return Key{
+		ID:        id,
+		method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// ParsePEMKey разбирает RSA или Ed25519 ключ в формате PEM. Закрытый ключ используется для подписи,
+// открытый ключ без закрытого позволяет только проверять токены.
+func ParsePEMKey(id string, data []byte) (Key, error) {
+	key := Key{ID: id}
+	if id == "" {
+		return key, errors.New("asymmetric key must have kid")
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return key, fmt.Errorf("key `%s`: no PEM data found", id)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return key, fmt.Errorf("key `%s` PEM block `%s`: %w", id, block.Type, ErrKeyNotSupported)
+	}
+	if err != nil {
+		return key, fmt.Errorf("failed parse key `%s`: %w", id, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.verifyKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	default:
+		return key, fmt.Errorf("key `%s` %T: %w", id, parsed, ErrKeyNotSupported)
+	}
+
+	return key, nil
+}
+
+// ParsePEMFiles загружает ключи из списка вида `kid1:/path/key1.pem,kid2:/path/key2.pem`.
+func ParsePEMFiles(s string) ([]Key, error) {
+	keys := []Key{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, path, ok := strings.Cut(item, ":")
+		if !ok || id == "" || path == "" {
+			return nil, fmt.Errorf("key must be in format `kid:path`, got `%s`", item)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed read key file `%s`: %w", path, err)
+		}
+		key, err := ParsePEMKey(id, data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (k Key) Algorithm() string {
+	if k.method == nil {
+		return ""
+	}
+	return k.method.Alg()
+}
+
+func (k Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK возвращает открытую часть ключа. Для HMAC ключа публиковать нечего.
+func (k Key) JWK() (JWK, bool) {
+	jwk := JWK{
+		Kid: k.ID,
+		Use: "sig",
+		Alg: k.Algorithm(),
+	}
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return jwk, false
+	}
+
+	return jwk, true
+}
@@ -0,0 +1,226 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+
+	keyFileExt    = ".key"
+	pemFileExt    = ".pem"
+	activeKeyFile = "active"
+)
+
+// KeySet хранит ключи подписи токенов. Новые токены подписываются активным ключом,
+// проверяются токены, подписанные любым ключом из набора. Ключ ищется по заголовку `kid`,
+// токены без `kid` проверяются ключом с пустым идентификатором.
+type KeySet struct {
+	mu           *sync.RWMutex
+	keys         map[string]Key
+	active       string
+	dir          string
+	staticActive string
+	static       []Key
+}
+
+func NewKeySet(active string, keys ...Key) (*KeySet, error) {
+	ks := &KeySet{
+		mu:           &sync.RWMutex{},
+		staticActive: active,
+		static:       keys,
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// NewDirKeySet создает набор ключей из каталога: файл `<kid>.key` содержит HMAC секрет,
+// файл `<kid>.pem` содержит RSA или Ed25519 ключ, файл `active` содержит идентификатор активного ключа.
+// Ключи keys добавляются к ключам каталога. Если active пустой и файла `active` нет,
+// активным становится ключ подписи с наибольшим идентификатором.
+func NewDirKeySet(dir, active string, keys ...Key) (*KeySet, error) {
+	ks := &KeySet{
+		mu:           &sync.RWMutex{},
+		dir:          dir,
+		staticActive: active,
+		static:       keys,
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// ParseKeys разбирает список ключей вида `kid1:secret1,kid2:secret2`.
+func ParseKeys(s string) ([]Key, error) {
+	keys := []Key{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(item, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("key must be in format `kid:secret`, got `%s`", item)
+		}
+		keys = append(keys, NewHMACKey(id, []byte(secret)))
+	}
+
+	return keys, nil
+}
+
+// Reload перечитывает каталог ключей. При ошибке текущий набор не меняется.
+func (ks *KeySet) Reload() error {
+	keys := make(map[string]Key)
+	for _, key := range ks.static {
+		keys[key.ID] = key
+	}
+
+	active := ks.staticActive
+	if ks.dir != "" {
+		dirKeys, dirActive, err := loadDir(ks.dir)
+		if err != nil {
+			return err
+		}
+		for _, key := range dirKeys {
+			keys[key.ID] = key
+		}
+		if active == "" {
+			active = dirActive
+		}
+		for i := len(dirKeys) - 1; active == "" && i >= 0; i-- {
+			if dirKeys[i].CanSign() {
+				active = dirKeys[i].ID
+			}
+		}
+	}
+
+	activeKey, ok := keys[active]
+	if !ok {
+		return fmt.Errorf("active key `%s`: %w", active, ErrKeyNotFound)
+	}
+	if !activeKey.CanSign() {
+		return fmt.Errorf("active key `%s`: %w", active, ErrKeyCanNotSign)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	ks.active = active
+
+	return nil
+}
+
+// Watch перечитывает каталог ключей с периодом interval до отмены контекста.
+func (ks *KeySet) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	if ks.dir == "" || interval <= 0 {
+		return
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := ks.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (ks *KeySet) ActiveKeyID() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+// JWKS возвращает открытые ключи набора для проверки токенов сторонними сервисами.
+func (ks *KeySet) JWKS() JWKSet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+func (ks *KeySet) signingKey() Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[ks.active]
+}
+
+func (ks *KeySet) lookup(id string) (Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[id]
+	if !ok {
+		return key, fmt.Errorf("kid `%s`: %w", id, ErrKeyNotFound)
+	}
+	return key, nil
+}
+
+func loadDir(dir string) (keys []Key, active string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed read keys directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if name != activeKeyFile && ext != keyFileExt && ext != pemFileExt {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed read key file `%s`: %w", name, err)
+		}
+		if name == activeKeyFile {
+			active = strings.TrimSpace(string(data))
+			continue
+		}
+		if ext == keyFileExt {
+			secret := strings.TrimSpace(string(data))
+			if secret == "" {
+				return nil, "", fmt.Errorf("key file `%s` is empty", name)
+			}
+			keys = append(keys, NewHMACKey(strings.TrimSuffix(name, keyFileExt), []byte(secret)))
+			continue
+		}
+		key, err := ParsePEMKey(strings.TrimSuffix(name, pemFileExt), data)
+		if err != nil {
+			return nil, "", err
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, active, nil
+}
@@ -0,0 +1,65 @@
+// Package requestid передает идентификатор запроса X-Request-ID через context,
+// чтобы по нему можно было найти в логах все записи одного запроса.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+)
+
+const (
+	Header   = "X-Request-ID"
+	LogField = "request_id"
+
+	maxLength = 128
+)
+
+type ctxKey struct{}
+
+// New создает случайный идентификатор запроса.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("requestid: failed read random: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid сообщает, что идентификатор от клиента можно принять: непустой, не длиннее 128 символов,
+// только латинские буквы, цифры и символы -_.:/+=. Остальное могло бы испортить заголовки и логи.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':', r == '/', r == '+', r == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext возвращает контекст с идентификатором запроса.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext возвращает идентификатор запроса или пустую строку.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Logger добавляет к log поле request_id, если в контексте есть идентификатор запроса.
+func Logger(ctx context.Context, log *zap.Logger) *zap.Logger {
+	if id := FromContext(ctx); id != "" {
+		return log.With(zap.String(LogField, id))
+	}
+	return log
+}
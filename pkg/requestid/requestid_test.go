@@ -0,0 +1,55 @@
+package requestid_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/playmixer/gophermart/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNew(t *testing.T) {
+	id := requestid.New()
+	assert.Len(t, id, 32)
+	assert.True(t, requestid.Valid(id))
+	assert.NotEqual(t, id, requestid.New())
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{id: "2f1c9a", valid: true},
+		{id: "req-42_a.b:c/d+e=", valid: true},
+		{id: strings.Repeat("a", 128), valid: true},
+		{id: strings.Repeat("a", 129)},
+		{id: ""},
+		{id: "has space"},
+		{id: "line\nbreak"},
+		{id: "кириллица"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.valid, requestid.Valid(tt.id), tt.id)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	log := zap.New(core)
+
+	requestid.Logger(context.Background(), log).Info("without id")
+	ctx := requestid.NewContext(context.Background(), "abc")
+	assert.Equal(t, "abc", requestid.FromContext(ctx))
+	requestid.Logger(ctx, log).Info("with id")
+
+	entries := logs.All()
+	assert.Len(t, entries, 2)
+	assert.Empty(t, entries[0].ContextMap())
+	assert.Equal(t, "abc", entries[1].ContextMap()[requestid.LogField])
+}
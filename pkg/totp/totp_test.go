@@ -0,0 +1,56 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/playmixer/gophermart/pkg/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCode_rfc6238(t *testing.T) {
+	// Тестовые значения RFC 6238 для SHA1, последние 6 цифр 8-значных кодов.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		code string
+		unix int64
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := totp.Code(secret, totp.Step(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := totp.Code(secret, totp.Step(now.Add(-30*time.Second)))
+	require.NoError(t, err)
+
+	step, ok := totp.Validate(secret, code, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now)-1, step)
+
+	_, ok = totp.Validate(secret, code, now, 0)
+	assert.False(t, ok)
+
+	_, ok = totp.Validate("not base32!", code, now, 1)
+	assert.False(t, ok)
+
+	uri := totp.URI("Gophermart", "user", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Gophermart:user?"))
+	assert.Contains(t, uri, "secret="+secret)
+}
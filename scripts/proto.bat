@@ -0,0 +1 @@
+protoc --proto_path=./internal/adapters/api/grpcapi/pb --go_out=./internal/adapters/api/grpcapi/pb --go_opt=paths=source_relative --go-grpc_out=./internal/adapters/api/grpcapi/pb --go-grpc_opt=paths=source_relative gophermart.proto
\ No newline at end of file